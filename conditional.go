@@ -0,0 +1,107 @@
+// Copyright 2014 Manu Martinez-Almeida.  All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package gin
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// CheckETag sets the ETag header of response to etag, and checks it against the If-None-Match header of request.
+// If it matches, the request is answered with 304 Not Modified (412 Precondition Failed for the methods other
+// than GET and HEAD), the handler chain is aborted, and it returns true; the handler should return without
+// rendering the response. The etag must be a quoted string, optionally with the weak prefix, e.g. `W/"v1"`.
+//
+//     if ctx.CheckETag(`"` + article.Version + `"`) {
+//         return
+//     }
+//     ctx.JSON(200, article)
+func (ctx *Context) CheckETag(etag string) bool {
+	ctx.ResponseWriter.Header().Set(HeaderETag, etag)
+	inm := ctx.Request.Header.Get(HeaderIfNoneMatch)
+	if inm == "" || !etagMatch(inm, etag) {
+		return false
+	}
+	if method := ctx.Request.Method; method == http.MethodGet || method == http.MethodHead {
+		ctx.notModified()
+	} else {
+		ctx.AbortWithStatus(http.StatusPreconditionFailed)
+	}
+	return true
+}
+
+// CheckLastModified sets the Last-Modified header of response to modtime, and checks it against the
+// If-Modified-Since header of GET and HEAD request, the If-Modified-Since header is ignored if the request
+// has the If-None-Match header. If the resource is not modified since, the request is answered with
+// 304 Not Modified, the handler chain is aborted, and it returns true; the handler should return without
+// rendering the response. The zero modtime is ignored.
+func (ctx *Context) CheckLastModified(modtime time.Time) bool {
+	if modtime.IsZero() || modtime.Equal(time.Unix(0, 0)) {
+		return false
+	}
+	ctx.ResponseWriter.Header().Set(HeaderLastModified, modtime.UTC().Format(http.TimeFormat))
+	if method := ctx.Request.Method; method != http.MethodGet && method != http.MethodHead {
+		return false
+	}
+	if ctx.Request.Header.Get(HeaderIfNoneMatch) != "" {
+		return false
+	}
+	ims := ctx.Request.Header.Get(HeaderIfModifiedSince)
+	if ims == "" {
+		return false
+	}
+	t, err := http.ParseTime(ims)
+	if err != nil {
+		return false
+	}
+	// The Last-Modified header truncates sub-second precision so the modtime needs to be truncated too.
+	if modtime.Truncate(time.Second).After(t) {
+		return false
+	}
+	ctx.notModified()
+	return true
+}
+
+// notModified answers the request with 304 Not Modified and aborts the handler chain.
+func (ctx *Context) notModified() {
+	// RFC 7232 section 4.1:
+	// a sender SHOULD NOT generate representation metadata other than the above listed fields.
+	header := ctx.ResponseWriter.Header()
+	delete(header, HeaderContentType)
+	delete(header, HeaderContentLength)
+	delete(header, HeaderContentEncoding)
+	ctx.AbortWithStatus(http.StatusNotModified)
+}
+
+// etagMatch reports whether etag matches any entity-tag of the If-None-Match header by the weak comparison.
+func etagMatch(inm, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+	for inm != "" {
+		inm = strings.TrimLeft(inm, " \t,")
+		if inm == "" {
+			break
+		}
+		if inm[0] == '*' {
+			return true
+		}
+		candidate := inm
+		if strings.HasPrefix(candidate, "W/") {
+			candidate = candidate[2:]
+		}
+		if len(candidate) < 2 || candidate[0] != '"' {
+			return false
+		}
+		end := strings.IndexByte(candidate[1:], '"')
+		if end < 0 {
+			return false
+		}
+		if candidate[:end+2] == etag {
+			return true
+		}
+		inm = candidate[end+2:]
+	}
+	return false
+}
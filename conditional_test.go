@@ -0,0 +1,95 @@
+// Copyright 2014 Manu Martinez-Almeida.  All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package gin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCheckETag(t *testing.T) {
+	engine := New()
+	handler := func(ctx *Context) {
+		if ctx.CheckETag(`W/"v1"`) {
+			return
+		}
+		ctx.String(200, "article")
+	}
+	engine.Get("/article", handler)
+	engine.Head("/article", handler)
+	engine.Put("/article", handler)
+
+	tests := []struct {
+		method string
+		inm    string
+		status int
+		body   string
+	}{
+		{http.MethodGet, "", 200, "article"},
+		{http.MethodGet, `"v0"`, 200, "article"},
+		{http.MethodGet, `"v0", "v1"`, 304, ""},
+		{http.MethodGet, `W/"v1"`, 304, ""},
+		{http.MethodGet, `*`, 304, ""},
+		{http.MethodHead, `"v1"`, 304, ""},
+		{http.MethodPut, `"v1"`, 412, ""},
+		{http.MethodPut, `"v2"`, 200, "article"},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(tt.method, "/article", nil)
+		if tt.inm != "" {
+			r.Header.Set(HeaderIfNoneMatch, tt.inm)
+		}
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, r)
+		if w.Code != tt.status || w.Body.String() != tt.body {
+			t.Errorf("%s If-None-Match: %s got %d %q, want %d %q", tt.method, tt.inm, w.Code, w.Body.String(), tt.status, tt.body)
+		}
+		if etag := w.Header().Get(HeaderETag); etag != `W/"v1"` {
+			t.Errorf("%s If-None-Match: %s got ETag %q", tt.method, tt.inm, etag)
+		}
+	}
+}
+
+func TestCheckLastModified(t *testing.T) {
+	modtime := time.Date(2020, 1, 2, 3, 4, 5, 600, time.UTC)
+	engine := New()
+	engine.Get("/article", func(ctx *Context) {
+		if ctx.CheckLastModified(modtime) {
+			return
+		}
+		ctx.String(200, "article")
+	})
+
+	tests := []struct {
+		ims    time.Time
+		inm    string
+		status int
+	}{
+		{time.Time{}, "", 200},
+		{modtime.Add(-time.Second), "", 200},
+		{modtime, "", 304},
+		{modtime.Add(time.Hour), "", 304},
+		{modtime, `"v1"`, 200}, // If-None-Match takes precedence
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/article", nil)
+		if !tt.ims.IsZero() {
+			r.Header.Set(HeaderIfModifiedSince, tt.ims.Format(http.TimeFormat))
+		}
+		if tt.inm != "" {
+			r.Header.Set(HeaderIfNoneMatch, tt.inm)
+		}
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, r)
+		if w.Code != tt.status {
+			t.Errorf("If-Modified-Since: %s got %d, want %d", tt.ims, w.Code, tt.status)
+		}
+		if lm := w.Header().Get(HeaderLastModified); lm != modtime.Format(http.TimeFormat) {
+			t.Errorf("got Last-Modified %q", lm)
+		}
+	}
+}
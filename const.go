@@ -57,15 +57,28 @@ const (
 	HeaderSetCookie                     = "Set-Cookie"
 	HeaderIfModifiedSince               = "If-Modified-Since"
 	HeaderLastModified                  = "Last-Modified"
+	HeaderIfNoneMatch                   = "If-None-Match"
+	HeaderETag                          = "ETag"
+	HeaderCacheControl                  = "Cache-Control"
+	HeaderAge                           = "Age"
 	HeaderLocation                      = "Location"
 	HeaderUpgrade                       = "Upgrade"
 	HeaderVary                          = "Vary"
 	HeaderWWWAuthenticate               = "WWW-Authenticate"
+	HeaderForwarded                     = "Forwarded"
 	HeaderXForwardedProto               = "X-Forwarded-Proto"
+	HeaderXForwardedHost                = "X-Forwarded-Host"
+	HeaderXForwardedPrefix              = "X-Forwarded-Prefix"
 	HeaderXHTTPMethodOverride           = "X-HTTP-Method-Override"
 	HeaderXForwardedFor                 = "X-Forwarded-For"
 	HeaderXRealIP                       = "X-Real-IP"
 	HeaderServer                        = "Server"
+	HeaderRetryAfter                    = "Retry-After"
+	HeaderRateLimitLimit                = "RateLimit-Limit"
+	HeaderRateLimitRemaining            = "RateLimit-Remaining"
+	HeaderRateLimitReset                = "RateLimit-Reset"
+	HeaderIdempotencyKey                = "Idempotency-Key"
+	HeaderIdempotentReplayed            = "Idempotent-Replayed"
 	HeaderOrigin                        = "Origin"
 	HeaderAccessControlRequestMethod    = "Access-Control-Request-Method"
 	HeaderAccessControlRequestHeaders   = "Access-Control-Request-Headers"
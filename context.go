@@ -17,7 +17,6 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
-	"strings"
 	"time"
 	"unicode"
 
@@ -25,10 +24,7 @@ import (
 	"github.com/chanxuehong/gin/internal/response"
 )
 
-const (
-	__initHandlerIndex  = -1
-	__abortHandlerIndex = __maxHandlerChainSize
-)
+const __initHandlerIndex = -1
 
 // Context is the most important part of gin. It allows us to pass variables between middleware,
 // manage the flow, validate the JSON of a request and render a JSON response for example.
@@ -42,7 +38,7 @@ type Context struct {
 	ResponseWriter      ResponseWriter // convert from Context.responseWriter2
 	Request             *http.Request
 
-	pathParamsBuffer [8]Param // Context.PathParams points to this
+	pathParamsBuffer Params // Context.PathParams points to this, the capacity of it is Engine.maxParams
 	PathParams       Params
 	queryParams      url.Values
 
@@ -52,10 +48,14 @@ type Context struct {
 	// also you can change this in handlers.
 	Validator StructValidator
 
-	fetchClientIPFromHeader bool
+	engine *Engine // the engine which created the context
 
 	handlers     HandlerChain
 	handlerIndex int
+	aborted      bool
+	route        *routeInfo // matched route, nil if no route matched
+
+	beforeWriteHeader bool // Context.BeforeWriteHeader() has been called
 
 	kvs map[string]interface{}
 }
@@ -70,9 +70,11 @@ func (ctx *Context) reset() {
 	ctx.PathParams = nil
 	ctx.queryParams = nil
 	ctx.Validator = nil
-	ctx.fetchClientIPFromHeader = false
 	ctx.handlers = nil
 	ctx.handlerIndex = __initHandlerIndex
+	ctx.aborted = false
+	ctx.route = nil
+	ctx.beforeWriteHeader = false
 	ctx.kvs = nil
 }
 
@@ -84,16 +86,18 @@ func (ctx *Context) Copy() *Context {
 		pathParams = append(pathParams, ctx.PathParams...)
 	}
 	return &Context{
-		responseWriter2:         nil,
-		ResponseWriter:          nil,
-		Request:                 ctx.Request,
-		PathParams:              pathParams,
-		queryParams:             ctx.queryParams,
-		Validator:               ctx.Validator,
-		fetchClientIPFromHeader: ctx.fetchClientIPFromHeader,
-		handlers:                nil,
-		handlerIndex:            __abortHandlerIndex,
-		kvs:                     ctx.kvs,
+		responseWriter2: nil,
+		ResponseWriter:  nil,
+		Request:         ctx.Request,
+		PathParams:      pathParams,
+		queryParams:     ctx.queryParams,
+		Validator:       ctx.Validator,
+		engine:          ctx.engine,
+		handlers:        nil,
+		handlerIndex:    __initHandlerIndex,
+		aborted:         true,
+		route:           ctx.route,
+		kvs:             ctx.kvs,
 	}
 }
 
@@ -105,7 +109,7 @@ func (ctx *Context) HandlerName() string {
 
 // IsAborted returns true if the currect context was aborted.
 func (ctx *Context) IsAborted() bool {
-	return ctx.handlerIndex >= __abortHandlerIndex
+	return ctx.aborted
 }
 
 // Abort prevents pending handlers from being called. Note that this will not stop the current handler.
@@ -113,7 +117,7 @@ func (ctx *Context) IsAborted() bool {
 // authorization fails (ex: the password does not match), call Abort to ensure the remaining handlers
 // for this request are not called.
 func (ctx *Context) Abort() {
-	ctx.handlerIndex = __abortHandlerIndex
+	ctx.aborted = true
 }
 
 // AbortWithStatus writes the headers with the specified status code and calls `Abort()`.
@@ -131,10 +135,22 @@ func (ctx *Context) AbortWithError(code int, format string, values ...interface{
 	ctx.Abort()
 }
 
+// BeforeWriteHeader registers fn to be called just before the response header is written,
+// so that fn can still modify the header, e.g. set the session cookie after the handlers changed the session.
+// The functions are called in the reverse order of registration, and they must not write the response.
+// If the handlers write nothing, the functions are called when the handler chain returns.
+func (ctx *Context) BeforeWriteHeader(fn func()) {
+	if fn == nil {
+		panic("fn can not be nil")
+	}
+	ctx.responseWriter2.BeforeWriteHeader(fn)
+	ctx.beforeWriteHeader = true
+}
+
 // Next should be used only inside middleware.
 // It executes the pending handlers in the chain inside the calling handler.
 func (ctx *Context) Next() {
-	for {
+	for !ctx.aborted {
 		ctx.handlerIndex++
 		if ctx.handlerIndex >= len(ctx.handlers) {
 			ctx.handlerIndex--
@@ -177,23 +193,27 @@ func (ctx *Context) MustGet(key string) interface{} {
 	panic(`[kvs] value with key "` + key + `" does not exist`)
 }
 
+// KeyQueueTime is the key of Context to store the time.Duration the request waited in a queue
+// before being handled, e.g. by middleware.ConcurrencyLimit, see QueueTime().
+const KeyQueueTime = "github.com/chanxuehong/gin.QueueTime"
+
+// QueueTime returns the time the request waited in a queue before being handled,
+// it returns 0 if the request did not wait, see KeyQueueTime.
+func (ctx *Context) QueueTime() time.Duration {
+	value, _ := ctx.Get(KeyQueueTime)
+	queueTime, _ := value.(time.Duration)
+	return queueTime
+}
+
 // ================================ request ====================================
 
+// ClientIP returns the IP of the client. If Engine.FetchClientIPFromHeader is enabled and the request
+// comes from a trusted proxy, the IP is got from the headers set by Engine.RemoteIPHeaders(),
+// see Engine.TrustedProxies(). Otherwise it returns the IP of Request.RemoteAddr.
 func (ctx *Context) ClientIP() (ip string) {
-	if ctx.fetchClientIPFromHeader {
-		header := ctx.Request.Header
-		if ip = header.Get("X-Real-Ip"); ip != "" {
-			if ip = strings.TrimSpace(ip); ip != "" {
-				return ip
-			}
-		}
-		if ip = header.Get("X-Forwarded-For"); ip != "" {
-			if index := strings.IndexByte(ip, ','); index >= 0 {
-				ip = ip[:index]
-			}
-			if ip = strings.TrimSpace(ip); ip != "" {
-				return ip
-			}
+	if ctx.engine.trustedRequest(ctx.Request) {
+		if ip, ok := ctx.engine.clientIPFromHeaders(ctx.Request); ok {
+			return ip
 		}
 	}
 	ip, _, err := net.SplitHostPort(ctx.Request.RemoteAddr)
@@ -203,6 +223,31 @@ func (ctx *Context) ClientIP() (ip string) {
 	return ip
 }
 
+// Scheme returns the scheme of the request, "http" or "https". If the request comes from
+// a trusted proxy, the scheme is got from the Forwarded or X-Forwarded-Proto header, see ClientIP().
+func (ctx *Context) Scheme() string {
+	if ctx.engine.trustedRequest(ctx.Request) {
+		if proto, _ := ctx.engine.forwardedProtoHost(ctx.Request); proto != "" {
+			return proto
+		}
+	}
+	if ctx.Request.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
+
+// Host returns the host of the request, it may have a port. If the request comes from
+// a trusted proxy, the host is got from the Forwarded or X-Forwarded-Host header, see ClientIP().
+func (ctx *Context) Host() string {
+	if ctx.engine.trustedRequest(ctx.Request) {
+		if _, host := ctx.engine.forwardedProtoHost(ctx.Request); host != "" {
+			return host
+		}
+	}
+	return ctx.Request.Host
+}
+
 // Cookie is a shortcut for ctx.Request.Cookie(name).
 // It returns the named cookie provided in the request or http.ErrNoCookie if not found.
 func (ctx *Context) Cookie(name string) (*http.Cookie, error) {
@@ -0,0 +1,219 @@
+// Copyright 2014 Manu Martinez-Almeida.  All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package gin
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// CookieDefaults are the attributes of the cookies set by Context.SetCookieValue(), Context.SetSignedCookie()
+// and Context.DeleteCookie(), see Engine.CookieDefaults().
+type CookieDefaults struct {
+	Domain string
+	Path   string // default is "/"
+	Secure bool
+
+	// DisableHttpOnly makes the cookies visible to the scripts, the cookies are HttpOnly by default.
+	DisableHttpOnly bool
+
+	// SameSite is http.SameSiteLaxMode if it is zero, http.SameSiteDefaultMode omits the attribute.
+	SameSite http.SameSite
+}
+
+// CookieDefaults sets the attributes of the cookies set by Context.SetCookieValue(), Context.SetSignedCookie()
+// and Context.DeleteCookie(). The zero fields keep the safe defaults, so that
+//     engine.CookieDefaults(gin.CookieDefaults{Secure: true})
+// sets the cookies with Path "/", Secure, HttpOnly and SameSite Lax.
+func (engine *Engine) CookieDefaults(defaults CookieDefaults) {
+	engine.startedChecker.check() // check if engine has been started.
+	if defaults.Path == "" {
+		defaults.Path = "/"
+	}
+	if defaults.SameSite == 0 {
+		defaults.SameSite = http.SameSiteLaxMode
+	}
+	engine.cookieDefaults = defaults
+}
+
+// CookieKeyring sets the keyring to sign the cookies of Context.SetSignedCookie(), see Keyring.
+func (engine *Engine) CookieKeyring(keyring *Keyring) {
+	engine.startedChecker.check() // check if engine has been started.
+	engine.cookieKeyring = keyring
+}
+
+// Keyring is the HMAC keys to sign and verify the values, the first key signs the values
+// and all the keys verify the values, so that the keys can be rotated without invalidating
+// the values signed by the previous keys. It is safe for concurrent use.
+type Keyring struct {
+	keys atomic.Value // [][]byte
+}
+
+// NewKeyring returns a new Keyring with keys, the first key is the current key.
+// The key should be at least 32 random bytes.
+func NewKeyring(keys ...[]byte) *Keyring {
+	keyring := new(Keyring)
+	keyring.SetKeys(keys...)
+	return keyring
+}
+
+// SetKeys replaces the keys of keyring, the first key is the current key.
+// It is safe to be called while serving requests.
+func (keyring *Keyring) SetKeys(keys ...[]byte) {
+	if len(keys) == 0 {
+		panic("keyring must have at least one key")
+	}
+	copied := make([][]byte, len(keys))
+	for i, key := range keys {
+		if len(key) == 0 {
+			panic("the key of keyring can not be empty")
+		}
+		copied[i] = append([]byte(nil), key...)
+	}
+	keyring.keys.Store(copied)
+}
+
+// Rotate makes key the current key and keeps at most keep previous keys to verify the values.
+// It is safe to be called while serving requests.
+func (keyring *Keyring) Rotate(key []byte, keep int) {
+	keys, _ := keyring.keys.Load().([][]byte)
+	if keep > len(keys) {
+		keep = len(keys)
+	}
+	if keep < 0 {
+		keep = 0
+	}
+	keyring.SetKeys(append([][]byte{key}, keys[:keep]...)...)
+}
+
+// Sign returns the HMAC-SHA256 of data by the current key.
+func (keyring *Keyring) Sign(data []byte) []byte {
+	keys := keyring.keys.Load().([][]byte)
+	return hmacSHA256(keys[0], data)
+}
+
+// Verify reports whether mac is the HMAC-SHA256 of data by any key of keyring.
+func (keyring *Keyring) Verify(data, mac []byte) bool {
+	keys, _ := keyring.keys.Load().([][]byte)
+	for _, key := range keys {
+		if hmac.Equal(hmacSHA256(key, data), mac) {
+			return true
+		}
+	}
+	return false
+}
+
+func hmacSHA256(key, data []byte) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+// ErrInvalidSignedCookie is returned by Context.SignedCookie() if the cookie is not signed
+// by the keyring of Engine or is expired.
+var ErrInvalidSignedCookie = errors.New("gin: invalid signed cookie")
+
+// newCookie returns a cookie with the attributes of Engine.CookieDefaults().
+// maxAge=0 means no 'Max-Age' attribute specified, maxAge<0 means delete cookie now,
+// maxAge>0 means Max-Age attribute present and given in seconds.
+func (ctx *Context) newCookie(name, value string, maxAge int) *http.Cookie {
+	defaults := ctx.engine.cookieDefaults
+	cookie := &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     defaults.Path,
+		Domain:   defaults.Domain,
+		MaxAge:   maxAge,
+		Secure:   defaults.Secure,
+		HttpOnly: !defaults.DisableHttpOnly,
+		SameSite: defaults.SameSite,
+	}
+	switch {
+	case maxAge > 0:
+		cookie.Expires = time.Now().Add(time.Duration(maxAge) * time.Second)
+	case maxAge < 0:
+		cookie.Expires = time.Unix(1, 0)
+	}
+	return cookie
+}
+
+// SetCookieValue sets the cookie with the attributes of Engine.CookieDefaults().
+// maxAge=0 means a session cookie, maxAge<0 means delete cookie now,
+// maxAge>0 means the cookie expires after maxAge seconds.
+func (ctx *Context) SetCookieValue(name, value string, maxAge int) {
+	http.SetCookie(ctx.ResponseWriter, ctx.newCookie(name, value, maxAge))
+}
+
+// DeleteCookie deletes the cookie set by SetCookieValue() or SetSignedCookie().
+func (ctx *Context) DeleteCookie(name string) {
+	http.SetCookie(ctx.ResponseWriter, ctx.newCookie(name, "", -1))
+}
+
+// SetSignedCookie sets the cookie like SetCookieValue(), the value is signed by the keyring of Engine
+// with the cookie name and the expiration time, so that it can not be modified or be reused after it is expired,
+// see Engine.CookieKeyring(). The value is visible to the client.
+func (ctx *Context) SetSignedCookie(name, value string, maxAge int) {
+	keyring := ctx.engine.cookieKeyring
+	if keyring == nil {
+		panic("the cookie keyring of engine is not set, see Engine.CookieKeyring()")
+	}
+	var expires int64
+	if maxAge > 0 {
+		expires = time.Now().Unix() + int64(maxAge)
+	}
+	encoded := base64.RawURLEncoding.EncodeToString([]byte(value))
+	payload := strconv.FormatInt(expires, 10) + "." + encoded
+	mac := keyring.Sign(signedCookieData(name, payload))
+	ctx.SetCookieValue(name, payload+"."+base64.RawURLEncoding.EncodeToString(mac), maxAge)
+}
+
+// SignedCookie returns the value of the cookie set by SetSignedCookie().
+// It returns http.ErrNoCookie if the cookie is not found, and ErrInvalidSignedCookie if
+// the signature is invalid or the cookie is expired.
+func (ctx *Context) SignedCookie(name string) (string, error) {
+	keyring := ctx.engine.cookieKeyring
+	if keyring == nil {
+		panic("the cookie keyring of engine is not set, see Engine.CookieKeyring()")
+	}
+	cookie, err := ctx.Request.Cookie(name)
+	if err != nil {
+		return "", err
+	}
+	dot := strings.LastIndexByte(cookie.Value, '.')
+	if dot < 0 {
+		return "", ErrInvalidSignedCookie
+	}
+	payload := cookie.Value[:dot]
+	mac, err := base64.RawURLEncoding.DecodeString(cookie.Value[dot+1:])
+	if err != nil || !keyring.Verify(signedCookieData(name, payload), mac) {
+		return "", ErrInvalidSignedCookie
+	}
+	dot = strings.IndexByte(payload, '.')
+	if dot < 0 {
+		return "", ErrInvalidSignedCookie
+	}
+	expires, err := strconv.ParseInt(payload[:dot], 10, 64)
+	if err != nil || (expires > 0 && time.Now().Unix() >= expires) {
+		return "", ErrInvalidSignedCookie
+	}
+	value, err := base64.RawURLEncoding.DecodeString(payload[dot+1:])
+	if err != nil {
+		return "", ErrInvalidSignedCookie
+	}
+	return string(value), nil
+}
+
+// signedCookieData returns the data signed for the signed cookie, the name is included so
+// that the value of a cookie can not be used as another cookie.
+func signedCookieData(name, payload string) []byte {
+	return []byte(name + "=" + payload)
+}
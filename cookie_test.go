@@ -0,0 +1,149 @@
+// Copyright 2014 Manu Martinez-Almeida.  All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package gin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSetCookieValue(t *testing.T) {
+	engine := New()
+	engine.CookieDefaults(CookieDefaults{Domain: "example.com", Secure: true, SameSite: http.SameSiteStrictMode})
+	engine.Get("/set", func(ctx *Context) { ctx.SetCookieValue("a", "1", 60) })
+	engine.Get("/delete", func(ctx *Context) { ctx.DeleteCookie("a") })
+
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/set", nil))
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 {
+		t.Fatalf("got %d cookies, want 1", len(cookies))
+	}
+	c := cookies[0]
+	if c.Value != "1" || c.Path != "/" || c.Domain != "example.com" || c.MaxAge != 60 ||
+		!c.Secure || !c.HttpOnly || c.SameSite != http.SameSiteStrictMode {
+		t.Errorf("unexpected cookie %s", c)
+	}
+
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/delete", nil))
+	if c := w.Result().Cookies()[0]; c.MaxAge != -1 || c.Domain != "example.com" {
+		t.Errorf("unexpected deleting cookie %s", c)
+	}
+}
+
+func TestCookieDefaults(t *testing.T) {
+	tests := []struct {
+		defaults CookieDefaults
+		httpOnly bool
+		sameSite http.SameSite
+	}{
+		{CookieDefaults{Secure: true}, true, http.SameSiteLaxMode},
+		{CookieDefaults{DisableHttpOnly: true}, false, http.SameSiteLaxMode},
+		{CookieDefaults{SameSite: http.SameSiteNoneMode, Secure: true}, true, http.SameSiteNoneMode},
+	}
+	for _, tt := range tests {
+		engine := New()
+		engine.CookieDefaults(tt.defaults)
+		engine.Get("/", func(ctx *Context) { ctx.SetCookieValue("a", "1", 0) })
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		c := w.Result().Cookies()[0]
+		if c.Path != "/" || c.HttpOnly != tt.httpOnly || c.SameSite != tt.sameSite || c.Secure != tt.defaults.Secure {
+			t.Errorf("CookieDefaults(%+v): unexpected cookie %s", tt.defaults, c)
+		}
+	}
+}
+
+func TestSignedCookie(t *testing.T) {
+	keyring := NewKeyring([]byte("old-key"))
+	engine := New()
+	engine.CookieKeyring(keyring)
+	engine.Get("/set", func(ctx *Context) { ctx.SetSignedCookie("user", "bob; admin", 0) })
+	engine.Get("/set-other", func(ctx *Context) { ctx.SetSignedCookie("other", "alice", 0) })
+	engine.Get("/get", func(ctx *Context) {
+		value, err := ctx.SignedCookie("user")
+		if err != nil {
+			ctx.String(200, "%v", err)
+			return
+		}
+		ctx.String(200, "%s", value)
+	})
+	get := func(name, value string) string {
+		r := httptest.NewRequest(http.MethodGet, "/get", nil)
+		r.AddCookie(&http.Cookie{Name: name, Value: value})
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, r)
+		return w.Body.String()
+	}
+
+	set := func(path string) string {
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w.Result().Cookies()[0].Value
+	}
+	signed := set("/set")
+	other := set("/set-other")
+
+	if got := get("user", signed); got != "bob; admin" {
+		t.Errorf("got %q, want %q", got, "bob; admin")
+	}
+	keyring.Rotate([]byte("new-key"), 1)
+	if got := get("user", signed); got != "bob; admin" {
+		t.Errorf("after rotation got %q, want %q", got, "bob; admin")
+	}
+	keyring.Rotate([]byte("newer-key"), 0)
+	if got := get("user", signed); got != ErrInvalidSignedCookie.Error() {
+		t.Errorf("after removing the key got %q, want %q", got, ErrInvalidSignedCookie.Error())
+	}
+	if got := get("user", signed[:len(signed)-2]); got != ErrInvalidSignedCookie.Error() {
+		t.Errorf("tampered cookie got %q", got)
+	}
+	// the value signed for another cookie name
+	if got := get("user", other); got != ErrInvalidSignedCookie.Error() {
+		t.Errorf("the value of cookie 'other' got %q, want %q", got, ErrInvalidSignedCookie.Error())
+	}
+	if got := get("missing", signed); got != http.ErrNoCookie.Error() {
+		t.Errorf("missing cookie got %q", got)
+	}
+}
+
+func TestSignedCookieExpires(t *testing.T) {
+	engine := New()
+	engine.CookieKeyring(NewKeyring([]byte("key")))
+	engine.Get("/set", func(ctx *Context) { ctx.SetSignedCookie("user", "bob", 1) })
+	engine.Get("/get", func(ctx *Context) {
+		value, err := ctx.SignedCookie("user")
+		if err != nil {
+			ctx.String(200, "%v", err)
+			return
+		}
+		ctx.String(200, "%s", value)
+	})
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/set", nil))
+	c := w.Result().Cookies()[0]
+	if c.MaxAge != 1 {
+		t.Fatalf("got Max-Age %d, want 1", c.MaxAge)
+	}
+	get := func() string {
+		r := httptest.NewRequest(http.MethodGet, "/get", nil)
+		r.AddCookie(&http.Cookie{Name: "user", Value: c.Value})
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, r)
+		return w.Body.String()
+	}
+
+	if got := get(); got != "bob" {
+		t.Errorf("got %q before it is expired, want %q", got, "bob")
+	}
+	// the client keeps sending the cookie after Max-Age
+	time.Sleep(time.Until(time.Unix(time.Now().Unix()+1, 0)))
+	if got := get(); got != ErrInvalidSignedCookie.Error() {
+		t.Errorf("got %q after it is expired, want %q", got, ErrInvalidSignedCookie.Error())
+	}
+}
@@ -20,7 +20,7 @@ func (engine *Engine) DebugPProf(middleware ...HandlerFunc) {
 	engine.startedChecker.check() // check if engine has been started.
 	handlers := combineHandlerChain(middleware, HandlerChain{debugPProfHandler})
 	for _, method := range __httpMethods {
-		engine.addRoute(method, "/debug/pprof/*name", handlers)
+		engine.addRoute(nil, method, "/debug/pprof/*name", handlers)
 	}
 }
 
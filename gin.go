@@ -5,12 +5,12 @@
 package gin
 
 import (
+	"net"
 	"net/http"
 	"sync"
+	"unsafe"
 )
 
-const __maxHandlerChainSize = 64
-
 type (
 	HandlerChain []HandlerFunc
 	HandlerFunc  func(*Context)
@@ -59,6 +59,23 @@ type Engine struct {
 	trees       trees // point treesBuffer
 	treesBuffer [len(__httpMethods)]tree
 
+	pre    HandlerChain // see Engine.Pre()
+	allPre HandlerChain // always == combineHandlerChain(pre, routeHandler) if pre is not empty, otherwise is nil.
+
+	hosts  []*hostRoutes   // see Engine.Host()
+	mounts []mountedEngine // see RouteGroup.Mount()
+
+	namedRoutes map[string]*routeInfo // route name --> route
+
+	// If enabled, the routes can be changed while serving requests, see Engine.HotSwapRoutes().
+	hotSwapRoutes bool
+	hotSwapLock   sync.Mutex     // serializes the changes of routes in hot-swap mode
+	routingTable  unsafe.Pointer // *routingTable, it replaces trees once the routes are swapped
+
+	// maxParams is the max number of path parameters of all routes,
+	// Context.pathParamsBuffer is preallocated with this capacity.
+	maxParams uint8
+
 	// Enables automatic redirection if the current route can't be matched but a
 	// handler for the path with (without) the trailing slash exists.
 	// For example if /foo/ is requested but a route only exists for /foo, the
@@ -77,6 +94,9 @@ type Engine struct {
 	// redirectTrailingSlash is independent of this option.
 	redirectFixedPath bool
 
+	// The policy of the requests redirected by redirectTrailingSlash and redirectFixedPath.
+	redirectPolicy RedirectPolicy
+
 	// If enabled, the router checks if another method is allowed for the
 	// current route, if the current request can not be routed.
 	// If this is the case, the request is answered with 'Method Not Allowed'
@@ -85,8 +105,28 @@ type Engine struct {
 	// handler.
 	handleMethodNotAllowed bool
 
-	// If enabled, the engine get client IP from http hearder X-Real-IP, X-Forwarded-For.
+	// If enabled, the engine get client IP, scheme and host from the forwarding headers
+	// of the requests which come from the trusted proxies.
 	fetchClientIPFromHeader bool
+
+	// The proxies whose forwarding headers are trusted, nil means only the loopback addresses are trusted.
+	trustedProxies []*net.IPNet
+
+	// The headers to get the client IP from, in order of precedence, nil means __defaultRemoteIPHeaders.
+	remoteIPHeaders []string
+
+	// If enabled, the router matches the escaped path (URL.EscapedPath()) instead of URL.Path,
+	// and the values of path parameters are unescaped individually.
+	useRawPath bool
+
+	// The policy of the request paths with dot segments ('.' and '..').
+	dotSegments DotSegmentsPolicy
+
+	// The attributes of the cookies set by Context.SetCookieValue() and Context.SetSignedCookie().
+	cookieDefaults CookieDefaults
+
+	// The keyring to sign the cookies of Context.SetSignedCookie().
+	cookieKeyring *Keyring
 }
 
 // New returns a new blank Engine instance without any middleware attached.
@@ -97,25 +137,36 @@ type Engine struct {
 func New() *Engine {
 	debugPrintEngineNew()
 	engine := &Engine{
-		redirectTrailingSlash:   true,
-		redirectFixedPath:       false,
+		redirectTrailingSlash: true,
+		redirectFixedPath:     false,
+		redirectPolicy: RedirectPolicy{
+			GetCode: http.StatusMovedPermanently,
+			Code:    http.StatusTemporaryRedirect,
+		},
 		handleMethodNotAllowed:  false,
 		fetchClientIPFromHeader: false,
+		cookieDefaults: CookieDefaults{
+			Path:     "/",
+			SameSite: http.SameSiteLaxMode,
+		},
 	}
 	engine.RouteGroup.basePath = "/"
 	engine.RouteGroup.engine = engine
-	engine.contextPool.New = contextPoolNew
+	engine.contextPool.New = engine.newContext
 	engine.trees = engine.treesBuffer[:0]
 	return engine
 }
 
-func contextPoolNew() interface{} {
+func (engine *Engine) newContext() interface{} {
 	var ctx Context
+	ctx.engine = engine
+	ctx.pathParamsBuffer = make(Params, 0, engine.maxParams)
 	ctx.reset()
 	return &ctx
 }
 
-func (engine *Engine) addRoute(method, path string, handlers HandlerChain) {
+// addRoute adds the route to the trees of host, or to the trees of engine if host is nil.
+func (engine *Engine) addRoute(host *hostRoutes, method, path string, handlers HandlerChain) *routeInfo {
 	if method == "" {
 		panic("http method can not be empty")
 	}
@@ -132,20 +183,67 @@ func (engine *Engine) addRoute(method, path string, handlers HandlerChain) {
 	}
 
 	engine.startedChecker.check() // check if engine has been started.
-	debugPrintRoute(method, path, handlers)
+	if host == nil && engine.hotSwapRoutes && engine.loadRoutingTable() != nil {
+		debugPrintRoute(method, path, handlers)
+		return engine.addTableRoute(method, path, handlers)
+	}
+	trees, hostPattern, numHostParams := &engine.trees, "", 0
+	if host != nil {
+		trees, hostPattern, numHostParams = &host.trees, host.pattern, host.numParams
+	}
+	debugPrintRoute(method, hostPattern+path, handlers)
 
-	root := engine.trees.getTree(method)
+	root := trees.getTree(method)
 	if root == nil {
 		root = new(node)
-		engine.trees.addTree(method, root)
+		trees.addTree(method, root)
+	}
+	leaf := root.addRouteMethod(method, path, handlers)
+	if maxParams := int(root.maxParams) + numHostParams; maxParams > int(engine.maxParams) {
+		if maxParams > 255 {
+			maxParams = 255
+		}
+		engine.maxParams = uint8(maxParams)
+		if engine.hotSwapRoutes {
+			engine.updateTableMaxParams()
+		}
+	}
+	leaf.route = &routeInfo{
+		method:  method,
+		host:    hostPattern,
+		path:    path,
+		handler: nameOfFunction(handlers.last()),
 	}
-	root.addRoute(path, handlers)
+	return leaf.route
 }
 
 // Routes returns a slice of registered routes, including some useful information, such as:
 // the http method, path and the handler name.
 func (engine *Engine) Routes() []Route {
-	return engine.trees.routes()
+	trees, _ := engine.routingTrees()
+	routes := trees.routes()
+	for _, host := range engine.hosts {
+		routes = append(routes, host.trees.routes()...)
+	}
+	for _, mount := range engine.mounts {
+		for _, route := range mount.engine.Routes() {
+			if mount.prefix != "/" {
+				route.Path = mount.prefix + route.Path
+			}
+			if route.Host == "" {
+				route.Host = mount.host
+			}
+			routes = append(routes, route)
+		}
+	}
+	return routes
+}
+
+// mountedEngine is the Engine mounted by RouteGroup.Mount().
+type mountedEngine struct {
+	host   string // host pattern of the RouteGroup
+	prefix string
+	engine *Engine
 }
 
 // Attachs a global middleware to Engine. ie. the middleware attached though Use() will be
@@ -163,6 +261,56 @@ func (engine *Engine) Use(middleware ...HandlerFunc) {
 	engine.rebuild405Handlers()
 }
 
+// PreRouting adds the hooks which are called with the request in ServeHTTP before the route is looked up.
+// The hooks can rewrite the request, e.g. Request.Method and Request.URL.Path, to change the route to be matched,
+// see middleware.MethodOverride() for example.
+//
+// It is a shortcut for adding the hooks as the Pre middlewares, they are called in the order
+// the hooks and the Pre middlewares are added.
+func (engine *Engine) PreRouting(hooks ...func(r *http.Request)) {
+	middleware := make(HandlerChain, len(hooks))
+	for i, hook := range hooks {
+		if hook == nil {
+			panic("each hook can not be nil")
+		}
+		hook := hook
+		middleware[i] = func(ctx *Context) { hook(ctx.Request) }
+	}
+	engine.Pre(middleware...)
+}
+
+// Pre adds the middlewares which are called in ServeHTTP before the route is looked up.
+// The route is looked up with Context.Request when the last of them calls Context.Next(),
+// so they can rewrite the request (e.g. strip the path prefix or normalise the host),
+// or call Context.Abort() to reject the request without routing.
+//
+// Unlike the middlewares added by Use(), they are called for every request, including the
+// requests which are redirected or answered with 404 and 405. Context.Route() and Context.PathParams
+// are not available before calling Context.Next().
+// They are called in the order they are added, including the hooks added by PreRouting().
+func (engine *Engine) Pre(middleware ...HandlerFunc) {
+	for _, h := range middleware {
+		if h == nil {
+			panic("each middleware can not be nil")
+		}
+	}
+	engine.startedChecker.check() // check if engine has been started.
+	engine.pre = combineHandlerChain(engine.pre, middleware)
+	if len(engine.pre) == 0 {
+		engine.allPre = nil
+		return
+	}
+	engine.allPre = combineHandlerChain(engine.pre, HandlerChain{engine.routeHandler})
+}
+
+// routeHandler is the last handler of the Pre middlewares, it routes the request.
+func (engine *Engine) routeHandler(ctx *Context) {
+	// the handlers of route replace the Pre middlewares, no handler is called if the request is redirected
+	ctx.handlers = nil
+	ctx.handlerIndex = __initHandlerIndex
+	engine.serveHTTP(ctx)
+}
+
 // NoRoute set handlers for NoRoute. It return a 404 code by default.
 // Engine.NoRoute() removes all no-route handlers.
 func (engine *Engine) NoRoute(handlers ...HandlerFunc) {
@@ -209,7 +357,7 @@ func (engine *Engine) rebuild405Handlers() {
 // handler for the path with (without) the trailing slash exists.
 // For example if /foo/ is requested but a route only exists for /foo, the
 // client is redirected to /foo with http status code 301 for GET requests
-// and 307 for all other request methods.
+// and 307 for all other request methods, see RedirectPolicy().
 //
 // Default is true.
 func (engine *Engine) RedirectTrailingSlash(b bool) {
@@ -223,7 +371,7 @@ func (engine *Engine) RedirectTrailingSlash(b bool) {
 // Afterwards the router does a case-insensitive lookup of the cleaned path.
 // If a handle can be found for this route, the router makes a redirection
 // to the corrected path with status code 301 for GET requests and 307 for
-// all other request methods.
+// all other request methods, see RedirectPolicy().
 // For example /FOO and /..//Foo could be redirected to /foo.
 // RedirectTrailingSlash is independent of this option.
 //
@@ -246,7 +394,14 @@ func (engine *Engine) HandleMethodNotAllowed(b bool) {
 	engine.handleMethodNotAllowed = b
 }
 
-// If enabled, the engine get client IP from http hearder X-Real-IP, X-Forwarded-For.
+// If enabled, the engine get client IP from the headers set by Engine.RemoteIPHeaders(),
+// and Context.Scheme(), Context.Host() get the scheme and host from the Forwarded,
+// X-Forwarded-Proto and X-Forwarded-Host headers.
+// The headers are used only if the request comes from a trusted proxy, see Engine.TrustedProxies(),
+// only the loopback addresses are trusted if TrustedProxies() is not called.
+//
+// NOTE: X-Forwarded-For and Forwarded take precedence over X-Real-IP by default, and the client IP
+// is the first untrusted address of them from right to left instead of the leftmost address.
 //
 // Default is false.
 func (engine *Engine) FetchClientIPFromHeader(b bool) {
@@ -296,10 +451,20 @@ func (engine *Engine) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	ctx.responseWriter2 = ctx.responseWriterCache.ResponseWriter2(w)
 	ctx.ResponseWriter = ctx.responseWriter2
 	ctx.Request = r
+	if _, maxParams := engine.routingTrees(); cap(ctx.pathParamsBuffer) < int(maxParams) {
+		ctx.pathParamsBuffer = make(Params, 0, maxParams) // the context was created before the routes were added
+	}
 	ctx.PathParams = ctx.pathParamsBuffer[:0]
 	ctx.Validator = engine.defaultValidator
-	ctx.fetchClientIPFromHeader = engine.fetchClientIPFromHeader
-	engine.serveHTTP(ctx)
+	if engine.allPre != nil {
+		ctx.handlers = engine.allPre
+		ctx.Next()
+	} else {
+		engine.serveHTTP(ctx)
+	}
+	if ctx.beforeWriteHeader && !ctx.responseWriter2.WroteHeader() && !ctx.responseWriter2.Hijacked() {
+		ctx.responseWriter2.WriteHeader(http.StatusOK) // calls the functions of Context.BeforeWriteHeader()
+	}
 
 	ctx.reset()
 	engine.contextPool.Put(ctx)
@@ -307,40 +472,70 @@ func (engine *Engine) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 func (engine *Engine) serveHTTP(ctx *Context) {
 	httpMethod := ctx.Request.Method
+	if engine.dotSegments != DotSegmentsAllow && hasDotSegment(ctx.Request.URL.Path) {
+		if engine.dotSegments == DotSegmentsReject {
+			ctx.handlers = nil
+			serveError(ctx, 400, __default400Body)
+			return
+		}
+		cleanRequestPath(ctx.Request)
+	}
 	path := ctx.Request.URL.Path
+	if engine.useRawPath {
+		path = ctx.Request.URL.EscapedPath()
+	}
+
+	// find the trees for the host of request, the host params are the first params
+	trees, _ := engine.routingTrees()
+	if len(engine.hosts) > 0 {
+		if host, params := engine.matchHost(ctx.Request.Host, ctx.PathParams[:0]); host != nil {
+			trees = host.trees
+			ctx.PathParams = params
+		}
+	}
+	hostParams := ctx.PathParams
 
 	// find root of the tree for the given HTTP method
-	root := engine.trees.getTree(httpMethod)
+	root := trees.getTree(httpMethod)
 	if root != nil {
 		// find route in tree
-		handlers, params, tsr := root.getValue(path, ctx.PathParams[:0])
-		if handlers != nil {
-			ctx.handlers = handlers
-			ctx.PathParams = params
+		leaf, params, tsr := root.getNode(path, hostParams[len(hostParams):])
+		if leaf != nil {
+			if engine.useRawPath {
+				unescapeParams(params)
+			}
+			ctx.handlers = leaf.handlers
+			ctx.PathParams = append(hostParams, params...)
+			ctx.route = leaf.route
 			ctx.Next()
 			return
 		}
 		if httpMethod != http.MethodConnect && path != "/" {
 			if tsr && engine.redirectTrailingSlash {
-				redirectTrailingSlash(ctx)
+				engine.redirect(ctx, toggleTrailingSlash(path))
 				return
 			}
-			if engine.redirectFixedPath && redirectFixedPath(ctx, root, engine.redirectTrailingSlash) {
-				return
+			if engine.redirectFixedPath {
+				if fixedPath, found := root.findCaseInsensitivePath(pathClean(path), engine.redirectTrailingSlash); found {
+					engine.redirect(ctx, string(fixedPath))
+					return
+				}
 			}
 		}
 	}
 
 	// Handle 405
 	if engine.handleMethodNotAllowed {
-		trees := engine.trees
 		for i := 0; i < len(trees); i++ {
 			if trees[i].method == httpMethod {
 				continue // Skip the requested method - we already tried this one
 			}
-			if handlers, params, _ := trees[i].root.getValue(path, ctx.PathParams[:0]); handlers != nil {
+			if handlers, params, _ := trees[i].root.getValue(path, hostParams[len(hostParams):]); handlers != nil {
+				if engine.useRawPath {
+					unescapeParams(params)
+				}
 				ctx.handlers = engine.allNoMethod
-				ctx.PathParams = params
+				ctx.PathParams = append(hostParams, params...)
 				serveError(ctx, 405, __default405Body)
 				return
 			}
@@ -353,6 +548,7 @@ func (engine *Engine) serveHTTP(ctx *Context) {
 }
 
 var (
+	__default400Body = []byte("400 bad request")
 	__default404Body = []byte("404 page not found")
 	__default405Body = []byte("405 method not allowed")
 )
@@ -366,40 +562,3 @@ func serveError(ctx *Context, defaultCode int, defaultMessage []byte) {
 		w.Write(defaultMessage)
 	}
 }
-
-func redirectTrailingSlash(ctx *Context) {
-	req := ctx.Request
-	path := req.URL.Path // path != "/"
-
-	code := 301
-	if req.Method != http.MethodGet {
-		code = 307
-	}
-
-	if len(path) > 1 && path[len(path)-1] == '/' {
-		req.URL.Path = path[:len(path)-1]
-	} else {
-		req.URL.Path = path + "/"
-	}
-
-	debugPrintf("redirecting request %d: %s --> %s\r\n", code, path, req.URL.Path)
-	http.Redirect(ctx.ResponseWriter, req, req.URL.String(), code)
-}
-
-func redirectFixedPath(ctx *Context, root *node, fixTrailingSlash bool) bool {
-	req := ctx.Request
-	path := req.URL.Path // path != "/"
-
-	fixedPath, found := root.findCaseInsensitivePath(pathClean(path), fixTrailingSlash)
-	if found {
-		code := 301
-		if req.Method != http.MethodGet {
-			code = 307
-		}
-		req.URL.Path = string(fixedPath)
-		debugPrintf("redirecting request %d: %s --> %s\r\n", code, path, req.URL.Path)
-		http.Redirect(ctx.ResponseWriter, req, req.URL.String(), code)
-		return true
-	}
-	return false
-}
@@ -0,0 +1,248 @@
+// Copyright 2014 Manu Martinez-Almeida.  All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package gin
+
+import (
+	"bufio"
+	"bytes"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestEnginePre(t *testing.T) {
+	var trace []string
+	engine := New()
+	engine.RedirectFixedPath(true)
+	engine.Use(func(ctx *Context) {
+		trace = append(trace, "use")
+		ctx.Next()
+	})
+	engine.Pre(func(ctx *Context) {
+		trace = append(trace, "pre1")
+		if ctx.Request.Header.Get("X-Reject") != "" {
+			ctx.AbortWithStatus(http.StatusForbidden)
+			return
+		}
+		ctx.Request.URL.Path = strings.TrimPrefix(ctx.Request.URL.Path, "/api")
+		ctx.Next()
+		route, _ := ctx.Route()
+		trace = append(trace, "pre1 after "+route.Path)
+	}, func(ctx *Context) {
+		trace = append(trace, "pre2")
+	})
+	engine.Get("/users/:id", func(ctx *Context) {
+		trace = append(trace, "handler "+ctx.Param("id"))
+	})
+
+	tests := [...]struct {
+		path     string
+		reject   bool
+		code     int
+		trace    string
+		location string
+	}{
+		{"/api/users/12", false, 200, "pre1,pre2,use,handler 12,pre1 after /users/:id", ""},
+		{"/users/12", false, 200, "pre1,pre2,use,handler 12,pre1 after /users/:id", ""},
+		{"/api/users/12", true, 403, "pre1", ""},
+		{"/api/unknown", false, 404, "pre1,pre2,pre1 after ", ""},
+		// the redirection keeps the prefix stripped by the Pre middleware
+		{"/api/users/12/", false, 301, "pre1,pre2,pre1 after ", "/api/users/12"},
+		{"/api/users/12/?a=1", false, 301, "pre1,pre2,pre1 after ", "/api/users/12?a=1"},
+		{"/users/12/", false, 301, "pre1,pre2,pre1 after ", "/users/12"},
+		{"/api/USERS/12", false, 301, "pre1,pre2,pre1 after ", "/api/users/12"},
+	}
+	for _, tt := range tests {
+		trace = nil
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		if tt.reject {
+			r.Header.Set("X-Reject", "1")
+		}
+		engine.ServeHTTP(w, r)
+		if got := strings.Join(trace, ","); w.Code != tt.code || got != tt.trace {
+			t.Errorf("GET %s: got %d %q, want %d %q", tt.path, w.Code, got, tt.code, tt.trace)
+		}
+		if location := w.Header().Get(HeaderLocation); location != tt.location {
+			t.Errorf("GET %s: got Location %q, want %q", tt.path, location, tt.location)
+		}
+	}
+}
+
+func TestEnginePreRouting(t *testing.T) {
+	var trace []string
+	engine := New()
+	engine.Pre(func(ctx *Context) { trace = append(trace, "pre1") })
+	engine.PreRouting(func(r *http.Request) {
+		trace = append(trace, "hook")
+		r.Method = http.MethodPut
+	})
+	engine.Pre(func(ctx *Context) { trace = append(trace, "pre2 "+ctx.Request.Method) })
+	engine.Put("/", func(ctx *Context) { trace = append(trace, "handler") })
+
+	engine.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/", nil))
+	// the hooks and the Pre middlewares are called in the order they are added
+	if got := strings.Join(trace, ","); got != "pre1,hook,pre2 PUT,handler" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestEngineRedirectPolicy(t *testing.T) {
+	newEngine := func(policy RedirectPolicy) (*Engine, *[]string) {
+		var logs []string
+		engine := New()
+		engine.RedirectFixedPath(true)
+		engine.RedirectPolicy(policy)
+		engine.TrustedProxies("192.0.2.0/24")
+		engine.Use(func(ctx *Context) {
+			ctx.Next()
+			logs = append(logs, ctx.Request.URL.Path)
+		})
+		engine.Get("/users/:id", func(ctx *Context) { ctx.String(200, "user %s", ctx.Param("id")) })
+		engine.Post("/users", func(ctx *Context) { ctx.String(201, "created") })
+		return engine, &logs
+	}
+
+	tests := [...]struct {
+		policy   RedirectPolicy
+		method   string
+		path     string
+		prefix   string
+		remote   string
+		code     int
+		location string
+		body     string
+		logs     string
+	}{
+		{RedirectPolicy{}, "GET", "/users/12/?a=1", "", "", 301, "/users/12?a=1", "", ""},
+		{RedirectPolicy{}, "POST", "/users/", "", "", 307, "/users", "", ""},
+		{RedirectPolicy{Code: 308}, "POST", "/USERS", "", "", 308, "/users", "", ""},
+		{RedirectPolicy{ForwardedPrefix: true}, "GET", "/users/12/", "/api/", "", 301, "/api/users/12", "", ""},
+		{RedirectPolicy{ForwardedPrefix: true}, "GET", "/users/12/", "//evil.com", "", 301, "/users/12", "", ""},
+		{RedirectPolicy{ForwardedPrefix: true}, "GET", "/users/12/", "/api/", "203.0.113.5:1234", 301, "/users/12", "", ""}, // untrusted proxy
+		{RedirectPolicy{UseMiddleware: true}, "GET", "/Users/12", "", "", 301, "/users/12", "", "/Users/12"},
+		{RedirectPolicy{Rewrite: true}, "GET", "/users/12/", "", "", 200, "", "user 12", "/users/12"},
+	}
+	for _, tt := range tests {
+		engine, logs := newEngine(tt.policy)
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(tt.method, tt.path, nil)
+		if tt.prefix != "" {
+			r.Header.Set(HeaderXForwardedPrefix, tt.prefix)
+		}
+		if tt.remote != "" {
+			r.RemoteAddr = tt.remote
+		}
+		engine.ServeHTTP(w, r)
+		if w.Code != tt.code || w.Header().Get(HeaderLocation) != tt.location {
+			t.Errorf("%+v %s %s: got %d %q, want %d %q", tt.policy, tt.method, tt.path, w.Code, w.Header().Get(HeaderLocation), tt.code, tt.location)
+		}
+		if tt.body != "" && w.Body.String() != tt.body {
+			t.Errorf("%+v %s %s: got body %q, want %q", tt.policy, tt.method, tt.path, w.Body.String(), tt.body)
+		}
+		if got := strings.Join(*logs, ","); got != tt.logs {
+			t.Errorf("%+v %s %s: got logs %q, want %q", tt.policy, tt.method, tt.path, got, tt.logs)
+		}
+	}
+}
+
+func TestEngineRawPath(t *testing.T) {
+	for _, useRawPath := range [...]bool{false, true} {
+		for _, policy := range [...]DotSegmentsPolicy{DotSegmentsAllow, DotSegmentsClean, DotSegmentsReject} {
+			engine := New()
+			engine.UseRawPath(useRawPath)
+			engine.DotSegments(policy)
+			engine.Get("/files/:name", func(ctx *Context) { ctx.String(200, "file %s", ctx.Param("name")) })
+			engine.Get("/files/:name/:sub", func(ctx *Context) { ctx.String(200, "file %s sub %s", ctx.Param("name"), ctx.Param("sub")) })
+			engine.Get("/static/*path", func(ctx *Context) { ctx.String(200, "static %s", ctx.Param("path")) })
+
+			tests := [...]struct {
+				path string
+				code int
+				body string
+			}{
+				{"/files/a%2Fb", 200, map[bool]string{false: "file a sub b", true: "file a/b"}[useRawPath]},
+				{"/files/a%20b", 200, "file a b"},
+				{"/static/a%2Fb/c", 200, "static /a/b/c"},
+				{"/files/x/../a", map[DotSegmentsPolicy]int{DotSegmentsAllow: 404, DotSegmentsClean: 200, DotSegmentsReject: 400}[policy],
+					map[DotSegmentsPolicy]string{DotSegmentsAllow: "404 page not found", DotSegmentsClean: "file a", DotSegmentsReject: "400 bad request"}[policy]},
+				{"/static/%2e%2e/secret", map[DotSegmentsPolicy]int{DotSegmentsAllow: 200, DotSegmentsClean: 404, DotSegmentsReject: 400}[policy],
+					map[DotSegmentsPolicy]string{DotSegmentsAllow: "static /../secret", DotSegmentsClean: "404 page not found", DotSegmentsReject: "400 bad request"}[policy]},
+			}
+			for _, tt := range tests {
+				w := httptest.NewRecorder()
+				engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+				if w.Code != tt.code || w.Body.String() != tt.body {
+					t.Errorf("raw path %t, policy %d, GET %s: got %d %q, want %d %q", useRawPath, policy, tt.path, w.Code, w.Body.String(), tt.code, tt.body)
+				}
+			}
+		}
+	}
+}
+
+func TestContextBeforeWriteHeader(t *testing.T) {
+	engine := New()
+	engine.Use(func(ctx *Context) {
+		ctx.BeforeWriteHeader(func() { ctx.ResponseWriter.Header().Add("X-Trace", "outer") })
+		ctx.Next()
+	})
+	engine.Get("/write", func(ctx *Context) {
+		ctx.BeforeWriteHeader(func() { ctx.ResponseWriter.Header().Add("X-Trace", "inner") })
+		ctx.String(http.StatusCreated, "ok")
+		ctx.ResponseWriter.Header().Add("X-Trace", "late") // too late, the header has been written
+	})
+	engine.Get("/empty", func(ctx *Context) {})
+
+	tests := [...]struct {
+		path  string
+		code  int
+		trace string
+	}{
+		{"/write", http.StatusCreated, "inner,outer"},
+		{"/empty", http.StatusOK, "outer"},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if trace := strings.Join(w.Result().Header["X-Trace"], ","); w.Code != tt.code || trace != tt.trace {
+			t.Errorf("%s: got %d %q, want %d %q", tt.path, w.Code, trace, tt.code, tt.trace)
+		}
+	}
+}
+
+type hijackRecorder struct {
+	*httptest.ResponseRecorder
+}
+
+func (w hijackRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, peer := net.Pipe()
+	peer.Close()
+	return conn, bufio.NewReadWriter(bufio.NewReader(conn), bufio.NewWriter(conn)), nil
+}
+
+func TestContextBeforeWriteHeaderHijacked(t *testing.T) {
+	called := false
+	engine := New()
+	engine.Get("/ws", func(ctx *Context) {
+		ctx.BeforeWriteHeader(func() { called = true })
+		conn, _, err := ctx.ResponseWriter.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn.Close()
+	})
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+	w := hijackRecorder{httptest.NewRecorder()}
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/ws", nil))
+	if called || w.Flushed || buf.Len() > 0 {
+		t.Errorf("the header is written after the connection is hijacked: called %t, log %q", called, buf.String())
+	}
+}
@@ -0,0 +1,186 @@
+// Copyright 2014 Manu Martinez-Almeida.  All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package gin
+
+import (
+	"strings"
+)
+
+// hostRoutes holds the routes registered for a host pattern, see Engine.Host().
+type hostRoutes struct {
+	pattern   string
+	labels    []hostLabel
+	numParams int
+	wildcard  bool // has param or wildcard label
+	trees     trees
+}
+
+// hostLabel is a dot-separated label of the host pattern.
+type hostLabel struct {
+	value      string           // static label in lower case, or the wildcard, e.g. ':tenant', ':tenant<alnum>' or '*'
+	constraint *paramConstraint // constraint of the param label, nil if none
+}
+
+// Host creates a new RouteGroup whose routes are matched only if the host of request matches pattern.
+// The routes registered in the returned RouteGroup are stored in the trees of the host, separated from
+// the routes registered in Engine.
+//
+// The pattern is a host name without port, each dot-separated label of it can be:
+//     api          static label, it is matched case-insensitively
+//     :tenant      param label, the value of it is added to Context.PathParams before the path parameters
+//     :id<int>     param label with constraint, see RegisterParamConstraint()
+//     *            wildcard label, it matches any single label without capturing
+// for example:
+//     api := engine.Host("api.example.com")
+//     api.Get("/users", listUsers)
+//     tenant := engine.Host(":tenant.example.com")
+//     tenant.Get("/", func(ctx *gin.Context) { ctx.String(200, ctx.Param("tenant")) })
+//
+// The static hosts are matched before the hosts with wildcard, which are matched in the registration order.
+// If no host matches the request, the request is routed in the trees of Engine.
+// If a host matches the request, the request is routed only in the trees of the host,
+// and the NoRoute (NoMethod) handlers of Engine are called if no route matches.
+//
+// The same pattern returns the RouteGroups sharing the same trees.
+func (engine *Engine) Host(pattern string, middlewares ...HandlerFunc) *RouteGroup {
+	engine.startedChecker.check() // check if engine has been started.
+	host := engine.lookupHost(pattern)
+	if host == nil {
+		host = newHostRoutes(pattern)
+		engine.hosts = append(engine.hosts, host)
+	}
+	return &RouteGroup{
+		basePath:    "/",
+		middlewares: combineHandlerChain(engine.middlewares, middlewares),
+		engine:      engine,
+		host:        host,
+	}
+}
+
+func (engine *Engine) lookupHost(pattern string) *hostRoutes {
+	for _, host := range engine.hosts {
+		if strings.EqualFold(host.pattern, pattern) {
+			return host
+		}
+	}
+	return nil
+}
+
+func newHostRoutes(pattern string) *hostRoutes {
+	if pattern == "" {
+		panic("host pattern can not be empty")
+	}
+	if strings.ContainsRune(pattern, '/') {
+		panic("host pattern '" + pattern + "' can not contain '/'")
+	}
+	host := &hostRoutes{pattern: pattern}
+	for _, label := range strings.Split(strings.TrimSuffix(pattern, "."), ".") {
+		switch {
+		case label == "":
+			panic("host pattern '" + pattern + "' has empty label")
+		case label[0] == ':':
+			if name, _ := splitWildcard(label); name == "" {
+				panic("wildcards must be named with a non-empty name in host pattern '" + pattern + "'")
+			}
+			host.labels = append(host.labels, hostLabel{value: label, constraint: newParamConstraint(label)})
+			host.numParams++
+			host.wildcard = true
+		case label == "*":
+			host.labels = append(host.labels, hostLabel{value: label})
+			host.wildcard = true
+		case strings.ContainsAny(label, ":*"):
+			panic("only one wildcard per label is allowed, has: '" + label + "' in host pattern '" + pattern + "'")
+		default:
+			host.labels = append(host.labels, hostLabel{value: strings.ToLower(label)})
+		}
+	}
+	return host
+}
+
+// match reports whether the host name (without port) matches the host pattern,
+// the values of the param labels are appended to ps.
+func (host *hostRoutes) match(name string, ps Params) (Params, bool) {
+	numParams := len(ps)
+	for i, label := range host.labels {
+		var value string
+		if i == len(host.labels)-1 {
+			value, name = name, ""
+		} else {
+			dot := strings.IndexByte(name, '.')
+			if dot < 0 {
+				return ps[:numParams], false
+			}
+			value, name = name[:dot], name[dot+1:]
+		}
+		if value == "" {
+			return ps[:numParams], false
+		}
+
+		switch {
+		case label.value == "*":
+		case label.value[0] == ':':
+			key := label.value[1:]
+			if label.constraint != nil {
+				if !label.constraint.match(value) {
+					return ps[:numParams], false
+				}
+				key = label.constraint.key
+			}
+			ps = append(ps, Param{Key: key, Value: value})
+		default:
+			if !strings.EqualFold(label.value, value) {
+				return ps[:numParams], false
+			}
+		}
+	}
+	return ps, true
+}
+
+// matchHost returns the hostRoutes matching the host of request, the values of
+// the host params are appended to ps. It returns nil if no host matches.
+func (engine *Engine) matchHost(requestHost string, ps Params) (*hostRoutes, Params) {
+	name := stripHostPort(requestHost)
+	if n := len(name); n > 0 && name[n-1] == '.' {
+		name = name[:n-1] // fully qualified domain name
+	}
+	if name == "" {
+		return nil, ps
+	}
+
+	// static hosts first
+	for _, host := range engine.hosts {
+		if !host.wildcard {
+			if _, ok := host.match(name, ps); ok {
+				return host, ps
+			}
+		}
+	}
+	for _, host := range engine.hosts {
+		if host.wildcard {
+			if params, ok := host.match(name, ps); ok {
+				return host, params
+			}
+		}
+	}
+	return nil, ps
+}
+
+// stripHostPort returns h without any trailing ":<port>", IPv6 literal is returned without the brackets.
+func stripHostPort(h string) string {
+	// If no port on host, return unchanged
+	if !strings.Contains(h, ":") {
+		return h
+	}
+	if h[0] == '[' {
+		if end := strings.IndexByte(h, ']'); end > 0 {
+			return h[1:end]
+		}
+		return h
+	}
+	if strings.Count(h, ":") > 1 {
+		return h // IPv6 literal without port
+	}
+	return h[:strings.IndexByte(h, ':')]
+}
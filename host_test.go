@@ -0,0 +1,124 @@
+// Copyright 2014 Manu Martinez-Almeida.  All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package gin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestMatchHost(t *testing.T) {
+	engine := New()
+	for _, pattern := range [...]string{
+		":tenant.example.com",
+		"api.example.com",
+		"*.static.example.com",
+		":id<int>.users.example.com",
+		":region.:tenant.cloud.example.com",
+	} {
+		engine.Host(pattern)
+	}
+
+	tests := []struct {
+		host    string
+		pattern string // empty if no host matches
+		ps      Params
+	}{
+		{"api.example.com", "api.example.com", Params{}},
+		{"API.Example.com:8080", "api.example.com", Params{}},
+		{"api.example.com.", "api.example.com", Params{}},
+		{"acme.example.com", ":tenant.example.com", Params{Param{"tenant", "acme"}}},
+		{"acme.example.com:443", ":tenant.example.com", Params{Param{"tenant", "acme"}}},
+		{"cdn.static.example.com", "*.static.example.com", Params{}},
+		{"12.users.example.com", ":id<int>.users.example.com", Params{Param{"id", "12"}}},
+		{"abc.users.example.com", "", Params{}},
+		{"eu.acme.cloud.example.com", ":region.:tenant.cloud.example.com", Params{Param{"region", "eu"}, Param{"tenant", "acme"}}},
+		{"example.com", "", Params{}},
+		{".example.com", "", Params{}},
+		{"a.b.example.com", "", Params{}},
+		{"localhost:8080", "", Params{}},
+		{"[::1]:8080", "", Params{}},
+		{"", "", Params{}},
+	}
+	for _, tt := range tests {
+		host, ps := engine.matchHost(tt.host, make(Params, 0, 2))
+		pattern := ""
+		if host != nil {
+			pattern = host.pattern
+		}
+		if pattern != tt.pattern {
+			t.Errorf("matchHost(%q) matched %q, want %q", tt.host, pattern, tt.pattern)
+		}
+		if !reflect.DeepEqual(ps, tt.ps) {
+			t.Errorf("matchHost(%q) returned params %v, want %v", tt.host, ps, tt.ps)
+		}
+	}
+}
+
+func TestHostInvalid(t *testing.T) {
+	patterns := [...]string{
+		"",
+		"api..example.com",
+		"api.example.com/v1",
+		":.example.com",
+		"a:b.example.com",
+		"api*.example.com",
+		":id<int.example.com",
+	}
+	for _, pattern := range patterns {
+		if recv := catchPanic(func() { New().Host(pattern) }); recv == nil {
+			t.Errorf("no panic for invalid host pattern '%s'", pattern)
+		}
+	}
+}
+
+func TestStripHostPort(t *testing.T) {
+	tests := [...]struct{ in, out string }{
+		{"example.com", "example.com"},
+		{"example.com:8080", "example.com"},
+		{"[::1]:8080", "::1"},
+		{"[::1]", "::1"},
+		{"::1", "::1"},
+	}
+	for _, tt := range tests {
+		if out := stripHostPort(tt.in); out != tt.out {
+			t.Errorf("stripHostPort(%q) = %q, want %q", tt.in, out, tt.out)
+		}
+	}
+}
+
+func TestHostRouting(t *testing.T) {
+	engine := New()
+	engine.Get("/", func(ctx *Context) { ctx.String(200, "default") })
+	engine.Host("api.example.com").Get("/users/:id", func(ctx *Context) {
+		ctx.String(200, "api %s", ctx.Param("id"))
+	})
+	engine.Host(":tenant.example.com").Group("/v1").Get("/files/*path", func(ctx *Context) {
+		ctx.String(200, "%v", ctx.PathParams)
+	})
+
+	tests := [...]struct {
+		host, path string
+		code       int
+		body       string
+	}{
+		{"example.org", "/", 200, "default"},
+		{"api.example.com", "/users/12", 200, "api 12"},
+		{"api.example.com", "/", 404, "404 page not found"},
+		{"acme.example.com:8080", "/v1/files/a/b", 200, "[{tenant acme} {path /a/b}]"},
+		{"acme.example.com", "/users/12", 404, "404 page not found"},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		r.Host = tt.host
+		engine.ServeHTTP(w, r)
+		if w.Code != tt.code || w.Body.String() != tt.body {
+			t.Errorf("%s%s: got %d %q, want %d %q", tt.host, tt.path, w.Code, w.Body.String(), tt.code, tt.body)
+		}
+	}
+}
@@ -0,0 +1,384 @@
+// Copyright 2014 Manu Martinez-Almeida.  All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package gin
+
+import (
+	"errors"
+	"sync/atomic"
+	"unsafe"
+)
+
+// RouteDefinition is a route to be registered at runtime, see Engine.ReplaceRoutes().
+type RouteDefinition struct {
+	Method   string
+	Path     string
+	Handlers HandlerChain
+}
+
+// routingTable is the immutable snapshot of the trees of Engine in hot-swap mode,
+// it is replaced atomically when the routes are changed at runtime.
+type routingTable struct {
+	trees     trees
+	maxParams uint8                   // the max number of path parameters of all routes, including the routes of hosts
+	routes    map[*routeInfo]struct{} // the routes in trees
+	names     map[string]*routeInfo   // route name --> the named route in trees
+}
+
+// routeEntry is a route stored in the trees.
+type routeEntry struct {
+	method   string
+	path     string
+	handlers HandlerChain
+	info     *routeInfo
+}
+
+var errHotSwapDisabled = errors.New("gin: hot-swapping routes is not enabled, see Engine.HotSwapRoutes()")
+
+// HotSwapRoutes enables the routes of Engine to be changed while serving requests,
+// by Engine.AddRoute(), Engine.RemoveRoute() and Engine.ReplaceRoutes().
+//
+// In hot-swap mode every change builds new trees (copy-on-write) and replaces the trees
+// atomically, the requests being served are not affected and no lock is held when routing.
+// The routes of Engine.Host() are not affected by the hot-swapping.
+//
+// Default is false.
+func (engine *Engine) HotSwapRoutes(b bool) {
+	engine.startedChecker.check() // check if engine has been started.
+	engine.hotSwapRoutes = b
+}
+
+// AddRoute registers a new route with the middlewares of Engine at runtime, see Engine.HotSwapRoutes().
+// It is safe to be called while serving requests, and so are the Name() and Meta() of the returned route:
+//     route, err := engine.AddRoute("GET", "/users/:id", getUser)
+//     if err == nil {
+//         route.Name("user.show").Tags("user")
+//     }
+func (engine *Engine) AddRoute(method, path string, handlers ...HandlerFunc) (*RegisteredRoute, error) {
+	var info *routeInfo
+	err := engine.swapRoutes(func(entries []routeEntry) ([]routeEntry, error) {
+		entry, err := engine.newRouteEntry(RouteDefinition{Method: method, Path: path, Handlers: handlers})
+		if err != nil {
+			return nil, err
+		}
+		info = entry.info
+		return append(entries, entry), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &RegisteredRoute{engine: engine, infos: []*routeInfo{info}}, nil
+}
+
+// RemoveRoute removes the route registered with method and path at runtime, see Engine.HotSwapRoutes().
+// The path must be the same as it was registered, e.g. "/users/:id<int>".
+// It is safe to be called while serving requests.
+//
+// Only the tree of method is copied, and the route is removed from the copy by node.removeRoute().
+func (engine *Engine) RemoveRoute(method, path string) error {
+	if !engine.hotSwapRoutes {
+		return errHotSwapDisabled
+	}
+	engine.hotSwapLock.Lock()
+	defer engine.hotSwapLock.Unlock()
+
+	current := engine.loadRoutingTable()
+	if current == nil {
+		table, err := buildRoutingTable(engine.trees.entries(), engine.maxParams)
+		if err != nil {
+			return err
+		}
+		current = table
+	}
+	var info *routeInfo
+	root := current.trees.getTree(method)
+	if root != nil {
+		for _, entry := range collectEntries(nil, root, method) {
+			if entry.path == path {
+				info = entry.info
+				break
+			}
+		}
+	}
+	if info == nil {
+		return errors.New("gin: route " + method + " " + path + " does not exist")
+	}
+	root = root.clone()
+	root.removeRoute(path)
+
+	table := &routingTable{
+		trees:     make(trees, 0, len(current.trees)),
+		maxParams: current.maxParams,
+		routes:    make(map[*routeInfo]struct{}, len(current.routes)),
+	}
+	for _, t := range current.trees {
+		if t.method != method {
+			table.trees = append(table.trees, t)
+		} else if root.handlers != nil || len(root.children) > 0 {
+			table.trees.addTree(method, root)
+		}
+	}
+	for route := range current.routes {
+		if route != info {
+			table.routes[route] = struct{}{}
+		}
+	}
+	for name, route := range current.names {
+		if route != info {
+			if table.names == nil {
+				table.names = make(map[string]*routeInfo, len(current.names))
+			}
+			table.names[name] = route
+		}
+	}
+	atomic.StorePointer(&engine.routingTable, unsafe.Pointer(table))
+	return nil
+}
+
+// ReplaceRoutes replaces all the routes of Engine with routes at runtime, see Engine.HotSwapRoutes().
+// The handlers of routes are combined with the middlewares of Engine.
+// It is safe to be called while serving requests.
+func (engine *Engine) ReplaceRoutes(routes []RouteDefinition) error {
+	return engine.swapRoutes(func([]routeEntry) ([]routeEntry, error) {
+		entries := make([]routeEntry, 0, len(routes))
+		for _, route := range routes {
+			entry, err := engine.newRouteEntry(route)
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, entry)
+		}
+		return entries, nil
+	})
+}
+
+func (engine *Engine) newRouteEntry(route RouteDefinition) (routeEntry, error) {
+	if !__httpMethodRegexp.MatchString(route.Method) {
+		return routeEntry{}, errors.New(`gin: http method "` + route.Method + `" is not valid`)
+	}
+	if route.Path == "" || route.Path[0] != '/' {
+		return routeEntry{}, errors.New("gin: path must begin with '/'")
+	}
+	if len(route.Handlers) == 0 {
+		return routeEntry{}, errors.New("gin: there must be at least one handler")
+	}
+	for _, h := range route.Handlers {
+		if h == nil {
+			return routeEntry{}, errors.New("gin: each handler of handlers can not be nil")
+		}
+	}
+	handlers := combineHandlerChain(engine.middlewares, route.Handlers)
+	debugPrintRoute(route.Method, route.Path, handlers)
+	return routeEntry{
+		method:   route.Method,
+		path:     route.Path,
+		handlers: handlers,
+		info: &routeInfo{
+			method:  route.Method,
+			path:    route.Path,
+			handler: nameOfFunction(handlers.last()),
+		},
+	}, nil
+}
+
+// swapRoutes builds the new trees from the routes returned by update, and replaces the trees of engine.
+func (engine *Engine) swapRoutes(update func(entries []routeEntry) ([]routeEntry, error)) error {
+	if !engine.hotSwapRoutes {
+		return errHotSwapDisabled
+	}
+	engine.hotSwapLock.Lock()
+	defer engine.hotSwapLock.Unlock()
+
+	current, _ := engine.routingTrees()
+	entries, err := update(current.entries())
+	if err != nil {
+		return err
+	}
+	table, err := buildRoutingTable(entries, engine.maxParams)
+	if err != nil {
+		return err
+	}
+	atomic.StorePointer(&engine.routingTable, unsafe.Pointer(table))
+	return nil
+}
+
+// addTableRoute registers the route into the routing table, it is called by Engine.addRoute()
+// if the routes have been swapped before the engine is started.
+func (engine *Engine) addTableRoute(method, path string, handlers HandlerChain) *routeInfo {
+	info := &routeInfo{
+		method:  method,
+		path:    path,
+		handler: nameOfFunction(handlers.last()),
+	}
+	err := engine.swapRoutes(func(entries []routeEntry) ([]routeEntry, error) {
+		return append(entries, routeEntry{method: method, path: path, handlers: handlers, info: info}), nil
+	})
+	if err != nil {
+		panic(err)
+	}
+	return info
+}
+
+// updateSwappedRoutes modifies the routes of route in hot-swap mode, the copies of the routes are modified by update
+// and replace the routes in the routing table atomically, so that the requests being served are not affected.
+// It returns false if the routes can be modified in place, i.e. they are not in the routing trees of Engine
+// (e.g. the routes of hosts), or the engine has not been started and the routes have not been swapped.
+func (route *RegisteredRoute) updateSwappedRoutes(update func(infos []*routeInfo)) bool {
+	engine := route.engine
+	if !engine.hotSwapRoutes || len(route.infos) == 0 || route.infos[0].host != "" {
+		return false
+	}
+	if !engine.startedChecker.started() && engine.loadRoutingTable() == nil {
+		return false
+	}
+	copies := make([]*routeInfo, len(route.infos))
+	indexes := make(map[*routeInfo]int, len(route.infos))
+	for i, info := range route.infos {
+		c := *info
+		if info.meta != nil {
+			c.meta = make(map[string]interface{}, len(info.meta)+1)
+			for k, v := range info.meta {
+				c.meta[k] = v
+			}
+		}
+		copies[i] = &c
+		indexes[info] = i
+	}
+	err := engine.swapRoutes(func(entries []routeEntry) ([]routeEntry, error) {
+		n := 0
+		for i := range entries {
+			if index, ok := indexes[entries[i].info]; ok {
+				entries[i].info = copies[index]
+				n++
+			}
+		}
+		if n != len(copies) {
+			info := route.infos[0]
+			return nil, errors.New("gin: route " + info.method + " " + info.path + " has been removed")
+		}
+		update(copies)
+		return entries, nil
+	})
+	if err != nil {
+		panic(err.Error())
+	}
+	route.infos = copies
+	return true
+}
+
+// updateTableMaxParams updates the maxParams of the routing table after the routes of hosts are registered,
+// if the routes have been swapped before the engine is started.
+func (engine *Engine) updateTableMaxParams() {
+	engine.hotSwapLock.Lock()
+	defer engine.hotSwapLock.Unlock()
+
+	if table := engine.loadRoutingTable(); table != nil && table.maxParams < engine.maxParams {
+		newTable := *table
+		newTable.maxParams = engine.maxParams
+		atomic.StorePointer(&engine.routingTable, unsafe.Pointer(&newTable))
+	}
+}
+
+func (engine *Engine) loadRoutingTable() *routingTable {
+	return (*routingTable)(atomic.LoadPointer(&engine.routingTable))
+}
+
+// routingTrees returns the trees of engine (excluding the trees of hosts) for routing the requests,
+// and the max number of path parameters of all routes.
+func (engine *Engine) routingTrees() (trees, uint8) {
+	if engine.hotSwapRoutes {
+		if table := engine.loadRoutingTable(); table != nil {
+			return table.trees, table.maxParams
+		}
+	}
+	return engine.trees, engine.maxParams
+}
+
+// routeRemoved reports whether the route has been removed at runtime.
+func (engine *Engine) routeRemoved(info *routeInfo) bool {
+	if !engine.hotSwapRoutes || info.host != "" {
+		return false
+	}
+	table := engine.loadRoutingTable()
+	if table == nil {
+		return false
+	}
+	_, ok := table.routes[info]
+	return !ok
+}
+
+// lookupNamedRoute returns the route named name, the removed route is not returned.
+func (engine *Engine) lookupNamedRoute(name string) (*routeInfo, bool) {
+	if engine.hotSwapRoutes {
+		if table := engine.loadRoutingTable(); table != nil {
+			if info, ok := table.names[name]; ok {
+				return info, true
+			}
+		}
+	}
+	info, ok := engine.namedRoutes[name]
+	if !ok || engine.routeRemoved(info) {
+		return nil, false
+	}
+	return info, true
+}
+
+// buildRoutingTable builds the routing table from entries, maxParams is the max number of path parameters
+// of the routes not in entries, i.e. the routes registered before the engine is started and the routes of hosts.
+func buildRoutingTable(entries []routeEntry, maxParams uint8) (table *routingTable, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = recoveredRouteError(r)
+		}
+	}()
+
+	table = &routingTable{
+		maxParams: maxParams,
+		routes:    make(map[*routeInfo]struct{}, len(entries)),
+	}
+	for _, entry := range entries {
+		root := table.trees.getTree(entry.method)
+		if root == nil {
+			root = new(node)
+			table.trees.addTree(entry.method, root)
+		}
+		leaf := root.addRouteMethod(entry.method, entry.path, entry.handlers)
+		leaf.route = entry.info
+		table.routes[entry.info] = struct{}{}
+		if name := entry.info.name; name != "" {
+			if table.names == nil {
+				table.names = make(map[string]*routeInfo)
+			}
+			table.names[name] = entry.info
+		}
+		if root.maxParams > table.maxParams {
+			table.maxParams = root.maxParams
+		}
+	}
+	return table, nil
+}
+
+// entries returns the routes stored in the trees.
+func (v trees) entries() []routeEntry {
+	entries := make([]routeEntry, 0, 64)
+	for i := 0; i < len(v); i++ {
+		entries = collectEntries(entries, v[i].root, v[i].method)
+	}
+	return entries
+}
+
+func collectEntries(entries []routeEntry, n *node, method string) []routeEntry {
+	if n.handlers != nil && n.route != nil {
+		entries = append(entries, routeEntry{
+			method:   method,
+			path:     n.route.path,
+			handlers: n.handlers,
+			info:     n.route,
+		})
+	}
+	for _, child := range n.children {
+		entries = collectEntries(entries, child, method)
+	}
+	return entries
+}
@@ -0,0 +1,290 @@
+// Copyright 2014 Manu Martinez-Almeida.  All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package gin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestHotSwapRoutes(t *testing.T) {
+	serve := func(engine *Engine, path string) (int, string) {
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w.Code, w.Body.String()
+	}
+	handler := func(body string) HandlerFunc {
+		return func(ctx *Context) { ctx.String(200, "%s %v", body, ctx.PathParams) }
+	}
+
+	engine := New()
+	if _, err := engine.AddRoute("GET", "/a", handler("a")); err != errHotSwapDisabled {
+		t.Fatalf("AddRoute without hot-swap mode returned %v", err)
+	}
+	engine.HotSwapRoutes(true)
+	engine.Get("/static", handler("static"))
+	if _, err := engine.AddRoute("GET", "/before/:id", handler("before")); err != nil {
+		t.Fatalf("AddRoute before starting returned %v", err)
+	}
+	if code, body := serve(engine, "/before/1"); code != 200 || body != "before [{id 1}]" {
+		t.Fatalf("GET /before/1: got %d %q", code, body)
+	}
+
+	// the engine has been started
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				if code, _ := serve(engine, "/static"); code != 200 {
+					t.Errorf("GET /static: got %d while swapping", code)
+					return
+				}
+				serve(engine, "/a/b/c/d")
+			}
+		}()
+	}
+	for j := 0; j < 20; j++ {
+		if _, err := engine.AddRoute("GET", "/:a/:b/:c/:d", handler("params")); err != nil {
+			t.Fatalf("AddRoute returned %v", err)
+		}
+		if err := engine.RemoveRoute("GET", "/:a/:b/:c/:d"); err != nil {
+			t.Fatalf("RemoveRoute returned %v", err)
+		}
+	}
+	wg.Wait()
+
+	if _, err := engine.AddRoute("GET", "/users/:id", handler("user")); err != nil {
+		t.Fatalf("AddRoute returned %v", err)
+	}
+	if code, body := serve(engine, "/users/12"); code != 200 || body != "user [{id 12}]" {
+		t.Errorf("GET /users/12: got %d %q", code, body)
+	}
+	if _, err := engine.AddRoute("GET", "/users/:name", handler("conflict")); err == nil {
+		t.Errorf("AddRoute with conflicting route returned nil error")
+	}
+	if err := engine.RemoveRoute("GET", "/nonexistent"); err == nil {
+		t.Errorf("RemoveRoute of nonexistent route returned nil error")
+	}
+	if err := engine.ReplaceRoutes([]RouteDefinition{{Method: "GET", Path: "/new", Handlers: HandlerChain{handler("new")}}}); err != nil {
+		t.Fatalf("ReplaceRoutes returned %v", err)
+	}
+	if code, _ := serve(engine, "/users/12"); code != 404 {
+		t.Errorf("GET /users/12 after ReplaceRoutes: got %d", code)
+	}
+	if code, body := serve(engine, "/new"); code != 200 || body != "new []" {
+		t.Errorf("GET /new: got %d %q", code, body)
+	}
+	if routes := engine.Routes(); len(routes) != 1 || routes[0].Path != "/new" {
+		t.Errorf("Routes() after ReplaceRoutes: %v", routes)
+	}
+}
+
+func TestHotSwapRemoveNamedRoute(t *testing.T) {
+	engine := New()
+	engine.HotSwapRoutes(true)
+	engine.Get("/users/:id", func(ctx *Context) {}).Name("user")
+	engine.Get("/posts/:id", func(ctx *Context) {}).Name("post")
+
+	if url, err := engine.URL("user", 1); err != nil || url != "/users/1" {
+		t.Fatalf("URL(user) got %q, %v", url, err)
+	}
+	if err := engine.RemoveRoute("GET", "/users/:id"); err != nil {
+		t.Fatalf("RemoveRoute returned %v", err)
+	}
+	if url, err := engine.URL("user", 1); err == nil {
+		t.Errorf("URL of the removed route got %q", url)
+	}
+	if url, err := engine.URL("post", 2); err != nil || url != "/posts/2" {
+		t.Errorf("URL(post) got %q, %v", url, err)
+	}
+	if err := engine.ReplaceRoutes(nil); err != nil {
+		t.Fatalf("ReplaceRoutes returned %v", err)
+	}
+	if url, err := engine.URL("post", 2); err == nil {
+		t.Errorf("URL of the replaced route got %q", url)
+	}
+}
+
+func TestHotSwapRegisterAfterSwap(t *testing.T) {
+	engine := New()
+	engine.HotSwapRoutes(true)
+	if _, err := engine.AddRoute("GET", "/a", func(ctx *Context) { ctx.String(200, "a") }); err != nil {
+		t.Fatalf("AddRoute returned %v", err)
+	}
+	// the routes registered after swapping are added to the routing table
+	engine.Get("/b/:x/:y/:z", func(ctx *Context) { ctx.String(200, "%v", ctx.PathParams) }).Name("b")
+	engine.Host(":sub.example.com").Get("/:p1/:p2/:p3/:p4", func(ctx *Context) { ctx.String(200, "%d", len(ctx.PathParams)) })
+
+	if _, maxParams := engine.routingTrees(); maxParams != 5 {
+		t.Errorf("got maxParams %d, want 5", maxParams)
+	}
+	for path, want := range map[string]string{"/a": "a", "/b/1/2/3": "[{x 1} {y 2} {z 3}]"} {
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != 200 || w.Body.String() != want {
+			t.Errorf("GET %s: got %d %q, want %q", path, w.Code, w.Body.String(), want)
+		}
+	}
+	if url, err := engine.URL("b", 1, 2, 3); err != nil || url != "/b/1/2/3" {
+		t.Errorf("URL(b) got %q, %v", url, err)
+	}
+}
+
+func TestHotSwapRacingFirstRequest(t *testing.T) {
+	engine := New()
+	engine.HotSwapRoutes(true)
+	engine.Get("/static", func(ctx *Context) { ctx.String(200, "static") })
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 50; i++ {
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/static", nil))
+			if w.Code != 200 {
+				t.Errorf("GET /static: got %d", w.Code)
+				return
+			}
+		}
+	}()
+	for i := 0; i < 50; i++ {
+		if _, err := engine.AddRoute("GET", "/p/:a/:b", func(ctx *Context) {}); err != nil {
+			t.Fatalf("AddRoute returned %v", err)
+		}
+		if err := engine.RemoveRoute("GET", "/p/:a/:b"); err != nil {
+			t.Fatalf("RemoveRoute returned %v", err)
+		}
+	}
+	wg.Wait()
+}
+
+func TestHotSwapNameAndMeta(t *testing.T) {
+	engine := New()
+	engine.HotSwapRoutes(true)
+	before := engine.Get("/before/:id", func(ctx *Context) {}).Name("before")
+	engine.Get("/route/:id", func(ctx *Context) {
+		route, _ := ctx.Route()
+		ctx.String(200, "%s %v", route.Name, route.Meta.Strings(MetaTags))
+	})
+	serve := func(path string) string {
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w.Body.String()
+	}
+	serve("/route/1") // the engine has been started
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 50; i++ {
+			serve("/users/1")
+			engine.Routes()
+			engine.URL("user", 1)
+		}
+	}()
+	route, err := engine.AddRoute("GET", "/users/:id", func(ctx *Context) {
+		route, _ := ctx.Route()
+		ctx.String(200, "%s %v", route.Name, route.Meta.Strings(MetaTags))
+	})
+	if err != nil {
+		t.Fatalf("AddRoute returned %v", err)
+	}
+	route.Name("user").Tags("user")
+	wg.Wait()
+
+	if body := serve("/users/1"); body != "user [user]" {
+		t.Errorf("GET /users/1: got %q", body)
+	}
+	if url, err := engine.URL("user", 1); err != nil || url != "/users/1" {
+		t.Errorf("URL(user) got %q, %v", url, err)
+	}
+	found := false
+	for _, r := range engine.Routes() {
+		if r.Path == "/users/:id" {
+			found = r.Name == "user" && len(r.Meta.Strings(MetaTags)) == 1
+		}
+	}
+	if !found {
+		t.Errorf("the named route is not in Routes(): %v", engine.Routes())
+	}
+
+	// the route registered before starting
+	before.Description("before")
+	if url, err := engine.URL("before", 2); err != nil || url != "/before/2" {
+		t.Errorf("URL(before) got %q, %v", url, err)
+	}
+
+	route2, _ := engine.AddRoute("GET", "/posts/:id", func(ctx *Context) {})
+	if recv := catchPanic(func() { route2.Name("before") }); recv == nil {
+		t.Error("the duplicate route name is accepted")
+	}
+	if err := engine.RemoveRoute("GET", "/posts/:id"); err != nil {
+		t.Fatalf("RemoveRoute returned %v", err)
+	}
+	if recv := catchPanic(func() { route2.Tags("post") }); recv == nil {
+		t.Error("the metadata of the removed route is accepted")
+	}
+}
+
+func TestHotSwapRouteConflict(t *testing.T) {
+	engine := New()
+	engine.HotSwapRoutes(true)
+	if _, err := engine.AddRoute("GET", "/users/:id", func(ctx *Context) {}); err != nil {
+		t.Fatalf("AddRoute returned %v", err)
+	}
+	// registered into the routing table
+	recv := catchPanic(func() { engine.Get("/users/:name", func(ctx *Context) {}) })
+	if err, ok := recv.(*RouteConflictError); !ok || err.Method != "GET" || err.ExistingPath != "/users/:id" {
+		t.Errorf("got panic %T %v, want *RouteConflictError", recv, recv)
+	}
+	if _, err := engine.TryHandle("GET", "/users/:name", func(ctx *Context) {}); err == nil {
+		t.Error("TryHandle with conflicting route returned nil error")
+	} else if _, ok := err.(*RouteConflictError); !ok {
+		t.Errorf("TryHandle got %T %v, want *RouteConflictError", err, err)
+	}
+}
+
+func TestHotSwapRemoveRouteCopy(t *testing.T) {
+	engine := New()
+	engine.HotSwapRoutes(true)
+	engine.Get("/doc/go_faq.html", func(ctx *Context) {})
+	engine.Get("/doc/go1.html", func(ctx *Context) {})
+	engine.Post("/doc", func(ctx *Context) {})
+	if _, err := engine.AddRoute("PUT", "/doc", func(ctx *Context) {}); err != nil {
+		t.Fatalf("AddRoute returned %v", err)
+	}
+
+	before, _ := engine.routingTrees()
+	dump := dumpTrees(before)
+	if err := engine.RemoveRoute("GET", "/doc/go_faq.html"); err != nil {
+		t.Fatalf("RemoveRoute returned %v", err)
+	}
+	// the trees being served are not modified
+	if got := dumpTrees(before); got != dump {
+		t.Errorf("the trees before removing are changed:\n%s\nwant:\n%s", got, dump)
+	}
+	after, _ := engine.routingTrees()
+	if root := after.getTree("GET"); root.path != "/doc/go1.html" || len(root.children) != 0 {
+		t.Errorf("the prefix is not merged after removing:\n%s", dumpTrees(after))
+	}
+	if after.getTree("POST") != before.getTree("POST") {
+		t.Error("the tree of the other method is copied")
+	}
+	if err := engine.RemoveRoute("POST", "/doc"); err != nil {
+		t.Fatalf("RemoveRoute returned %v", err)
+	}
+	if after, _ = engine.routingTrees(); after.getTree("POST") != nil {
+		t.Errorf("the empty tree is kept:\n%s", dumpTrees(after))
+	}
+	if err := engine.RemoveRoute("GET", "/doc/go_faq.html"); err == nil {
+		t.Error("RemoveRoute of the removed route returned nil error")
+	}
+}
@@ -0,0 +1,37 @@
+// Copyright 2014 Manu Martinez-Almeida.  All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+// Package netutil contains the network helpers shared by gin and its middlewares.
+package netutil
+
+import (
+	"errors"
+	"net"
+	"strings"
+)
+
+// ParseIPNets parses each of cidrs as a CIDR (e.g. "10.0.0.0/8", "fd00::/8") or a single IP (e.g. "192.168.1.1"),
+// the single IP is converted to the network of the IP only.
+func ParseIPNets(cidrs []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		if !strings.ContainsRune(cidr, '/') {
+			ip := net.ParseIP(cidr)
+			if ip == nil {
+				return nil, errors.New("invalid IP '" + cidr + "'")
+			}
+			if ip4 := ip.To4(); ip4 != nil {
+				ip = ip4
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(len(ip)*8, len(ip)*8)})
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, errors.New("invalid CIDR '" + cidr + "'")
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets, nil
+}
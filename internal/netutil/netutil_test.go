@@ -0,0 +1,40 @@
+// Copyright 2014 Manu Martinez-Almeida.  All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package netutil
+
+import (
+	"testing"
+)
+
+func TestParseIPNets(t *testing.T) {
+	tests := []struct {
+		cidr string
+		want string
+		err  bool
+	}{
+		{"10.0.0.0/8", "10.0.0.0/8", false},
+		{"10.1.2.3/8", "10.0.0.0/8", false},
+		{"192.168.1.1", "192.168.1.1/32", false},
+		{"::ffff:192.168.1.1", "192.168.1.1/32", false},
+		{"fd00::/8", "fd00::/8", false},
+		{"::1", "::1/128", false},
+		{"", "", true},
+		{"bogus", "", true},
+		{"10.0.0.0/33", "", true},
+		{"10.0.0/8", "", true},
+	}
+	for _, tt := range tests {
+		nets, err := ParseIPNets([]string{tt.cidr})
+		if tt.err {
+			if err == nil {
+				t.Errorf("ParseIPNets(%q) got %v, want error", tt.cidr, nets)
+			}
+			continue
+		}
+		if err != nil || len(nets) != 1 || nets[0].String() != tt.want {
+			t.Errorf("ParseIPNets(%q) got %v, %v, want %s", tt.cidr, nets, err, tt.want)
+		}
+	}
+}
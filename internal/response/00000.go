@@ -12,6 +12,8 @@ type responseWriter00000 struct {
 	wroteHeader    bool  // reply header has been (logically) written
 	status         int   // status code passed to WriteHeader
 	written        int64 // number of bytes written in body
+
+	beforeWriteHeader []func() // called before the header is written, see BeforeWriteHeader
 }
 
 func (w *responseWriter00000) Reset(writer http.ResponseWriter) {
@@ -19,6 +21,7 @@ func (w *responseWriter00000) Reset(writer http.ResponseWriter) {
 	w.wroteHeader = false
 	w.status = http.StatusOK
 	w.written = 0
+	w.beforeWriteHeader = nil
 }
 
 func (w *responseWriter00000) WroteHeader() bool {
@@ -33,6 +36,14 @@ func (w *responseWriter00000) Written() int64 {
 	return w.written
 }
 
+func (w *responseWriter00000) Hijacked() bool {
+	return false
+}
+
+func (w *responseWriter00000) BeforeWriteHeader(fn func()) {
+	w.beforeWriteHeader = append(w.beforeWriteHeader, fn)
+}
+
 func (w *responseWriter00000) Header() http.Header {
 	return w.responseWriter.Header()
 }
@@ -42,6 +53,12 @@ func (w *responseWriter00000) WriteHeader(code int) {
 		log.Println("gin: multiple response.WriteHeader calls")
 		return
 	}
+	if hooks := w.beforeWriteHeader; len(hooks) > 0 {
+		w.beforeWriteHeader = nil
+		for i := len(hooks) - 1; i >= 0; i-- {
+			hooks[i]()
+		}
+	}
 	w.wroteHeader = true
 	w.status = code
 	w.responseWriter.WriteHeader(code)
@@ -21,6 +21,10 @@ func (w *responseWriter00010) Reset(writer http.ResponseWriter) {
 	w.hijacked = false
 }
 
+func (w *responseWriter00010) Hijacked() bool {
+	return w.hijacked
+}
+
 func (w *responseWriter00010) WriteHeader(code int) {
 	if w.hijacked {
 		log.Println("gin: response.WriteHeader on hijacked connection")
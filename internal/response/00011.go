@@ -22,6 +22,10 @@ func (w *responseWriter00011) Reset(writer http.ResponseWriter) {
 	w.hijacked = false
 }
 
+func (w *responseWriter00011) Hijacked() bool {
+	return w.hijacked
+}
+
 func (w *responseWriter00011) WriteHeader(code int) {
 	if w.hijacked {
 		log.Println("gin: response.WriteHeader on hijacked connection")
@@ -0,0 +1,157 @@
+// Copyright 2014 Manu Martinez-Almeida.  All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package response
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+)
+
+var _ ResponseWriter = (*CaptureWriter)(nil)
+var _ http.Flusher = (*CaptureWriter)(nil)
+
+// CaptureWriter captures the status code and body written to the underlying ResponseWriter.
+// The header is the header of the underlying ResponseWriter.
+//
+// If buffering, nothing is written to the underlying ResponseWriter until Commit() or Flush() is called,
+// otherwise the response is written through and captured at the same time.
+// The capturing is given up once the body exceeds the limit, and the buffered response is committed.
+type CaptureWriter struct {
+	w           ResponseWriter
+	buffering   bool
+	limit       int // <= 0 means no limit
+	overflowed  bool
+	wroteHeader bool
+	status      int
+	written     int64
+	body        bytes.Buffer
+}
+
+// NewCaptureWriter returns a new CaptureWriter which captures the response written to w.
+func NewCaptureWriter(w ResponseWriter, buffering bool, limit int) *CaptureWriter {
+	return &CaptureWriter{
+		w:         w,
+		buffering: buffering,
+		limit:     limit,
+		status:    http.StatusOK,
+	}
+}
+
+// NewBufferWriter returns a new CaptureWriter which buffers the whole response in memory without
+// an underlying connection, e.g. to call a local handler. It must not be committed.
+func NewBufferWriter() *CaptureWriter {
+	return NewCaptureWriter(&headerWriter{header: make(http.Header)}, true, 0)
+}
+
+// headerWriter is the ResponseWriter of NewBufferWriter, it holds the header only.
+type headerWriter struct {
+	header http.Header
+}
+
+func (w *headerWriter) WroteHeader() bool              { return false }
+func (w *headerWriter) Status() int                    { return http.StatusOK }
+func (w *headerWriter) Written() int64                 { return 0 }
+func (w *headerWriter) Header() http.Header            { return w.header }
+func (w *headerWriter) WriteHeader(int)                {}
+func (w *headerWriter) Write(data []byte) (int, error) { return len(data), nil }
+
+func (w *CaptureWriter) WroteHeader() bool {
+	return w.wroteHeader
+}
+
+func (w *CaptureWriter) Status() int {
+	return w.status
+}
+
+func (w *CaptureWriter) Written() int64 {
+	return w.written
+}
+
+func (w *CaptureWriter) Header() http.Header {
+	return w.w.Header()
+}
+
+func (w *CaptureWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		log.Println("gin: multiple response.WriteHeader calls")
+		return
+	}
+	w.wroteHeader = true
+	w.status = code
+	if !w.buffering {
+		w.w.WriteHeader(code)
+	}
+}
+
+func (w *CaptureWriter) Write(data []byte) (n int, err error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if !w.overflowed {
+		if w.limit > 0 && w.body.Len()+len(data) > w.limit {
+			w.overflowed = true
+			w.Commit()
+			w.body = bytes.Buffer{}
+		} else {
+			w.body.Write(data)
+		}
+	}
+	if w.buffering {
+		n = len(data)
+	} else {
+		n, err = w.w.Write(data)
+	}
+	w.written += int64(n)
+	return
+}
+
+func (w *CaptureWriter) WriteString(data string) (n int, err error) {
+	return w.Write([]byte(data))
+}
+
+// Flush commits the buffered response and flushes the underlying ResponseWriter if it is a http.Flusher.
+func (w *CaptureWriter) Flush() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	w.Commit()
+	if flusher, ok := w.w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Commit writes the buffered response to the underlying ResponseWriter and stops buffering,
+// the later writes are written through.
+func (w *CaptureWriter) Commit() {
+	if !w.buffering {
+		return
+	}
+	w.buffering = false
+	if w.wroteHeader {
+		w.w.WriteHeader(w.status)
+		if w.body.Len() > 0 {
+			w.w.Write(w.body.Bytes())
+		}
+	}
+}
+
+// Buffering reports whether the response is still held, i.e. nothing has been written to the underlying ResponseWriter.
+func (w *CaptureWriter) Buffering() bool {
+	return w.buffering
+}
+
+// Overflowed reports whether the body exceeds the limit, the body is not captured if it is true.
+func (w *CaptureWriter) Overflowed() bool {
+	return w.overflowed
+}
+
+// Body returns the captured body, it is nil if Overflowed.
+func (w *CaptureWriter) Body() []byte {
+	if w.overflowed {
+		return nil
+	}
+	return w.body.Bytes()
+}
@@ -0,0 +1,135 @@
+// Copyright 2014 Manu Martinez-Almeida.  All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package response
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func newTestWriter() (ResponseWriter2, *httptest.ResponseRecorder) {
+	recorder := httptest.NewRecorder()
+	w := NewResponseWriter2(Bitmap(recorder))
+	w.Reset(recorder)
+	return w, recorder
+}
+
+func TestCaptureWriterPassThrough(t *testing.T) {
+	w, recorder := newTestWriter()
+	cw := NewCaptureWriter(w, false, 0)
+	cw.WriteHeader(http.StatusCreated)
+	cw.Write([]byte("hello "))
+	cw.WriteString("world")
+
+	if recorder.Code != http.StatusCreated || recorder.Body.String() != "hello world" {
+		t.Errorf("got %d %q from the underlying writer", recorder.Code, recorder.Body.String())
+	}
+	if cw.Buffering() || cw.Status() != http.StatusCreated || cw.Written() != 11 || string(cw.Body()) != "hello world" {
+		t.Errorf("got buffering %t, status %d, written %d, body %q", cw.Buffering(), cw.Status(), cw.Written(), cw.Body())
+	}
+	cw.Commit() // no-op
+	if recorder.Body.String() != "hello world" {
+		t.Errorf("got %q after Commit()", recorder.Body.String())
+	}
+}
+
+func TestCaptureWriterBuffered(t *testing.T) {
+	w, recorder := newTestWriter()
+	cw := NewCaptureWriter(w, true, 0)
+	cw.Header().Set("X-Test", "1")
+	cw.WriteHeader(http.StatusAccepted)
+	cw.Write([]byte("hello"))
+
+	if w.WroteHeader() || recorder.Body.Len() != 0 {
+		t.Errorf("the buffered response is written: %d %q", recorder.Code, recorder.Body.String())
+	}
+	if !cw.Buffering() || !cw.WroteHeader() || cw.Status() != http.StatusAccepted || cw.Written() != 5 || string(cw.Body()) != "hello" {
+		t.Errorf("got buffering %t, status %d, written %d, body %q", cw.Buffering(), cw.Status(), cw.Written(), cw.Body())
+	}
+
+	cw.Commit()
+	cw.Write([]byte(" world")) // written through after Commit()
+	if recorder.Code != http.StatusAccepted || recorder.Body.String() != "hello world" || recorder.Header().Get("X-Test") != "1" {
+		t.Errorf("got %d %q %v after Commit()", recorder.Code, recorder.Body.String(), recorder.Header())
+	}
+	if cw.Buffering() || string(cw.Body()) != "hello world" {
+		t.Errorf("got buffering %t, body %q after Commit()", cw.Buffering(), cw.Body())
+	}
+}
+
+func TestCaptureWriterCommitWithoutHeader(t *testing.T) {
+	w, _ := newTestWriter()
+	cw := NewCaptureWriter(w, true, 0)
+	cw.Commit()
+	if w.WroteHeader() {
+		t.Error("the header is written by Commit() although nothing is written")
+	}
+}
+
+func TestCaptureWriterFlush(t *testing.T) {
+	w, recorder := newTestWriter()
+	cw := NewCaptureWriter(w, true, 0)
+	cw.Write([]byte("hello"))
+	cw.Flush()
+	if cw.Buffering() || !recorder.Flushed || recorder.Body.String() != "hello" {
+		t.Errorf("got buffering %t, flushed %t, body %q after Flush()", cw.Buffering(), recorder.Flushed, recorder.Body.String())
+	}
+}
+
+func TestCaptureWriterOverflow(t *testing.T) {
+	for _, buffering := range []bool{false, true} {
+		w, recorder := newTestWriter()
+		cw := NewCaptureWriter(w, buffering, 8)
+		cw.Write([]byte("hello"))
+		if cw.Overflowed() || string(cw.Body()) != "hello" || cw.Buffering() != buffering {
+			t.Errorf("buffering %t: got overflowed %t, body %q before the limit", buffering, cw.Overflowed(), cw.Body())
+		}
+		cw.Write([]byte(" world"))
+		cw.Write([]byte("!"))
+
+		// the capturing is given up and the buffered response is committed
+		if !cw.Overflowed() || cw.Body() != nil || cw.Buffering() || cw.Written() != 12 {
+			t.Errorf("buffering %t: got overflowed %t, buffering %t, written %d, body %q", buffering, cw.Overflowed(), cw.Buffering(), cw.Written(), cw.Body())
+		}
+		if recorder.Code != http.StatusOK || recorder.Body.String() != "hello world!" {
+			t.Errorf("buffering %t: got %d %q from the underlying writer", buffering, recorder.Code, recorder.Body.String())
+		}
+	}
+}
+
+func TestCaptureWriterWriteHeaderAfterWrite(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	for _, buffering := range []bool{false, true} {
+		buf.Reset()
+		w, recorder := newTestWriter()
+		cw := NewCaptureWriter(w, buffering, 0)
+		cw.Write([]byte("hello"))
+		cw.WriteHeader(http.StatusInternalServerError) // ignored, the header has been written
+		cw.Commit()
+		if cw.Status() != http.StatusOK || recorder.Code != http.StatusOK || recorder.Body.String() != "hello" {
+			t.Errorf("buffering %t: got status %d, %d %q", buffering, cw.Status(), recorder.Code, recorder.Body.String())
+		}
+		if !bytes.Contains(buf.Bytes(), []byte("multiple response.WriteHeader calls")) {
+			t.Errorf("buffering %t: got log %q", buffering, buf.String())
+		}
+	}
+}
+
+func TestBufferWriter(t *testing.T) {
+	w := NewBufferWriter()
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusNotFound)
+	w.Write([]byte("{}"))
+	if w.Status() != http.StatusNotFound || string(w.Body()) != "{}" || w.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("got %d %q %v", w.Status(), w.Body(), w.Header())
+	}
+}
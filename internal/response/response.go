@@ -14,6 +14,11 @@ type ResponseWriter interface {
 type ResponseWriter2 interface {
 	ResponseWriter
 	Reset(w http.ResponseWriter)
+	Hijacked() bool // Hijacked returns true if the connection has been hijacked, see http.Hijacker.
+
+	// BeforeWriteHeader registers fn to be called before the header is written,
+	// the functions are called in the reverse order of registration.
+	BeforeWriteHeader(fn func())
 }
 
 func NewResponseWriter2(bitmap int) ResponseWriter2 {
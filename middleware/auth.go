@@ -0,0 +1,189 @@
+// Copyright 2014 Manu Martinez-Almeida.  All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package middleware
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/chanxuehong/gin"
+)
+
+// The key of Context to store the authenticated principal, see AuthPrincipal().
+const principalKey = "github.com/chanxuehong/gin/middleware.Principal"
+
+// Accounts is the accounts of BasicAuth, user name --> password.
+type Accounts map[string]string
+
+// AuthPrincipal returns the principal authenticated by BasicAuth, BearerAuth or JWT, ie: (principal, true).
+// If the request is not authenticated it returns (nil, false).
+func AuthPrincipal(ctx *gin.Context) (principal interface{}, ok bool) {
+	return ctx.Get(principalKey)
+}
+
+// AuthUser returns the principal if it is a string, e.g. the user name authenticated by BasicAuth,
+// otherwise it returns "".
+func AuthUser(ctx *gin.Context) string {
+	principal, _ := ctx.Get(principalKey)
+	user, _ := principal.(string)
+	return user
+}
+
+// SetAuthPrincipal sets the authenticated principal of the request, it is used by the custom
+// authentication middlewares so that AuthPrincipal() works the same.
+func SetAuthPrincipal(ctx *gin.Context, principal interface{}) {
+	ctx.Set(principalKey, principal)
+}
+
+// BasicAuth returns a middleware that authenticates the requests by the HTTP Basic authentication
+// (RFC 7617) with accounts, the passwords are compared in constant time. The user name is set as
+// the principal, see AuthUser(). The unauthenticated requests are answered with 401 and the
+// WWW-Authenticate challenge of realm, the default realm is "Authorization Required".
+//
+// It can be attached to a RouteGroup:
+//     admin := engine.Group("/admin", middleware.BasicAuth(middleware.Accounts{"admin": "secret"}, ""))
+func BasicAuth(accounts Accounts, realm string) gin.HandlerFunc {
+	if len(accounts) == 0 {
+		panic("accounts can not be empty")
+	}
+	hashed := make(map[string][sha256.Size]byte, len(accounts))
+	for user, password := range accounts {
+		if user == "" {
+			panic("user name can not be empty")
+		}
+		hashed[user] = sha256.Sum256([]byte(password))
+	}
+	var dummy [sha256.Size]byte
+	return BasicAuthFunc(func(_ *gin.Context, user, password string) bool {
+		expected, ok := hashed[user]
+		if !ok {
+			expected = dummy // keeps the time of unknown user the same
+		}
+		actual := sha256.Sum256([]byte(password))
+		return subtle.ConstantTimeCompare(expected[:], actual[:]) == 1 && ok
+	}, realm)
+}
+
+// BasicAuthFunc returns a middleware like BasicAuth, the credentials are validated by validator,
+// which should compare the secrets in constant time, e.g. by subtle.ConstantTimeCompare.
+func BasicAuthFunc(validator func(ctx *gin.Context, user, password string) bool, realm string) gin.HandlerFunc {
+	if validator == nil {
+		panic("validator can not be nil")
+	}
+	if realm == "" {
+		realm = "Authorization Required"
+	}
+	challenge := "Basic realm=" + quoteAuthParam(realm) + `, charset="UTF-8"`
+
+	return func(ctx *gin.Context) {
+		user, password, ok := ctx.Request.BasicAuth()
+		if !ok || !validator(ctx, user, password) {
+			ctx.ResponseWriter.Header().Set(gin.HeaderWWWAuthenticate, challenge)
+			ctx.AbortWithError(http.StatusUnauthorized, "401 unauthorized")
+			return
+		}
+		SetAuthPrincipal(ctx, user)
+		ctx.Next()
+	}
+}
+
+// BearerAuthConfig is the configuration of BearerAuthWithConfig.
+type BearerAuthConfig struct {
+	// Validator validates the token and returns the principal of it, it is required.
+	Validator func(ctx *gin.Context, token string) (principal interface{}, ok bool)
+
+	// Realm is the realm of the WWW-Authenticate challenge, it is omitted if empty.
+	Realm string
+
+	// Scope is the scope of the WWW-Authenticate challenge, it is omitted if empty.
+	Scope string
+}
+
+// BearerAuth returns a middleware that authenticates the requests by the bearer token (RFC 6750)
+// in the Authorization header, the token is validated by validator, and the returned principal is set
+// on the Context, see AuthPrincipal(). The unauthenticated requests are answered with 401 and the
+// WWW-Authenticate challenge, with error="invalid_token" if the token is invalid.
+func BearerAuth(validator func(ctx *gin.Context, token string) (principal interface{}, ok bool)) gin.HandlerFunc {
+	return BearerAuthWithConfig(BearerAuthConfig{Validator: validator})
+}
+
+// BearerAuthWithConfig returns a middleware like BearerAuth with the config.
+func BearerAuthWithConfig(config BearerAuthConfig) gin.HandlerFunc {
+	if config.Validator == nil {
+		panic("validator can not be nil")
+	}
+	challenge := bearerChallenge(config.Realm, config.Scope)
+	invalidTokenChallenge := bearerChallenge(config.Realm, config.Scope, "error", "invalid_token")
+	invalidRequestChallenge := bearerChallenge(config.Realm, config.Scope, "error", "invalid_request")
+
+	return func(ctx *gin.Context) {
+		token, present, ok := bearerToken(ctx.Request)
+		switch {
+		case !present:
+			unauthorized(ctx, http.StatusUnauthorized, challenge)
+		case !ok:
+			unauthorized(ctx, http.StatusBadRequest, invalidRequestChallenge)
+		default:
+			principal, ok := config.Validator(ctx, token)
+			if !ok {
+				unauthorized(ctx, http.StatusUnauthorized, invalidTokenChallenge)
+				return
+			}
+			SetAuthPrincipal(ctx, principal)
+			ctx.Next()
+		}
+	}
+}
+
+// bearerToken returns the bearer token of the Authorization header of r, present is false if the request
+// has no bearer credentials, ok is false if the credentials are malformed.
+func bearerToken(r *http.Request) (token string, present, ok bool) {
+	auth := r.Header.Get(gin.HeaderAuthorization)
+	const prefix = "Bearer "
+	if len(auth) < len(prefix) || !strings.EqualFold(auth[:len(prefix)], prefix) {
+		return "", false, false
+	}
+	token = strings.TrimSpace(auth[len(prefix):])
+	if token == "" || strings.ContainsAny(token, " \t,") {
+		return "", true, false
+	}
+	return token, true, true
+}
+
+func unauthorized(ctx *gin.Context, code int, challenge string) {
+	ctx.ResponseWriter.Header().Set(gin.HeaderWWWAuthenticate, challenge)
+	if code == http.StatusBadRequest {
+		ctx.AbortWithError(code, "400 bad request")
+		return
+	}
+	ctx.AbortWithError(code, "401 unauthorized")
+}
+
+// bearerChallenge returns the Bearer challenge with realm, scope and the other params (name, value pairs).
+func bearerChallenge(realm, scope string, params ...string) string {
+	var pairs []string
+	if realm != "" {
+		pairs = append(pairs, "realm="+quoteAuthParam(realm))
+	}
+	if scope != "" {
+		pairs = append(pairs, "scope="+quoteAuthParam(scope))
+	}
+	for i := 0; i+1 < len(params); i += 2 {
+		pairs = append(pairs, params[i]+"="+quoteAuthParam(params[i+1]))
+	}
+	if len(pairs) == 0 {
+		return "Bearer"
+	}
+	return "Bearer " + strings.Join(pairs, ", ")
+}
+
+// quoteAuthParam returns the quoted-string of s.
+func quoteAuthParam(s string) string {
+	s = strings.Replace(s, `\`, `\\`, -1)
+	s = strings.Replace(s, `"`, `\"`, -1)
+	return `"` + s + `"`
+}
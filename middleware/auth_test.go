@@ -0,0 +1,145 @@
+// Copyright 2014 Manu Martinez-Almeida.  All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/chanxuehong/gin"
+)
+
+func TestBasicAuth(t *testing.T) {
+	engine := gin.New()
+	engine.Use(BasicAuth(Accounts{"admin": "secret", "guest": ""}, `my "realm"`))
+	engine.Get("/", func(ctx *gin.Context) { ctx.String(200, "%s", AuthUser(ctx)) })
+
+	const challenge = `Basic realm="my \"realm\"", charset="UTF-8"`
+	tests := []struct {
+		name     string
+		auth     bool
+		user     string
+		password string
+		code     int
+		body     string
+	}{
+		{"valid", true, "admin", "secret", 200, "admin"},
+		{"empty password", true, "guest", "", 200, "guest"},
+		{"wrong password", true, "admin", "secret2", 401, ""},
+		{"password prefix", true, "admin", "secre", 401, ""},
+		// the unknown user is compared with the dummy password in constant time
+		{"unknown user", true, "root", "secret", 401, ""},
+		{"unknown user without password", true, "root", "", 401, ""},
+		{"empty user", true, "", "", 401, ""},
+		{"no credentials", false, "", "", 401, ""},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		if tt.auth {
+			r.SetBasicAuth(tt.user, tt.password)
+		}
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, r)
+		if w.Code != tt.code {
+			t.Errorf("%s: got %d, want %d", tt.name, w.Code, tt.code)
+		}
+		if tt.code == 200 {
+			if w.Body.String() != tt.body || w.Header().Get(gin.HeaderWWWAuthenticate) != "" {
+				t.Errorf("%s: got body %q WWW-Authenticate %q", tt.name, w.Body.String(), w.Header().Get(gin.HeaderWWWAuthenticate))
+			}
+		} else if got := w.Header().Get(gin.HeaderWWWAuthenticate); got != challenge {
+			t.Errorf("%s: got WWW-Authenticate %q, want %q", tt.name, got, challenge)
+		}
+	}
+}
+
+func TestBasicAuthDefaultRealm(t *testing.T) {
+	engine := gin.New()
+	engine.Use(BasicAuth(Accounts{"admin": "secret"}, ""))
+	engine.Get("/", func(ctx *gin.Context) {})
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if got, want := w.Header().Get(gin.HeaderWWWAuthenticate), `Basic realm="Authorization Required", charset="UTF-8"`; got != want {
+		t.Errorf("got WWW-Authenticate %q, want %q", got, want)
+	}
+}
+
+func TestBasicAuthInvalid(t *testing.T) {
+	for _, accounts := range []Accounts{nil, {"": "secret"}} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("BasicAuth(%v) did not panic", accounts)
+				}
+			}()
+			BasicAuth(accounts, "")
+		}()
+	}
+}
+
+func TestBearerAuth(t *testing.T) {
+	engine := gin.New()
+	engine.Use(BearerAuthWithConfig(BearerAuthConfig{
+		Validator: func(_ *gin.Context, token string) (interface{}, bool) {
+			if token == "good" {
+				return 42, true
+			}
+			return nil, false
+		},
+		Realm: "api",
+		Scope: "read write",
+	}))
+	engine.Get("/", func(ctx *gin.Context) {
+		principal, ok := AuthPrincipal(ctx)
+		if !ok || principal != 42 {
+			t.Errorf("got principal %v %t", principal, ok)
+		}
+		ctx.String(200, "ok")
+	})
+
+	tests := []struct {
+		auth      string
+		code      int
+		challenge string
+	}{
+		{"Bearer good", 200, ""},
+		{"bearer  good ", 200, ""},
+		{"", 401, `Bearer realm="api", scope="read write"`},
+		{"Basic Z29vZA==", 401, `Bearer realm="api", scope="read write"`},
+		{"Bearer bad", 401, `Bearer realm="api", scope="read write", error="invalid_token"`},
+		{"Bearer ", 400, `Bearer realm="api", scope="read write", error="invalid_request"`},
+		{"Bearer good extra", 400, `Bearer realm="api", scope="read write", error="invalid_request"`},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		if tt.auth != "" {
+			r.Header.Set(gin.HeaderAuthorization, tt.auth)
+		}
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, r)
+		if got := w.Header().Get(gin.HeaderWWWAuthenticate); w.Code != tt.code || got != tt.challenge {
+			t.Errorf("Authorization %q: got %d %q, want %d %q", tt.auth, w.Code, got, tt.code, tt.challenge)
+		}
+	}
+}
+
+func TestBearerChallenge(t *testing.T) {
+	tests := []struct {
+		realm  string
+		scope  string
+		params []string
+		want   string
+	}{
+		{"", "", nil, "Bearer"},
+		{"", "", []string{"error", "invalid_token"}, `Bearer error="invalid_token"`},
+		{`a\b`, "", nil, `Bearer realm="a\\b"`},
+	}
+	for _, tt := range tests {
+		if got := bearerChallenge(tt.realm, tt.scope, tt.params...); got != tt.want {
+			t.Errorf("bearerChallenge(%q, %q, %q) got %q, want %q", tt.realm, tt.scope, tt.params, got, tt.want)
+		}
+	}
+}
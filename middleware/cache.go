@@ -0,0 +1,467 @@
+// Copyright 2014 Manu Martinez-Almeida.  All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package middleware
+
+import (
+	"container/list"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chanxuehong/gin"
+	"github.com/chanxuehong/gin/internal/response"
+)
+
+// CachedResponse is the response stored in the CacheStore, it must not be modified after it is stored.
+type CachedResponse struct {
+	Status  int
+	Header  http.Header
+	Body    []byte
+	Vary    map[string]string // the request headers the response varies on (by the Vary header), name --> value
+	Created time.Time
+}
+
+// matches reports whether the request headers the response varies on are the same as r.
+func (resp *CachedResponse) matches(r *http.Request) bool {
+	for name, value := range resp.Vary {
+		if headerValue(r.Header, name) != value {
+			return false
+		}
+	}
+	return true
+}
+
+func headerValue(header http.Header, name string) string {
+	return strings.Join(header.Values(name), ", ")
+}
+
+// CacheStore is the storage of the cached responses, it must be safe for concurrent use.
+// A shared backend (e.g. redis) can be plugged in by implementing it.
+type CacheStore interface {
+	// Get returns the response of key, it returns nil if the response is not found or is expired.
+	Get(key string) (*CachedResponse, error)
+
+	// Set stores the response of key for ttl.
+	Set(key string, response *CachedResponse, ttl time.Duration) error
+
+	// Delete deletes the response of key.
+	Delete(key string) error
+}
+
+// CacheConfig is the configuration of Cache.
+type CacheConfig struct {
+	// TTL is the lifetime of the responses without the s-maxage or max-age directive of the Cache-Control header.
+	// Default is 1 minute.
+	TTL time.Duration
+
+	// QueryParams are the query parameters included in the key of cache.
+	// Default (nil) includes all the query parameters, an empty non-nil slice ignores the query string.
+	QueryParams []string
+
+	// VaryHeaders are the request headers included in the key of cache, e.g. Accept-Language,
+	// the responses for the different values are cached separately.
+	//
+	// The Vary header of response is always respected: a cached response is used only if the request headers
+	// it varies on are the same as the current request, even if they are not in VaryHeaders.
+	VaryHeaders []string
+
+	// Statuses are the cacheable status codes. Default is [200].
+	Statuses []int
+
+	// MaxBodySize is the max size of the cacheable response body. Default is 1 MiB.
+	MaxBodySize int
+
+	// Store stores the responses. Default is a NewMemoryCacheStore(0).
+	// If Store returns an error, the request is handled as if the response is not cached.
+	Store CacheStore
+}
+
+// Cache returns a middleware that caches the responses of GET and HEAD requests, the cached responses
+// are served without calling the handlers. The concurrent requests of the same key, which are not cached,
+// are coalesced: the first request calls the handlers, and the others wait for and share its response.
+//
+// The responses are cached as a shared cache by the Cache-Control header:
+//   - the request with no-store is neither served from nor stored in the cache;
+//   - the request with no-cache or max-age=0 is not served from the cache, but its response is stored;
+//   - the request with max-age=N is served only by the response cached in N seconds;
+//   - the response with no-store, no-cache or private, the response with the Set-Cookie header,
+//     and the response with Vary: * are not stored;
+//   - the response to the request with the Authorization header is stored only if it has public or s-maxage;
+//   - the response is stored for s-maxage or max-age if it has, otherwise for CacheConfig.TTL.
+//
+// The cached response has the Age header, and is answered with 304 Not Modified if it matches
+// the If-None-Match or If-Modified-Since header.
+func Cache(config CacheConfig) gin.HandlerFunc {
+	if config.TTL <= 0 {
+		config.TTL = time.Minute
+	}
+	if config.MaxBodySize <= 0 {
+		config.MaxBodySize = 1 << 20
+	}
+	if config.Store == nil {
+		config.Store = NewMemoryCacheStore(0)
+	}
+	if len(config.Statuses) == 0 {
+		config.Statuses = []int{http.StatusOK}
+	}
+	varyHeaders := make([]string, len(config.VaryHeaders))
+	for i, name := range config.VaryHeaders {
+		varyHeaders[i] = http.CanonicalHeaderKey(name)
+	}
+	statuses := make(map[int]bool, len(config.Statuses))
+	for _, status := range config.Statuses {
+		statuses[status] = true
+	}
+	flights := &cacheFlights{m: make(map[string]*cacheFlight)}
+
+	return func(ctx *gin.Context) {
+		r := ctx.Request
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			ctx.Next()
+			return
+		}
+		directives := parseCacheControl(r.Header.Get(gin.HeaderCacheControl))
+		if _, ok := directives["no-store"]; ok {
+			ctx.Next()
+			return
+		}
+		key := cacheKey(r, config.QueryParams, varyHeaders)
+
+		_, noCache := directives["no-cache"]
+		maxAge := -1
+		if value, ok := directives["max-age"]; ok {
+			if n, err := strconv.Atoi(value); err == nil && n >= 0 {
+				maxAge = n
+			}
+		}
+		if !noCache && maxAge != 0 {
+			resp, err := config.Store.Get(key)
+			if err == nil && resp != nil && resp.matches(r) &&
+				(maxAge < 0 || time.Since(resp.Created) <= time.Duration(maxAge)*time.Second) {
+				serveCachedResponse(ctx, resp)
+				return
+			}
+		}
+
+		flight, leader := flights.join(key)
+		if !leader {
+			select {
+			case <-flight.done:
+				if resp := flight.response; resp != nil && resp.matches(r) {
+					serveCachedResponse(ctx, resp)
+					return
+				}
+				ctx.Next() // the response of the first request is not cacheable
+			case <-r.Context().Done():
+				ctx.Abort()
+			}
+			return
+		}
+
+		var cached *CachedResponse
+		w := ctx.ResponseWriter
+		before := w.Header().Clone()
+		cw := response.NewCaptureWriter(w, false, config.MaxBodySize)
+		ctx.ResponseWriter = cw
+		defer func() {
+			ctx.ResponseWriter = w
+			flights.finish(key, flight, cached)
+		}()
+		ctx.Next()
+
+		if !cw.WroteHeader() || cw.Overflowed() || !statuses[cw.Status()] {
+			return
+		}
+		ttl, ok := cacheTTL(r, w.Header(), config.TTL)
+		if !ok {
+			return
+		}
+		vary, ok := cacheVary(r, w.Header())
+		if !ok {
+			return
+		}
+		cached = &CachedResponse{
+			Status:  cw.Status(),
+			Header:  changedHeader(before, w.Header()),
+			Body:    append([]byte(nil), cw.Body()...),
+			Vary:    vary,
+			Created: time.Now(),
+		}
+		config.Store.Set(key, cached, ttl) // the response has been written, the error can only be ignored
+	}
+}
+
+// cacheKey returns the key of the request: method, host, path, the query parameters and the vary headers.
+func cacheKey(r *http.Request, queryParams, varyHeaders []string) string {
+	var b strings.Builder
+	b.WriteString(r.Method)
+	b.WriteByte(' ')
+	b.WriteString(r.Host)
+	b.WriteString(r.URL.EscapedPath())
+
+	query := r.URL.Query()
+	if queryParams != nil {
+		selected := make(url.Values, len(queryParams))
+		for _, name := range queryParams {
+			if values, ok := query[name]; ok {
+				selected[name] = values
+			}
+		}
+		query = selected
+	}
+	if len(query) > 0 {
+		b.WriteByte('?')
+		b.WriteString(query.Encode()) // sorted by name
+	}
+	for _, name := range varyHeaders {
+		b.WriteByte('\n')
+		b.WriteString(name)
+		b.WriteString(": ")
+		b.WriteString(headerValue(r.Header, name))
+	}
+	return b.String()
+}
+
+// cacheTTL returns the lifetime of the response, it returns false if the response can not be stored.
+func cacheTTL(r *http.Request, header http.Header, defaultTTL time.Duration) (time.Duration, bool) {
+	if _, ok := header[gin.HeaderSetCookie]; ok {
+		return 0, false
+	}
+	directives := parseCacheControl(header.Get(gin.HeaderCacheControl))
+	for _, name := range [...]string{"no-store", "no-cache", "private"} {
+		if _, ok := directives[name]; ok {
+			return 0, false
+		}
+	}
+	sMaxAge, hasSMaxAge := directives["s-maxage"]
+	if r.Header.Get(gin.HeaderAuthorization) != "" {
+		if _, public := directives["public"]; !public && !hasSMaxAge {
+			return 0, false
+		}
+	}
+	maxAge, ok := sMaxAge, hasSMaxAge
+	if !ok {
+		maxAge, ok = directives["max-age"]
+	}
+	if !ok {
+		return defaultTTL, true
+	}
+	seconds, err := strconv.Atoi(maxAge)
+	if err != nil || seconds <= 0 {
+		return 0, false
+	}
+	return time.Duration(seconds) * time.Second, true
+}
+
+// cacheVary returns the request headers the response varies on, it returns false for Vary: *.
+func cacheVary(r *http.Request, header http.Header) (map[string]string, bool) {
+	var vary map[string]string
+	for _, value := range header.Values(gin.HeaderVary) {
+		for _, name := range strings.Split(value, ",") {
+			name = strings.TrimSpace(name)
+			if name == "" {
+				continue
+			}
+			if name == "*" {
+				return nil, false
+			}
+			if vary == nil {
+				vary = make(map[string]string)
+			}
+			name = http.CanonicalHeaderKey(name)
+			vary[name] = headerValue(r.Header, name)
+		}
+	}
+	return vary, true
+}
+
+// changedHeader returns the header fields set by the handlers, the fields set before them (e.g. by the
+// previous middlewares) are not stored, so that they are not replaced by the stale values.
+func changedHeader(before, after http.Header) http.Header {
+	header := make(http.Header, len(after))
+	for name, values := range after {
+		if old, ok := before[name]; ok && equalStrings(old, values) {
+			continue
+		}
+		header[name] = append([]string(nil), values...)
+	}
+	return header
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// parseCacheControl parses the Cache-Control header, lowercase directive name --> unquoted value.
+func parseCacheControl(value string) map[string]string {
+	if value == "" {
+		return nil
+	}
+	directives := make(map[string]string)
+	for _, directive := range strings.Split(value, ",") {
+		directive = strings.TrimSpace(directive)
+		if directive == "" {
+			continue
+		}
+		name, value := directive, ""
+		if i := strings.IndexByte(directive, '='); i >= 0 {
+			name, value = strings.TrimSpace(directive[:i]), strings.Trim(strings.TrimSpace(directive[i+1:]), `"`)
+		}
+		directives[strings.ToLower(name)] = value
+	}
+	return directives
+}
+
+func serveCachedResponse(ctx *gin.Context, resp *CachedResponse) {
+	ctx.Abort()
+	header := ctx.ResponseWriter.Header()
+	for name, values := range resp.Header {
+		header[name] = append([]string(nil), values...)
+	}
+	header.Set(gin.HeaderAge, strconv.FormatInt(int64(time.Since(resp.Created)/time.Second), 10))
+	if resp.Status == http.StatusOK {
+		if etag := resp.Header.Get(gin.HeaderETag); etag != "" && ctx.CheckETag(etag) {
+			return
+		}
+		if modtime, err := http.ParseTime(resp.Header.Get(gin.HeaderLastModified)); err == nil && ctx.CheckLastModified(modtime) {
+			return
+		}
+	}
+	ctx.ResponseWriter.WriteHeader(resp.Status)
+	if ctx.Request.Method != http.MethodHead {
+		ctx.ResponseWriter.Write(resp.Body)
+	}
+}
+
+// cacheFlight is the in-flight request of a key, the concurrent requests of the key wait for it.
+type cacheFlight struct {
+	done     chan struct{}
+	response *CachedResponse // nil if the response is not cacheable
+}
+
+type cacheFlights struct {
+	mu sync.Mutex
+	m  map[string]*cacheFlight
+}
+
+// join returns the in-flight request of key, it returns true if the caller is the first request
+// and must call finish() after the response is generated.
+func (flights *cacheFlights) join(key string) (*cacheFlight, bool) {
+	flights.mu.Lock()
+	defer flights.mu.Unlock()
+
+	if flight, ok := flights.m[key]; ok {
+		return flight, false
+	}
+	flight := &cacheFlight{done: make(chan struct{})}
+	flights.m[key] = flight
+	return flight, true
+}
+
+func (flights *cacheFlights) finish(key string, flight *cacheFlight, response *CachedResponse) {
+	flights.mu.Lock()
+	delete(flights.m, key)
+	flights.mu.Unlock()
+
+	flight.response = response
+	close(flight.done)
+}
+
+// ================================================================================================================
+
+const __defaultCacheEntries = 1024
+
+// memoryCacheStore is the in-memory CacheStore, the least recently used responses are evicted
+// if the number of responses exceeds maxEntries.
+type memoryCacheStore struct {
+	mu         sync.Mutex
+	maxEntries int
+	lru        *list.List // of *memoryCacheEntry, the front is the most recently used
+	entries    map[string]*list.Element
+	now        func() time.Time
+}
+
+type memoryCacheEntry struct {
+	key      string
+	response *CachedResponse
+	expires  time.Time
+}
+
+// NewMemoryCacheStore returns an in-memory CacheStore which holds at most maxEntries responses,
+// the default number is used if maxEntries <= 0. The memory used is bounded by maxEntries
+// times CacheConfig.MaxBodySize.
+func NewMemoryCacheStore(maxEntries int) CacheStore {
+	if maxEntries <= 0 {
+		maxEntries = __defaultCacheEntries
+	}
+	return &memoryCacheStore{
+		maxEntries: maxEntries,
+		lru:        list.New(),
+		entries:    make(map[string]*list.Element),
+		now:        time.Now,
+	}
+}
+
+func (store *memoryCacheStore) Get(key string) (*CachedResponse, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	elem, ok := store.entries[key]
+	if !ok {
+		return nil, nil
+	}
+	entry := elem.Value.(*memoryCacheEntry)
+	if !store.now().Before(entry.expires) {
+		store.remove(elem)
+		return nil, nil
+	}
+	store.lru.MoveToFront(elem)
+	return entry.response, nil
+}
+
+func (store *memoryCacheStore) Set(key string, response *CachedResponse, ttl time.Duration) error {
+	expires := store.now().Add(ttl)
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if elem, ok := store.entries[key]; ok {
+		entry := elem.Value.(*memoryCacheEntry)
+		entry.response, entry.expires = response, expires
+		store.lru.MoveToFront(elem)
+		return nil
+	}
+	store.entries[key] = store.lru.PushFront(&memoryCacheEntry{key: key, response: response, expires: expires})
+	for store.lru.Len() > store.maxEntries {
+		store.remove(store.lru.Back())
+	}
+	return nil
+}
+
+func (store *memoryCacheStore) Delete(key string) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if elem, ok := store.entries[key]; ok {
+		store.remove(elem)
+	}
+	return nil
+}
+
+func (store *memoryCacheStore) remove(elem *list.Element) {
+	store.lru.Remove(elem)
+	delete(store.entries, elem.Value.(*memoryCacheEntry).key)
+}
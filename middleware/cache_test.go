@@ -0,0 +1,255 @@
+// Copyright 2014 Manu Martinez-Almeida.  All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/chanxuehong/gin"
+)
+
+func TestMemoryCacheStore(t *testing.T) {
+	now := time.Unix(1000, 0)
+	store := NewMemoryCacheStore(2).(*memoryCacheStore)
+	store.now = func() time.Time { return now }
+	get := func(key string) bool {
+		resp, err := store.Get(key)
+		return err == nil && resp != nil
+	}
+
+	store.Set("a", &CachedResponse{Status: 200}, time.Minute)
+	store.Set("b", &CachedResponse{Status: 200}, time.Minute)
+	if !get("a") || !get("b") || get("c") {
+		t.Fatal("unexpected entries")
+	}
+
+	// "a" is used recently, "b" is evicted
+	get("a")
+	store.Set("c", &CachedResponse{Status: 200}, time.Minute)
+	if !get("a") || get("b") || !get("c") {
+		t.Errorf("got a=%t b=%t c=%t, want b evicted", get("a"), get("b"), get("c"))
+	}
+
+	// the expired entry is not returned
+	store.Set("a", &CachedResponse{Status: 200}, 2*time.Minute)
+	now = now.Add(time.Minute)
+	if !get("a") || get("c") {
+		t.Errorf("got a=%t c=%t after a minute", get("a"), get("c"))
+	}
+	if store.lru.Len() != 1 || len(store.entries) != 1 {
+		t.Errorf("got %d entries, want the expired removed", store.lru.Len())
+	}
+
+	store.Delete("a")
+	if get("a") {
+		t.Error("the deleted entry is returned")
+	}
+}
+
+func TestCache(t *testing.T) {
+	var calls int32
+	engine := gin.New()
+	engine.Use(Cache(CacheConfig{QueryParams: []string{"id"}}))
+	engine.Get("/items", func(ctx *gin.Context) {
+		n := atomic.AddInt32(&calls, 1)
+		ctx.ResponseWriter.Header().Set(gin.HeaderETag, `"v1"`)
+		ctx.String(200, "item %s #%d", ctx.Query("id"), n)
+	})
+
+	tests := []struct {
+		method       string
+		path         string
+		cacheControl string
+		inm          string
+		code         int
+		body         string
+		calls        int32
+	}{
+		{"GET", "/items?id=1", "", "", 200, "item 1 #1", 1},
+		{"GET", "/items?id=1", "", "", 200, "item 1 #1", 1},
+		// the other query parameters are not in the key
+		{"GET", "/items?id=1&x=2", "", "", 200, "item 1 #1", 1},
+		{"GET", "/items?id=2", "", "", 200, "item 2 #2", 2},
+		{"GET", "/items?id=1", "", `"v1"`, 304, "", 2},
+		// the request directives
+		{"GET", "/items?id=1", "no-cache", "", 200, "item 1 #3", 3},
+		{"GET", "/items?id=1", "", "", 200, "item 1 #3", 3},
+		{"GET", "/items?id=1", "max-age=0", "", 200, "item 1 #4", 4},
+		{"GET", "/items?id=1", "no-store", "", 200, "item 1 #5", 5},
+		{"GET", "/items?id=1", "max-age=60", "", 200, "item 1 #4", 5},
+	}
+	for i, tt := range tests {
+		r := httptest.NewRequest(tt.method, tt.path, nil)
+		if tt.cacheControl != "" {
+			r.Header.Set(gin.HeaderCacheControl, tt.cacheControl)
+		}
+		if tt.inm != "" {
+			r.Header.Set(gin.HeaderIfNoneMatch, tt.inm)
+		}
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, r)
+		if w.Code != tt.code || w.Body.String() != tt.body || calls != tt.calls {
+			t.Errorf("#%d %s %s: got %d %q after %d calls, want %d %q after %d calls", i, tt.method, tt.path,
+				w.Code, w.Body.String(), calls, tt.code, tt.body, tt.calls)
+		}
+	}
+}
+
+func TestCacheStorable(t *testing.T) {
+	tests := []struct {
+		name          string
+		cacheControl  string
+		setCookie     bool
+		authorization bool
+		status        int
+		stored        bool
+	}{
+		{"default", "", false, false, 200, true},
+		{"max-age", "max-age=60", false, false, 200, true},
+		{"no-store", "no-store", false, false, 200, false},
+		{"no-cache", "no-cache", false, false, 200, false},
+		{"private", "private, max-age=60", false, false, 200, false},
+		{"max-age=0", "max-age=0", false, false, 200, false},
+		{"set-cookie", "public, max-age=60", true, false, 200, false},
+		{"status", "", false, false, 500, false},
+		{"authorization", "", false, true, 200, false},
+		{"authorization max-age", "max-age=60", false, true, 200, false},
+		{"authorization public", "public", false, true, 200, true},
+		{"authorization s-maxage", "s-maxage=60", false, true, 200, true},
+	}
+	for _, tt := range tests {
+		calls := 0
+		engine := gin.New()
+		engine.Use(Cache(CacheConfig{}))
+		engine.Get("/", func(ctx *gin.Context) {
+			calls++
+			if tt.cacheControl != "" {
+				ctx.ResponseWriter.Header().Set(gin.HeaderCacheControl, tt.cacheControl)
+			}
+			if tt.setCookie {
+				ctx.SetCookie(&http.Cookie{Name: "sid", Value: "secret"})
+			}
+			ctx.String(tt.status, "ok")
+		})
+		for i := 0; i < 2; i++ {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.authorization {
+				r.Header.Set(gin.HeaderAuthorization, "Bearer token")
+			}
+			engine.ServeHTTP(httptest.NewRecorder(), r)
+		}
+		if stored := calls == 1; stored != tt.stored {
+			t.Errorf("%s: got stored %t, want %t", tt.name, stored, tt.stored)
+		}
+	}
+}
+
+func TestCacheVary(t *testing.T) {
+	for _, varyHeaders := range [][]string{nil, {"accept-language"}} {
+		calls := 0
+		engine := gin.New()
+		engine.Use(Cache(CacheConfig{VaryHeaders: varyHeaders}))
+		engine.Get("/", func(ctx *gin.Context) {
+			calls++
+			ctx.ResponseWriter.Header().Set(gin.HeaderVary, "Accept-Language")
+			ctx.String(200, "hello %s", ctx.Request.Header.Get("Accept-Language"))
+		})
+		engine.Get("/any", func(ctx *gin.Context) {
+			calls++
+			ctx.ResponseWriter.Header().Set(gin.HeaderVary, "*")
+			ctx.String(200, "any")
+		})
+
+		serve := func(path, lang string) string {
+			r := httptest.NewRequest(http.MethodGet, path, nil)
+			r.Header.Set("Accept-Language", lang)
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, r)
+			return w.Body.String()
+		}
+		for _, lang := range []string{"en", "fr", "en", "fr"} {
+			if body := serve("/", lang); body != "hello "+lang {
+				t.Errorf("VaryHeaders %v: got %q for %s", varyHeaders, body, lang)
+			}
+		}
+		// the variants replace each other if the vary header is not in the key
+		want := 2
+		if varyHeaders == nil {
+			want = 4
+		}
+		if calls != want {
+			t.Errorf("VaryHeaders %v: got %d calls, want %d", varyHeaders, calls, want)
+		}
+
+		calls = 0
+		serve("/any", "en")
+		serve("/any", "en")
+		if calls != 2 {
+			t.Errorf("VaryHeaders %v: the response of Vary: * is stored", varyHeaders)
+		}
+	}
+}
+
+func TestCacheCoalescing(t *testing.T) {
+	var calls int32
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	engine := gin.New()
+	engine.Use(Cache(CacheConfig{}))
+	engine.Get("/slow", func(ctx *gin.Context) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			close(entered)
+		}
+		<-release
+		ctx.String(200, "slow")
+	})
+
+	const n = 8
+	bodies := make(chan string, n)
+	var wg sync.WaitGroup
+	serve := func() {
+		defer wg.Done()
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/slow", nil))
+		bodies <- w.Body.String()
+	}
+	wg.Add(n)
+	go serve()
+	<-entered
+	for i := 1; i < n; i++ {
+		go serve()
+	}
+	time.Sleep(20 * time.Millisecond) // the followers wait for the first request or hit the cache
+	close(release)
+	wg.Wait()
+	close(bodies)
+
+	for body := range bodies {
+		if body != "slow" {
+			t.Errorf("got body %q", body)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("the handler is called %d times, want once", calls)
+	}
+}
+
+func TestParseCacheControl(t *testing.T) {
+	directives := parseCacheControl(`Public, MAX-AGE=60, s-maxage="120", no-cache=" Set-Cookie",,`)
+	want := map[string]string{"public": "", "max-age": "60", "s-maxage": "120", "no-cache": " Set-Cookie"}
+	if len(directives) != len(want) {
+		t.Fatalf("got %v, want %v", directives, want)
+	}
+	for name, value := range want {
+		if got, ok := directives[name]; !ok || got != value {
+			t.Errorf("got %s=%q, want %q", name, got, value)
+		}
+	}
+}
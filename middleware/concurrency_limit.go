@@ -0,0 +1,209 @@
+// Copyright 2014 Manu Martinez-Almeida.  All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/chanxuehong/gin"
+)
+
+// ConcurrencyLimitConfig is the configuration of ConcurrencyLimit.
+type ConcurrencyLimitConfig struct {
+	// MaxInFlight is the max number of requests being handled concurrently, it is required.
+	// It is the initial limit if Adaptive is not nil.
+	MaxInFlight int
+
+	// MaxQueue is the max number of requests waiting for a slot when MaxInFlight is reached,
+	// the requests exceeding it are shed immediately. Default is 0, no request waits.
+	MaxQueue int
+
+	// MaxWait is the max time a request waits in the queue, the request is shed after it.
+	// Default is 1 second.
+	MaxWait time.Duration
+
+	// RetryAfter is the value of the Retry-After header of the shed requests.
+	// Default is 1 second.
+	RetryAfter time.Duration
+
+	// Adaptive adjusts the limit by the observed latency if it is not nil.
+	Adaptive *AdaptiveLimit
+
+	// Shed is called when the request is shed, the handler chain is aborted after it returns.
+	// The Retry-After header has been set. Default writes 503 Service Unavailable.
+	Shed gin.HandlerFunc
+}
+
+// AdaptiveLimit adjusts the limit of in-flight requests by AIMD (additive increase, multiplicative decrease):
+// the limit is increased by Increase per limit requests whose latency is within Target,
+// and multiplied by Decrease when the latency of a request exceeds Target.
+//
+// The limit is decreased at most once per latency window, i.e. the slow requests started before the last
+// decrease do not decrease it again, so that a burst of concurrent slow requests backs off only once.
+type AdaptiveLimit struct {
+	MinLimit int           // default is 1
+	MaxLimit int           // default is 10 * ConcurrencyLimitConfig.MaxInFlight
+	Target   time.Duration // the target latency, it is required
+	Increase float64       // default is 1
+	Decrease float64       // in (0, 1), default is 0.9
+}
+
+// ConcurrencyLimit returns a middleware that limits the number of requests being handled concurrently.
+// When the limit is reached, at most MaxQueue requests wait for at most MaxWait in FIFO order,
+// and the rest are shed with 503 and the Retry-After header. The time a request waited is got by Context.QueueTime().
+//
+// Each call returns a middleware with its own limit, so it can limit the whole engine or a route group:
+//     engine.Pre(middleware.ConcurrencyLimit(middleware.ConcurrencyLimitConfig{MaxInFlight: 1000}))
+//     reports := engine.Group("/reports", middleware.ConcurrencyLimit(middleware.ConcurrencyLimitConfig{
+//         MaxInFlight: 10,
+//         MaxQueue:    100,
+//         MaxWait:     2 * time.Second,
+//     }))
+func ConcurrencyLimit(config ConcurrencyLimitConfig) gin.HandlerFunc {
+	if config.MaxInFlight <= 0 {
+		panic("MaxInFlight must be greater than 0")
+	}
+	if config.MaxQueue < 0 {
+		panic("MaxQueue can not be negative")
+	}
+	if config.MaxWait <= 0 {
+		config.MaxWait = time.Second
+	}
+	if config.RetryAfter <= 0 {
+		config.RetryAfter = time.Second
+	}
+	shed := config.Shed
+	if shed == nil {
+		shed = func(ctx *gin.Context) {
+			ctx.String(http.StatusServiceUnavailable, "503 service unavailable")
+		}
+	}
+	limiter := newConcurrencyLimiter(config)
+	retryAfter := ceilSeconds(config.RetryAfter)
+
+	return func(ctx *gin.Context) {
+		queueTime, ok := limiter.acquire(ctx.Request)
+		if queueTime > 0 {
+			ctx.Set(gin.KeyQueueTime, queueTime)
+		}
+		if !ok {
+			ctx.ResponseWriter.Header().Set(gin.HeaderRetryAfter, retryAfter)
+			shed(ctx)
+			ctx.Abort()
+			return
+		}
+		start := time.Now()
+		defer func() {
+			limiter.release(start, time.Since(start))
+		}()
+		ctx.Next()
+	}
+}
+
+type concurrencyLimiter struct {
+	mu       sync.Mutex
+	inFlight int
+	limit    float64
+	waiters  []chan struct{} // FIFO, a waiter is granted by closing its channel
+	maxQueue int
+	maxWait  time.Duration
+	adaptive *AdaptiveLimit
+
+	lastDecrease time.Time // the time of the last decrease of limit
+}
+
+func newConcurrencyLimiter(config ConcurrencyLimitConfig) *concurrencyLimiter {
+	limiter := &concurrencyLimiter{
+		limit:    float64(config.MaxInFlight),
+		maxQueue: config.MaxQueue,
+		maxWait:  config.MaxWait,
+	}
+	if config.Adaptive != nil {
+		adaptive := *config.Adaptive
+		if adaptive.Target <= 0 {
+			panic("Target of AdaptiveLimit must be greater than 0")
+		}
+		if adaptive.MinLimit <= 0 {
+			adaptive.MinLimit = 1
+		}
+		if adaptive.MaxLimit <= 0 {
+			adaptive.MaxLimit = 10 * config.MaxInFlight
+		}
+		if adaptive.MinLimit > adaptive.MaxLimit {
+			panic("MinLimit of AdaptiveLimit can not be greater than MaxLimit")
+		}
+		if adaptive.Increase <= 0 {
+			adaptive.Increase = 1
+		}
+		if adaptive.Decrease <= 0 || adaptive.Decrease >= 1 {
+			adaptive.Decrease = 0.9
+		}
+		limiter.adaptive = &adaptive
+	}
+	return limiter
+}
+
+// acquire acquires a slot for r, it returns false if r is shed.
+func (limiter *concurrencyLimiter) acquire(r *http.Request) (queueTime time.Duration, ok bool) {
+	limiter.mu.Lock()
+	if len(limiter.waiters) == 0 && limiter.inFlight < int(limiter.limit) {
+		limiter.inFlight++
+		limiter.mu.Unlock()
+		return 0, true
+	}
+	if len(limiter.waiters) >= limiter.maxQueue {
+		limiter.mu.Unlock()
+		return 0, false
+	}
+	ch := make(chan struct{})
+	limiter.waiters = append(limiter.waiters, ch)
+	limiter.mu.Unlock()
+
+	start := time.Now()
+	timer := time.NewTimer(limiter.maxWait)
+	defer timer.Stop()
+	select {
+	case <-ch:
+		return time.Since(start), true
+	case <-timer.C:
+	case <-r.Context().Done():
+	}
+
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+	for i, waiter := range limiter.waiters {
+		if waiter == ch {
+			limiter.waiters = append(limiter.waiters[:i], limiter.waiters[i+1:]...)
+			return time.Since(start), false
+		}
+	}
+	return time.Since(start), true // granted while giving up
+}
+
+// release releases the slot of a request which is started at start and handled in latency.
+func (limiter *concurrencyLimiter) release(start time.Time, latency time.Duration) {
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+
+	limiter.inFlight--
+	if adaptive := limiter.adaptive; adaptive != nil {
+		if latency <= adaptive.Target {
+			limiter.limit += adaptive.Increase / limiter.limit
+		} else if start.After(limiter.lastDecrease) {
+			limiter.limit *= adaptive.Decrease
+			limiter.lastDecrease = start.Add(latency)
+		}
+		limiter.limit = math.Max(float64(adaptive.MinLimit), math.Min(float64(adaptive.MaxLimit), limiter.limit))
+	}
+	for len(limiter.waiters) > 0 && limiter.inFlight < int(limiter.limit) {
+		close(limiter.waiters[0])
+		limiter.waiters[0] = nil
+		limiter.waiters = limiter.waiters[1:]
+		limiter.inFlight++
+	}
+}
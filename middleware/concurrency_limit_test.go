@@ -0,0 +1,193 @@
+// Copyright 2014 Manu Martinez-Almeida.  All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/chanxuehong/gin"
+)
+
+// newBlockingEngine returns an engine whose "/block" route blocks until release is closed,
+// entered receives a value when a request enters the handler.
+func newBlockingEngine(config ConcurrencyLimitConfig) (engine *gin.Engine, entered chan time.Duration, release chan struct{}) {
+	entered = make(chan time.Duration, 10)
+	release = make(chan struct{})
+	engine = gin.New()
+	engine.Use(ConcurrencyLimit(config))
+	engine.Get("/block", func(ctx *gin.Context) {
+		entered <- ctx.QueueTime()
+		<-release
+		ctx.String(200, "ok")
+	})
+	return engine, entered, release
+}
+
+func serveAsync(engine *gin.Engine) <-chan *httptest.ResponseRecorder {
+	done := make(chan *httptest.ResponseRecorder, 1)
+	go func() {
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/block", nil))
+		done <- w
+	}()
+	return done
+}
+
+func TestConcurrencyLimitShed(t *testing.T) {
+	engine, entered, release := newBlockingEngine(ConcurrencyLimitConfig{MaxInFlight: 1, RetryAfter: 3 * time.Second})
+	first := serveAsync(engine)
+	<-entered
+
+	// the limit is reached and no request waits
+	w := <-serveAsync(engine)
+	if w.Code != http.StatusServiceUnavailable || w.Header().Get(gin.HeaderRetryAfter) != "3" {
+		t.Errorf("got %d Retry-After %q, want 503 \"3\"", w.Code, w.Header().Get(gin.HeaderRetryAfter))
+	}
+
+	close(release)
+	if w := <-first; w.Code != 200 {
+		t.Errorf("got %d for the first request", w.Code)
+	}
+	// the slot is released
+	if w := <-serveAsync(engine); w.Code != 200 {
+		t.Errorf("got %d after the slot is released", w.Code)
+	}
+}
+
+func TestConcurrencyLimitQueue(t *testing.T) {
+	engine, entered, release := newBlockingEngine(ConcurrencyLimitConfig{MaxInFlight: 1, MaxQueue: 1, MaxWait: time.Minute})
+	first := serveAsync(engine)
+	if queueTime := <-entered; queueTime != 0 {
+		t.Errorf("got queue time %s for the first request", queueTime)
+	}
+	queued := serveAsync(engine)
+
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	if w := <-first; w.Code != 200 {
+		t.Errorf("got %d for the first request", w.Code)
+	}
+	if queueTime := <-entered; queueTime <= 0 {
+		t.Errorf("got queue time %s for the queued request", queueTime)
+	}
+	if w := <-queued; w.Code != 200 {
+		t.Errorf("got %d for the queued request", w.Code)
+	}
+}
+
+func TestConcurrencyLimiterQueueFull(t *testing.T) {
+	limiter := newConcurrencyLimiter(ConcurrencyLimitConfig{MaxInFlight: 1, MaxQueue: 1, MaxWait: time.Minute})
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	if _, ok := limiter.acquire(r); !ok {
+		t.Fatal("the first request is shed")
+	}
+	queued := make(chan bool, 1)
+	go func() {
+		_, ok := limiter.acquire(r)
+		queued <- ok
+	}()
+	for waiting := 0; waiting == 0; {
+		time.Sleep(time.Millisecond)
+		limiter.mu.Lock()
+		waiting = len(limiter.waiters)
+		limiter.mu.Unlock()
+	}
+
+	// the queue is full
+	if _, ok := limiter.acquire(r); ok {
+		t.Error("the request is not shed when the queue is full")
+	}
+	limiter.release(time.Now(), 0)
+	if !<-queued {
+		t.Error("the queued request is shed")
+	}
+	limiter.release(time.Now(), 0)
+	if limiter.inFlight != 0 || len(limiter.waiters) != 0 {
+		t.Errorf("got %d in flight and %d waiters after release", limiter.inFlight, len(limiter.waiters))
+	}
+}
+
+func TestConcurrencyLimiterAdaptive(t *testing.T) {
+	limiter := newConcurrencyLimiter(ConcurrencyLimitConfig{
+		MaxInFlight: 10,
+		Adaptive:    &AdaptiveLimit{Target: 10 * time.Millisecond, MinLimit: 2, Decrease: 0.5},
+	})
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	t0 := time.Unix(1000, 0)
+	for i := 0; i < 5; i++ {
+		limiter.acquire(r)
+	}
+
+	// the concurrent slow requests decrease the limit only once
+	for i := 0; i < 5; i++ {
+		limiter.release(t0.Add(time.Duration(i)*time.Millisecond), 100*time.Millisecond)
+	}
+	if limiter.limit != 5 {
+		t.Errorf("got limit %v after the concurrent slow requests, want 5", limiter.limit)
+	}
+
+	// the slow request started after the decrease decreases it again
+	limiter.acquire(r)
+	limiter.release(t0.Add(200*time.Millisecond), 100*time.Millisecond)
+	if limiter.limit != 2.5 {
+		t.Errorf("got limit %v after the next slow request, want 2.5", limiter.limit)
+	}
+	limiter.acquire(r)
+	limiter.release(t0.Add(time.Second), 100*time.Millisecond)
+	if limiter.limit != 2 {
+		t.Errorf("got limit %v, want MinLimit 2", limiter.limit)
+	}
+
+	// the fast requests increase the limit by Increase per limit requests
+	for i := 0; i < 2; i++ {
+		limiter.acquire(r)
+		limiter.release(t0.Add(2*time.Second), time.Millisecond)
+	}
+	if limiter.limit < 2.9 || limiter.limit > 3 {
+		t.Errorf("got limit %v after the fast requests, want about 3", limiter.limit)
+	}
+}
+
+func TestConcurrencyLimitTimeout(t *testing.T) {
+	engine, entered, release := newBlockingEngine(ConcurrencyLimitConfig{MaxInFlight: 1, MaxQueue: 1, MaxWait: 20 * time.Millisecond})
+	defer close(release)
+	serveAsync(engine)
+	<-entered
+
+	start := time.Now()
+	w := <-serveAsync(engine)
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("got %d after waiting in the queue, want 503", w.Code)
+	}
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Errorf("the request is shed after %s, want at least MaxWait", elapsed)
+	}
+	select {
+	case <-entered:
+		t.Error("the timed out request is handled")
+	default:
+	}
+}
+
+func TestConcurrencyLimitInvalid(t *testing.T) {
+	for _, config := range []ConcurrencyLimitConfig{
+		{},
+		{MaxInFlight: 1, MaxQueue: -1},
+		{MaxInFlight: 1, Adaptive: &AdaptiveLimit{}},
+		{MaxInFlight: 1, Adaptive: &AdaptiveLimit{Target: time.Second, MinLimit: 5, MaxLimit: 2}},
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("ConcurrencyLimit(%+v) did not panic", config)
+				}
+			}()
+			ConcurrencyLimit(config)
+		}()
+	}
+}
@@ -0,0 +1,76 @@
+// Copyright 2014 Manu Martinez-Almeida.  All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+
+	"github.com/chanxuehong/gin"
+	"github.com/chanxuehong/gin/internal/response"
+)
+
+// ETagConfig is the configuration of ETagWithConfig.
+type ETagConfig struct {
+	// Weak generates the weak ETag, e.g. W/"...", which means the responses are semantically equivalent.
+	// Default generates the strong ETag.
+	Weak bool
+
+	// MaxSize is the max size of the body to be buffered, the response whose body exceeds it
+	// is written without ETag. Default is 1 MiB.
+	MaxSize int
+}
+
+// ETag returns a middleware that generates the strong ETag of the 200 responses of GET and HEAD requests,
+// and answers the request with 304 Not Modified if the ETag matches the If-None-Match header.
+// The response is buffered to compute the ETag, so it should not be used for streaming responses.
+// The ETag set by the handlers is kept, see also Context.CheckETag(). For HEAD requests the ETag is
+// generated only if the handler writes the body as for GET.
+func ETag() gin.HandlerFunc {
+	return ETagWithConfig(ETagConfig{})
+}
+
+// ETagWithConfig returns a middleware like ETag with the config.
+func ETagWithConfig(config ETagConfig) gin.HandlerFunc {
+	if config.MaxSize <= 0 {
+		config.MaxSize = 1 << 20
+	}
+
+	return func(ctx *gin.Context) {
+		if method := ctx.Request.Method; method != http.MethodGet && method != http.MethodHead {
+			ctx.Next()
+			return
+		}
+		w := ctx.ResponseWriter
+		cw := response.NewCaptureWriter(w, true, config.MaxSize)
+		ctx.ResponseWriter = cw
+		notModified := false
+		defer func() {
+			ctx.ResponseWriter = w
+			if !notModified {
+				cw.Commit() // also commits the response if the handler panics
+			}
+		}()
+		ctx.Next()
+
+		if !cw.Buffering() || cw.Status() != http.StatusOK {
+			return
+		}
+		etag := w.Header().Get(gin.HeaderETag)
+		if etag == "" {
+			if ctx.Request.Method == http.MethodHead && cw.Written() == 0 {
+				return // the ETag of the empty body does not match the one of GET
+			}
+			sum := sha256.Sum256(cw.Body())
+			etag = `"` + base64.RawURLEncoding.EncodeToString(sum[:16]) + `"`
+			if config.Weak {
+				etag = "W/" + etag
+			}
+		}
+		ctx.ResponseWriter = w
+		notModified = ctx.CheckETag(etag) // the buffered response is discarded
+	}
+}
@@ -0,0 +1,95 @@
+// Copyright 2014 Manu Martinez-Almeida.  All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/chanxuehong/gin"
+)
+
+func TestETag(t *testing.T) {
+	engine := gin.New()
+	engine.Use(ETagWithConfig(ETagConfig{MaxSize: 16}))
+	engine.Get("/hello", func(ctx *gin.Context) { ctx.String(200, "hello") })
+	engine.Head("/hello", func(ctx *gin.Context) { ctx.String(200, "hello") })
+	engine.Head("/empty", func(ctx *gin.Context) { ctx.ResponseWriter.WriteHeader(200) })
+	engine.Get("/custom", func(ctx *gin.Context) {
+		ctx.ResponseWriter.Header().Set(gin.HeaderETag, `"v1"`)
+		ctx.String(200, "custom")
+	})
+	engine.Get("/large", func(ctx *gin.Context) { ctx.String(200, "%s", strings.Repeat("x", 17)) })
+	engine.Get("/created", func(ctx *gin.Context) { ctx.String(201, "created") })
+	engine.Post("/hello", func(ctx *gin.Context) { ctx.String(200, "hello") })
+
+	serve := func(method, path, inm string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, path, nil)
+		if inm != "" {
+			r.Header.Set(gin.HeaderIfNoneMatch, inm)
+		}
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, r)
+		return w
+	}
+	etag := serve("GET", "/hello", "").Header().Get(gin.HeaderETag)
+	if !strings.HasPrefix(etag, `"`) || len(etag) < 3 {
+		t.Fatalf("got ETag %q", etag)
+	}
+
+	tests := []struct {
+		method string
+		path   string
+		inm    string
+		code   int
+		etag   string
+		body   string
+	}{
+		{"GET", "/hello", "", 200, etag, "hello"},
+		{"GET", "/hello", etag, 304, etag, ""},
+		{"GET", "/hello", `"other", ` + etag, 304, etag, ""},
+		{"GET", "/hello", `"other"`, 200, etag, "hello"},
+		// the HEAD response has the ETag of GET if the body is written
+		{"HEAD", "/hello", "", 200, etag, "hello"},
+		{"HEAD", "/hello", etag, 304, etag, ""},
+		// no ETag of the empty body for HEAD
+		{"HEAD", "/empty", "", 200, "", ""},
+		{"GET", "/custom", "", 200, `"v1"`, "custom"},
+		{"GET", "/custom", `"v1"`, 304, `"v1"`, ""},
+		// the body exceeds MaxSize
+		{"GET", "/large", "", 200, "", strings.Repeat("x", 17)},
+		{"GET", "/created", "", 201, "", "created"},
+		{"POST", "/hello", etag, 200, "", "hello"},
+	}
+	for _, tt := range tests {
+		w := serve(tt.method, tt.path, tt.inm)
+		if got := w.Header().Get(gin.HeaderETag); w.Code != tt.code || got != tt.etag || w.Body.String() != tt.body {
+			t.Errorf("%s %s If-None-Match %q: got %d %q %q, want %d %q %q", tt.method, tt.path, tt.inm,
+				w.Code, got, w.Body.String(), tt.code, tt.etag, tt.body)
+		}
+	}
+}
+
+func TestETagWeak(t *testing.T) {
+	engine := gin.New()
+	engine.Use(ETagWithConfig(ETagConfig{Weak: true}))
+	engine.Get("/", func(ctx *gin.Context) { ctx.String(200, "hello") })
+
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	etag := w.Header().Get(gin.HeaderETag)
+	if !strings.HasPrefix(etag, `W/"`) {
+		t.Fatalf("got ETag %q, want a weak ETag", etag)
+	}
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set(gin.HeaderIfNoneMatch, etag)
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, r)
+	if w.Code != http.StatusNotModified {
+		t.Errorf("got %d, want 304", w.Code)
+	}
+}
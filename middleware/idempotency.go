@@ -0,0 +1,337 @@
+// Copyright 2014 Manu Martinez-Almeida.  All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package middleware
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/chanxuehong/gin"
+	"github.com/chanxuehong/gin/internal/response"
+)
+
+// The max length of the idempotency key.
+const __maxIdempotencyKeyLength = 255
+
+// IdempotencyRecord is the record of an idempotency key stored in the IdempotencyStore.
+type IdempotencyRecord struct {
+	Fingerprint string // the fingerprint of the request which locks the key
+	Completed   bool   // false if the request is still in progress
+
+	// The response of the request, it is valid only if Completed.
+	Status int
+	Header http.Header
+	Body   []byte
+}
+
+// IdempotencyStore is the storage of the idempotency records, it must be safe for concurrent use.
+// A shared backend (e.g. redis) can be plugged in by implementing it, Lock must be atomic among
+// all the instances which share the store.
+type IdempotencyStore interface {
+	// Lock creates the in-progress record of key with fingerprint, which is owned by token and expires after ttl,
+	// and returns true if the key does not exist. Otherwise it returns the existing record and false.
+	Lock(key, fingerprint, token string, ttl time.Duration) (*IdempotencyRecord, bool, error)
+
+	// Complete replaces the in-progress record of key with the completed record, which expires after ttl.
+	// It must return an error and keep the existing record if the record of key is not the in-progress record
+	// owned by token, e.g. the lock has expired and the key has been locked by another request.
+	Complete(key, token string, record *IdempotencyRecord, ttl time.Duration) error
+
+	// Unlock deletes the in-progress record of key owned by token, so that the request can be retried.
+	Unlock(key, token string) error
+}
+
+// IdempotencyConfig is the configuration of Idempotency.
+type IdempotencyConfig struct {
+	// Header is the request header of the idempotency key. Default is "Idempotency-Key".
+	Header string
+
+	// Required rejects the requests without the idempotency key with 400 Bad Request.
+	Required bool
+
+	// Methods are the request methods which the middleware applies to. Default is [POST, PATCH].
+	Methods []string
+
+	// Scope returns the scope of the idempotency key, e.g. the user of request, so that the keys
+	// of different clients do not conflict. Default is no scope.
+	Scope func(ctx *gin.Context) string
+
+	// TTL is the lifetime of the stored responses. Default is 24 hours.
+	TTL time.Duration
+
+	// LockTimeout is the lifetime of the in-progress record, it should be longer than the time of handling
+	// a request, the key is unlocked after it if the server crashes. Default is 1 minute.
+	LockTimeout time.Duration
+
+	// MaxBodySize is the max size of the request body and the response body. The request with the larger body
+	// is rejected with 413 Request Entity Too Large, and the larger response is not stored. Default is 1 MiB.
+	MaxBodySize int
+
+	// Store stores the records. Default is a NewMemoryIdempotencyStore().
+	// If Store returns an error, the request is rejected with 503 Service Unavailable.
+	Store IdempotencyStore
+
+	// Rejected is called when the request is rejected with the status code, the handler chain is aborted after it returns.
+	// Default writes the status code and text.
+	Rejected func(ctx *gin.Context, code int)
+}
+
+// Idempotency returns a middleware that makes the requests with the idempotency key (see IdempotencyConfig.Header)
+// safe to be retried. The first request of a key locks the key, and its response is stored and replayed for the
+// retries of the key with the Idempotent-Replayed header.
+//
+// The retry is rejected with 409 Conflict if the first request is still in progress, and with 422 Unprocessable Entity
+// if its fingerprint (the method, the URL and the body of request) is different from the first request.
+// The response with 5xx status code is not stored and the key is unlocked, also if the handler panics,
+// so that the request can be retried.
+func Idempotency(config IdempotencyConfig) gin.HandlerFunc {
+	if config.Header == "" {
+		config.Header = gin.HeaderIdempotencyKey
+	}
+	if len(config.Methods) == 0 {
+		config.Methods = []string{http.MethodPost, http.MethodPatch}
+	}
+	if config.TTL <= 0 {
+		config.TTL = 24 * time.Hour
+	}
+	if config.LockTimeout <= 0 {
+		config.LockTimeout = time.Minute
+	}
+	if config.MaxBodySize <= 0 {
+		config.MaxBodySize = 1 << 20
+	}
+	if config.Store == nil {
+		config.Store = NewMemoryIdempotencyStore()
+	}
+	rejected := config.Rejected
+	if rejected == nil {
+		rejected = func(ctx *gin.Context, code int) {
+			ctx.String(code, "%d %s", code, http.StatusText(code))
+		}
+	}
+	methods := make(map[string]bool, len(config.Methods))
+	for _, method := range config.Methods {
+		methods[method] = true
+	}
+	reject := func(ctx *gin.Context, code int) {
+		rejected(ctx, code)
+		ctx.Abort()
+	}
+
+	return func(ctx *gin.Context) {
+		r := ctx.Request
+		if !methods[r.Method] {
+			ctx.Next()
+			return
+		}
+		key := r.Header.Get(config.Header)
+		if key == "" {
+			if config.Required {
+				reject(ctx, http.StatusBadRequest)
+				return
+			}
+			ctx.Next()
+			return
+		}
+		if len(key) > __maxIdempotencyKeyLength {
+			reject(ctx, http.StatusBadRequest)
+			return
+		}
+		fingerprint, code := requestFingerprint(r, config.MaxBodySize)
+		if code != 0 {
+			reject(ctx, code)
+			return
+		}
+		if config.Scope != nil {
+			key = config.Scope(ctx) + "\x00" + key
+		}
+
+		token, err := newIdempotencyToken()
+		if err != nil {
+			reject(ctx, http.StatusServiceUnavailable)
+			return
+		}
+		record, locked, err := config.Store.Lock(key, fingerprint, token, config.LockTimeout)
+		if err != nil {
+			reject(ctx, http.StatusServiceUnavailable)
+			return
+		}
+		if !locked {
+			switch {
+			case record.Fingerprint != fingerprint:
+				reject(ctx, http.StatusUnprocessableEntity)
+			case !record.Completed:
+				reject(ctx, http.StatusConflict)
+			default:
+				replayIdempotentResponse(ctx, record)
+			}
+			return
+		}
+
+		completed := false
+		w := ctx.ResponseWriter
+		before := w.Header().Clone()
+		cw := response.NewCaptureWriter(w, false, config.MaxBodySize)
+		ctx.ResponseWriter = cw
+		defer func() {
+			ctx.ResponseWriter = w
+			if !completed {
+				config.Store.Unlock(key, token)
+			}
+		}()
+		ctx.Next()
+
+		if !cw.WroteHeader() || cw.Overflowed() || cw.Status() >= http.StatusInternalServerError {
+			return
+		}
+		record = &IdempotencyRecord{
+			Fingerprint: fingerprint,
+			Completed:   true,
+			Status:      cw.Status(),
+			Header:      changedHeader(before, w.Header()),
+			Body:        append([]byte(nil), cw.Body()...),
+		}
+		completed = config.Store.Complete(key, token, record, config.TTL) == nil
+	}
+}
+
+// requestFingerprint returns the SHA-256 of the method, the URL and the body of r, the body is read
+// and replaced so that it can be read again by the handlers. It returns the status code if the body
+// can not be read.
+func requestFingerprint(r *http.Request, maxBodySize int) (string, int) {
+	h := sha256.New()
+	io.WriteString(h, r.Method)
+	h.Write([]byte{'\n'})
+	io.WriteString(h, r.URL.RequestURI())
+	h.Write([]byte{'\n'})
+	if r.Body != nil && r.Body != http.NoBody {
+		body, err := ioutil.ReadAll(io.LimitReader(r.Body, int64(maxBodySize)+1))
+		r.Body.Close()
+		if err != nil {
+			return "", http.StatusBadRequest
+		}
+		if len(body) > maxBodySize {
+			return "", http.StatusRequestEntityTooLarge
+		}
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+		h.Write(body)
+	}
+	return hex.EncodeToString(h.Sum(nil)), 0
+}
+
+// newIdempotencyToken returns a random token of the lock of a request.
+func newIdempotencyToken() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b[:]), nil
+}
+
+func replayIdempotentResponse(ctx *gin.Context, record *IdempotencyRecord) {
+	ctx.Abort()
+	header := ctx.ResponseWriter.Header()
+	for name, values := range record.Header {
+		header[name] = append([]string(nil), values...)
+	}
+	header.Set(gin.HeaderIdempotentReplayed, "true")
+	ctx.ResponseWriter.WriteHeader(record.Status)
+	ctx.ResponseWriter.Write(record.Body)
+}
+
+// ================================================================================================================
+
+const __idempotencySweepInterval = time.Minute
+
+// memoryIdempotencyStore is the in-memory IdempotencyStore.
+type memoryIdempotencyStore struct {
+	mu        sync.Mutex
+	records   map[string]*memoryIdempotencyRecord
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+type memoryIdempotencyRecord struct {
+	record  *IdempotencyRecord
+	token   string // the owner of the in-progress record
+	expires time.Time
+}
+
+var errIdempotencyLockLost = errors.New("middleware: the idempotency key is not locked by the request")
+
+// NewMemoryIdempotencyStore returns an in-memory IdempotencyStore, the expired records are removed periodically.
+// It can only be used by a single instance of the server.
+func NewMemoryIdempotencyStore() IdempotencyStore {
+	return &memoryIdempotencyStore{
+		records: make(map[string]*memoryIdempotencyRecord),
+		now:     time.Now,
+	}
+}
+
+func (store *memoryIdempotencyStore) Lock(key, fingerprint, token string, ttl time.Duration) (*IdempotencyRecord, bool, error) {
+	now := store.now()
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if now.Sub(store.lastSweep) >= __idempotencySweepInterval {
+		store.sweep(now)
+	}
+	if r, ok := store.records[key]; ok && now.Before(r.expires) {
+		return r.record, false, nil
+	}
+	store.records[key] = &memoryIdempotencyRecord{
+		record:  &IdempotencyRecord{Fingerprint: fingerprint},
+		token:   token,
+		expires: now.Add(ttl),
+	}
+	return nil, true, nil
+}
+
+func (store *memoryIdempotencyStore) Complete(key, token string, record *IdempotencyRecord, ttl time.Duration) error {
+	expires := store.now().Add(ttl)
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if !store.lockedBy(key, token) {
+		return errIdempotencyLockLost
+	}
+	store.records[key] = &memoryIdempotencyRecord{record: record, expires: expires}
+	return nil
+}
+
+func (store *memoryIdempotencyStore) Unlock(key, token string) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if store.lockedBy(key, token) {
+		delete(store.records, key)
+	}
+	return nil
+}
+
+// lockedBy reports whether the record of key is the in-progress record owned by token.
+func (store *memoryIdempotencyStore) lockedBy(key, token string) bool {
+	r, ok := store.records[key]
+	return ok && !r.record.Completed && r.token == token
+}
+
+func (store *memoryIdempotencyStore) sweep(now time.Time) {
+	for key, r := range store.records {
+		if !now.Before(r.expires) {
+			delete(store.records, key)
+		}
+	}
+	store.lastSweep = now
+}
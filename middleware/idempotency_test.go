@@ -0,0 +1,237 @@
+// Copyright 2014 Manu Martinez-Almeida.  All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package middleware
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chanxuehong/gin"
+)
+
+// idempotencyRequest serves a POST request of path with the idempotency key and body.
+func idempotencyRequest(engine *gin.Engine, path, key, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	if key != "" {
+		r.Header.Set(gin.HeaderIdempotencyKey, key)
+	}
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, r)
+	return w
+}
+
+func TestIdempotency(t *testing.T) {
+	calls := 0
+	engine := gin.New()
+	engine.Use(Idempotency(IdempotencyConfig{MaxBodySize: 16}))
+	engine.Post("/orders", func(ctx *gin.Context) {
+		calls++
+		body, _ := ioutil.ReadAll(ctx.Request.Body) // the body can be read again
+		ctx.ResponseWriter.Header().Set("X-Order", "1")
+		ctx.String(http.StatusCreated, "order %s #%d", body, calls)
+	})
+	engine.Post("/large", func(ctx *gin.Context) {
+		calls++
+		ctx.String(200, "%s", strings.Repeat("x", 17))
+	})
+
+	tests := []struct {
+		path     string
+		key      string
+		body     string
+		code     int
+		response string
+		replayed bool
+		calls    int
+	}{
+		{"/orders", "k1", "a", 201, "order a #1", false, 1},
+		{"/orders", "k1", "a", 201, "order a #1", true, 1},
+		// the same key with a different body
+		{"/orders", "k1", "b", 422, "422 Unprocessable Entity", false, 1},
+		{"/orders", "k2", "b", 201, "order b #2", false, 2},
+		// the request without key is not idempotent
+		{"/orders", "", "a", 201, "order a #3", false, 3},
+		{"/orders", "", "a", 201, "order a #4", false, 4},
+		{"/orders", strings.Repeat("k", 256), "a", 400, "400 Bad Request", false, 4},
+		// the request body exceeds MaxBodySize
+		{"/orders", "k3", strings.Repeat("x", 17), 413, "413 Request Entity Too Large", false, 4},
+		// the response body exceeds MaxBodySize, it is not stored
+		{"/large", "k4", "", 200, strings.Repeat("x", 17), false, 5},
+		{"/large", "k4", "", 200, strings.Repeat("x", 17), false, 6},
+	}
+	for i, tt := range tests {
+		w := idempotencyRequest(engine, tt.path, tt.key, tt.body)
+		replayed := w.Header().Get(gin.HeaderIdempotentReplayed) == "true"
+		if w.Code != tt.code || w.Body.String() != tt.response || replayed != tt.replayed || calls != tt.calls {
+			t.Errorf("#%d %s key=%.8q: got %d %q replayed %t after %d calls, want %d %q %t after %d calls", i, tt.path, tt.key,
+				w.Code, w.Body.String(), replayed, calls, tt.code, tt.response, tt.replayed, tt.calls)
+		}
+		if tt.replayed && w.Header().Get("X-Order") != "1" {
+			t.Errorf("#%d: the header is not replayed", i)
+		}
+	}
+}
+
+func TestIdempotencyInProgress(t *testing.T) {
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	engine := gin.New()
+	engine.Use(Idempotency(IdempotencyConfig{}))
+	engine.Post("/slow", func(ctx *gin.Context) {
+		close(entered)
+		<-release
+		ctx.String(200, "done")
+	})
+
+	done := make(chan *httptest.ResponseRecorder, 1)
+	go func() { done <- idempotencyRequest(engine, "/slow", "k1", "") }()
+	<-entered
+	if w := idempotencyRequest(engine, "/slow", "k1", ""); w.Code != http.StatusConflict {
+		t.Errorf("got %d while the first request is in progress, want 409", w.Code)
+	}
+	close(release)
+	if w := <-done; w.Code != 200 {
+		t.Errorf("got %d for the first request", w.Code)
+	}
+	if w := idempotencyRequest(engine, "/slow", "k1", ""); w.Code != 200 || w.Header().Get(gin.HeaderIdempotentReplayed) != "true" {
+		t.Errorf("got %d replayed %q after the first request", w.Code, w.Header().Get(gin.HeaderIdempotentReplayed))
+	}
+}
+
+func TestIdempotencyUnlock(t *testing.T) {
+	calls := 0
+	engine := gin.New()
+	engine.Use(Idempotency(IdempotencyConfig{}))
+	engine.Post("/flaky", func(ctx *gin.Context) {
+		calls++
+		switch calls {
+		case 1:
+			ctx.String(http.StatusServiceUnavailable, "unavailable")
+		case 2:
+			panic("boom")
+		default:
+			ctx.String(200, "ok #%d", calls)
+		}
+	})
+	serve := func() (w *httptest.ResponseRecorder, panicked bool) {
+		defer func() {
+			panicked = recover() != nil
+		}()
+		return idempotencyRequest(engine, "/flaky", "k1", ""), false
+	}
+
+	if w, _ := serve(); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("got %d, want 503", w.Code)
+	}
+	// the key is unlocked after 5xx
+	if _, panicked := serve(); !panicked || calls != 2 {
+		t.Errorf("got panicked %t after %d calls", panicked, calls)
+	}
+	// the key is unlocked after the panic
+	if w, _ := serve(); w.Code != 200 || w.Body.String() != "ok #3" {
+		t.Errorf("got %d %q after the panic", w.Code, w.Body.String())
+	}
+	if w, _ := serve(); w.Body.String() != "ok #3" || calls != 3 {
+		t.Errorf("got %q after %d calls, want the response replayed", w.Body.String(), calls)
+	}
+}
+
+func TestIdempotencyConfig(t *testing.T) {
+	engine := gin.New()
+	engine.Use(Idempotency(IdempotencyConfig{
+		Header:   "X-Request-Key",
+		Required: true,
+		Methods:  []string{http.MethodPut},
+		Scope:    func(ctx *gin.Context) string { return ctx.Request.Header.Get("X-User") },
+		Rejected: func(ctx *gin.Context, code int) { ctx.String(code, "rejected") },
+	}))
+	calls := 0
+	handler := func(ctx *gin.Context) {
+		calls++
+		ctx.String(200, "%d", calls)
+	}
+	engine.Put("/items", handler)
+	engine.Post("/items", handler)
+
+	serve := func(method, key, user string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, "/items", nil)
+		if key != "" {
+			r.Header.Set("X-Request-Key", key)
+		}
+		r.Header.Set("X-User", user)
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, r)
+		return w
+	}
+	tests := []struct {
+		method string
+		key    string
+		user   string
+		code   int
+		body   string
+	}{
+		{"PUT", "", "alice", 400, "rejected"},
+		{"PUT", "k1", "alice", 200, "1"},
+		{"PUT", "k1", "alice", 200, "1"},
+		// the keys of the users do not conflict
+		{"PUT", "k1", "bob", 200, "2"},
+		// the other methods are not affected
+		{"POST", "", "alice", 200, "3"},
+	}
+	for i, tt := range tests {
+		if w := serve(tt.method, tt.key, tt.user); w.Code != tt.code || w.Body.String() != tt.body {
+			t.Errorf("#%d %s key=%q user=%s: got %d %q, want %d %q", i, tt.method, tt.key, tt.user, w.Code, w.Body.String(), tt.code, tt.body)
+		}
+	}
+}
+
+func TestMemoryIdempotencyStore(t *testing.T) {
+	now := time.Unix(1000, 0)
+	store := NewMemoryIdempotencyStore().(*memoryIdempotencyStore)
+	store.now = func() time.Time { return now }
+	completed := &IdempotencyRecord{Fingerprint: "f", Completed: true, Status: 200}
+
+	if _, locked, _ := store.Lock("k", "f", "t1", time.Minute); !locked {
+		t.Fatal("the key is not locked")
+	}
+	if record, locked, _ := store.Lock("k", "f", "t2", time.Minute); locked || record.Completed {
+		t.Fatalf("got %+v %t for the locked key", record, locked)
+	}
+
+	// the lock of t1 expires and the key is locked by t2
+	now = now.Add(2 * time.Minute)
+	if _, locked, _ := store.Lock("k", "f", "t2", time.Minute); !locked {
+		t.Fatal("the expired lock is not replaced")
+	}
+	if err := store.Complete("k", "t1", completed, time.Hour); err == nil {
+		t.Error("Complete() by the expired lock got no error")
+	}
+	store.Unlock("k", "t1")
+	if record, locked, _ := store.Lock("k", "f", "t3", time.Minute); locked || record.Completed {
+		t.Errorf("got %+v %t, want the lock of t2 kept", record, locked)
+	}
+
+	if err := store.Complete("k", "t2", completed, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	// the completed record can not be unlocked or completed again
+	store.Unlock("k", "t2")
+	if err := store.Complete("k", "t2", &IdempotencyRecord{Completed: true, Status: 500}, time.Hour); err == nil {
+		t.Error("Complete() of the completed record got no error")
+	}
+	if record, locked, _ := store.Lock("k", "f", "t4", time.Minute); locked || record != completed {
+		t.Errorf("got %+v %t, want the completed record", record, locked)
+	}
+
+	// the completed record expires
+	now = now.Add(2 * time.Hour)
+	if _, locked, _ := store.Lock("k", "f", "t5", time.Minute); !locked {
+		t.Error("the expired record is not replaced")
+	}
+}
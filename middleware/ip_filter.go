@@ -0,0 +1,132 @@
+// Copyright 2014 Manu Martinez-Almeida.  All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package middleware
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/chanxuehong/gin"
+	"github.com/chanxuehong/gin/internal/netutil"
+)
+
+// IPFilterConfig is the configuration of IPFilter.
+type IPFilterConfig struct {
+	// Allow is the allow-list of CIDR (e.g. "10.0.0.0/8", "fd00::/8") or single IP,
+	// if it is not empty, only the clients in it are allowed.
+	Allow []string
+
+	// Deny is the deny-list of CIDR or single IP, it takes precedence over Allow.
+	Deny []string
+
+	// Lists replaces Allow and Deny if it is not nil, it can be reloaded at runtime by IPLists.Store().
+	// All the requests are denied until the lists are stored.
+	Lists *IPLists
+
+	// Forbidden is called when the client is not allowed, the handler chain is aborted after it returns.
+	// Default writes 403 Forbidden.
+	Forbidden gin.HandlerFunc
+}
+
+// IPLists is the allow-list and deny-list of IPFilter, which can be replaced atomically
+// while serving requests.
+type IPLists struct {
+	v atomic.Value // *ipLists
+}
+
+type ipLists struct {
+	allow []*net.IPNet
+	deny  []*net.IPNet
+}
+
+// NewIPLists returns a new IPLists, see IPFilterConfig for allow and deny.
+func NewIPLists(allow, deny []string) (*IPLists, error) {
+	lists := new(IPLists)
+	if err := lists.Store(allow, deny); err != nil {
+		return nil, err
+	}
+	return lists, nil
+}
+
+// Store replaces the lists atomically, the lists are not changed if any of allow and deny is invalid.
+// It is safe to be called while serving requests.
+func (lists *IPLists) Store(allow, deny []string) error {
+	allowNets, err := parseIPNets(allow)
+	if err != nil {
+		return err
+	}
+	denyNets, err := parseIPNets(deny)
+	if err != nil {
+		return err
+	}
+	lists.v.Store(&ipLists{allow: allowNets, deny: denyNets})
+	return nil
+}
+
+// Allowed reports whether ip is allowed by the lists.
+// All the IPs are denied if the lists have never been stored, e.g. a zero IPLists.
+func (lists *IPLists) Allowed(ip net.IP) bool {
+	v, _ := lists.v.Load().(*ipLists)
+	if v == nil {
+		return false
+	}
+	if ip == nil || containsIP(v.deny, ip) {
+		return false
+	}
+	return len(v.allow) == 0 || containsIP(v.allow, ip)
+}
+
+// IPFilter returns a middleware that allows or denies the requests by the client IP,
+// which is got by Context.ClientIP(), so the trusted proxies of Engine are respected,
+// see Engine.TrustedProxies(). It panics if any CIDR of the config is invalid.
+//
+// For example, to restrict the pprof routes to the internal networks:
+//     engine.DebugPProf(middleware.IPFilter(middleware.IPFilterConfig{
+//         Allow: []string{"127.0.0.1", "::1", "10.0.0.0/8"},
+//     }))
+func IPFilter(config IPFilterConfig) gin.HandlerFunc {
+	lists := config.Lists
+	if lists == nil {
+		var err error
+		if lists, err = NewIPLists(config.Allow, config.Deny); err != nil {
+			panic(err.Error())
+		}
+	}
+	forbidden := config.Forbidden
+	if forbidden == nil {
+		forbidden = func(ctx *gin.Context) {
+			ctx.String(http.StatusForbidden, "403 forbidden")
+		}
+	}
+
+	return func(ctx *gin.Context) {
+		if lists.Allowed(net.ParseIP(ctx.ClientIP())) {
+			ctx.Next()
+			return
+		}
+		forbidden(ctx)
+		ctx.Abort()
+	}
+}
+
+// parseIPNets parses the CIDRs or single IPs of the lists.
+func parseIPNets(cidrs []string) ([]*net.IPNet, error) {
+	nets, err := netutil.ParseIPNets(cidrs)
+	if err != nil {
+		return nil, errors.New("middleware: " + err.Error())
+	}
+	return nets, nil
+}
+
+func containsIP(nets []*net.IPNet, ip net.IP) bool {
+	for _, ipNet := range nets {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,141 @@
+// Copyright 2014 Manu Martinez-Almeida.  All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package middleware
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/chanxuehong/gin"
+)
+
+func TestIPListsAllowed(t *testing.T) {
+	tests := []struct {
+		allow []string
+		deny  []string
+		ip    string
+		want  bool
+	}{
+		{nil, nil, "1.2.3.4", true},
+		{nil, nil, "", false},
+		{[]string{"10.0.0.0/8"}, nil, "10.1.2.3", true},
+		{[]string{"10.0.0.0/8"}, nil, "11.1.2.3", false},
+		{[]string{"127.0.0.1", "::1"}, nil, "::1", true},
+		{[]string{"127.0.0.1"}, nil, "::ffff:127.0.0.1", true},
+		{nil, []string{"192.0.2.0/24"}, "192.0.2.1", false},
+		{nil, []string{"192.0.2.0/24"}, "198.51.100.1", true},
+		// the deny-list takes precedence over the allow-list
+		{[]string{"10.0.0.0/8"}, []string{"10.0.1.0/24"}, "10.0.1.5", false},
+		{[]string{"10.0.0.0/8"}, []string{"10.0.1.0/24"}, "10.0.2.5", true},
+		{[]string{"10.0.1.5"}, []string{"10.0.0.0/8"}, "10.0.1.5", false},
+		{[]string{"fd00::/8"}, []string{"fd00::1"}, "fd00::1", false},
+		{[]string{"fd00::/8"}, []string{"fd00::1"}, "fd00::2", true},
+	}
+	for _, tt := range tests {
+		lists, err := NewIPLists(tt.allow, tt.deny)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := lists.Allowed(net.ParseIP(tt.ip)); got != tt.want {
+			t.Errorf("allow=%v deny=%v Allowed(%q) got %t, want %t", tt.allow, tt.deny, tt.ip, got, tt.want)
+		}
+	}
+}
+
+func TestIPListsStore(t *testing.T) {
+	// the lists which have never been stored deny all
+	var zero IPLists
+	if zero.Allowed(net.ParseIP("10.0.0.1")) {
+		t.Error("the zero IPLists allows the IP")
+	}
+	lists, err := NewIPLists([]string{"10.0.0.0/8"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := lists.Store([]string{"1.2.3.4"}, []string{"bad/cidr"}); err == nil {
+		t.Error("Store() with an invalid CIDR got no error")
+	}
+	if !lists.Allowed(net.ParseIP("10.0.0.1")) {
+		t.Error("the lists are changed by the invalid Store()")
+	}
+	if err := lists.Store(nil, []string{"10.0.0.0/8"}); err != nil {
+		t.Fatal(err)
+	}
+	if lists.Allowed(net.ParseIP("10.0.0.1")) {
+		t.Error("the lists are not replaced by Store()")
+	}
+}
+
+func TestIPFilter(t *testing.T) {
+	engine := gin.New()
+	engine.TrustedProxies("10.0.0.0/8")
+	engine.Use(IPFilter(IPFilterConfig{
+		Allow: []string{"192.0.2.0/24"},
+		Deny:  []string{"192.0.2.66"},
+	}))
+	engine.Get("/", func(ctx *gin.Context) { ctx.String(200, "ok") })
+
+	tests := []struct {
+		remote string
+		xff    string
+		code   int
+	}{
+		{"192.0.2.1:1234", "", 200},
+		{"192.0.2.66:1234", "", 403},
+		{"198.51.100.1:1234", "", 403},
+		// the client IP is got from the trusted proxy
+		{"10.0.0.1:1234", "192.0.2.1", 200},
+		{"10.0.0.1:1234", "192.0.2.66", 403},
+		// the header from an untrusted client is ignored
+		{"198.51.100.1:1234", "192.0.2.1", 403},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = tt.remote
+		if tt.xff != "" {
+			r.Header.Set(gin.HeaderXForwardedFor, tt.xff)
+		}
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, r)
+		if w.Code != tt.code {
+			t.Errorf("remote=%s xff=%q got %d, want %d", tt.remote, tt.xff, w.Code, tt.code)
+		}
+	}
+}
+
+func TestIPFilterLists(t *testing.T) {
+	lists := new(IPLists)
+	engine := gin.New()
+	engine.Use(IPFilter(IPFilterConfig{Lists: lists}))
+	engine.Get("/", func(ctx *gin.Context) { ctx.String(200, "ok") })
+	serve := func() int {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = "192.0.2.1:1234"
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	if code := serve(); code != 403 {
+		t.Errorf("got %d before the lists are stored, want 403", code)
+	}
+	if err := lists.Store([]string{"192.0.2.0/24"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if code := serve(); code != 200 {
+		t.Errorf("got %d after the lists are stored, want 200", code)
+	}
+}
+
+func TestIPFilterInvalid(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("IPFilter() with an invalid CIDR did not panic")
+		}
+	}()
+	IPFilter(IPFilterConfig{Allow: []string{"10.0.0.0/33"}})
+}
@@ -0,0 +1,248 @@
+// Copyright 2014 Manu Martinez-Almeida.  All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package middleware
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/chanxuehong/gin/internal/response"
+)
+
+// JWTKeys is a static JWTKeySet, kid --> key. The key of the empty kid is used for
+// the tokens without the "kid" header.
+//
+// The keys can be rotated by adding the new key with a new kid, and removing the old key
+// after the tokens signed by it are expired.
+//
+// The value is the key, or a JWTKey to accept only the tokens of its algorithm.
+type JWTKeys map[string]interface{}
+
+// JWTKey is a key which only verifies the tokens of Alg, it can be the value of JWTKeys.
+type JWTKey struct {
+	Alg string      // the algorithm of the tokens, any algorithm is accepted if empty
+	Key interface{} // the key, see JWTKeySet
+}
+
+// Key implements JWTKeySet.
+func (keys JWTKeys) Key(kid, alg string) (interface{}, error) {
+	key, ok := keys[kid]
+	if !ok {
+		return nil, errors.New("middleware: JWT key '" + kid + "' is not found")
+	}
+	if key, ok := key.(JWTKey); ok {
+		if key.Alg != "" && key.Alg != alg {
+			return nil, errors.New("middleware: JWT key '" + kid + "' is for the algorithm " + key.Alg + ", not " + alg)
+		}
+		return key.Key, nil
+	}
+	return key, nil
+}
+
+// ParseJWKS parses the JWK Set document (RFC 7517), the keys of type RSA, EC (P-256)
+// and OKP (Ed25519) are returned, the keys whose "use" is not "sig" are skipped.
+// The keys of type oct are skipped too, the symmetric keys should not be published
+// and belong in the static JWTKeys. The key which declares "alg" is returned as JWTKey,
+// so that it only verifies the tokens of that algorithm.
+func ParseJWKS(data []byte) (JWTKeys, error) {
+	var set struct {
+		Keys []json.RawMessage `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, err
+	}
+	keys := make(JWTKeys, len(set.Keys))
+	for i, raw := range set.Keys {
+		var jwk struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			Use string `json:"use"`
+			Alg string `json:"alg"`
+			Crv string `json:"crv"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		}
+		if err := json.Unmarshal(raw, &jwk); err != nil {
+			return nil, err
+		}
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk2key(jwk.Kty, jwk.Crv, jwk.N, jwk.E, jwk.X, jwk.Y)
+		if err != nil {
+			return nil, errors.New("middleware: invalid JWK #" + strconv.Itoa(i) + ": " + err.Error())
+		}
+		switch {
+		case key == nil:
+		case jwk.Alg != "":
+			keys[jwk.Kid] = JWTKey{Alg: jwk.Alg, Key: key}
+		default:
+			keys[jwk.Kid] = key
+		}
+	}
+	return keys, nil
+}
+
+// jwk2key returns the key of the JWK parameters, or nil if the key type is not supported.
+func jwk2key(kty, crv, n, e, x, y string) (interface{}, error) {
+	switch kty {
+	case "RSA":
+		nb, err := base64.RawURLEncoding.DecodeString(n)
+		if err != nil {
+			return nil, err
+		}
+		eb, err := base64.RawURLEncoding.DecodeString(e)
+		if err != nil {
+			return nil, err
+		}
+		exponent := new(big.Int).SetBytes(eb)
+		if len(nb) == 0 || !exponent.IsInt64() || exponent.Int64() < 2 || exponent.Int64() > 1<<31-1 {
+			return nil, errors.New("invalid RSA key")
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(nb), E: int(exponent.Int64())}, nil
+	case "EC":
+		if crv != "P-256" {
+			return nil, nil
+		}
+		xb, err := base64.RawURLEncoding.DecodeString(x)
+		if err != nil {
+			return nil, err
+		}
+		yb, err := base64.RawURLEncoding.DecodeString(y)
+		if err != nil {
+			return nil, err
+		}
+		key := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(xb), Y: new(big.Int).SetBytes(yb)}
+		if !key.Curve.IsOnCurve(key.X, key.Y) {
+			return nil, errors.New("invalid EC key")
+		}
+		return key, nil
+	case "OKP":
+		if crv != "Ed25519" {
+			return nil, nil
+		}
+		xb, err := base64.RawURLEncoding.DecodeString(x)
+		if err != nil {
+			return nil, err
+		}
+		if len(xb) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 key")
+		}
+		return ed25519.PublicKey(xb), nil
+	}
+	return nil, nil
+}
+
+// JWKS is a JWTKeySet loaded from a JWK Set document, the keys are rotated by reloading the document.
+// The document is reloaded by Refresh(), or when a token has an unknown kid,
+// which happens at most once per MinRefreshInterval.
+type JWKS struct {
+	// MinRefreshInterval is the min interval of reloading the document for the unknown kid.
+	// Default is 1 minute.
+	MinRefreshInterval time.Duration
+
+	load        func() ([]byte, error)
+	keys        atomic.Value // JWTKeys
+	mu          sync.Mutex   // serializes the reloading
+	lastRefresh time.Time
+}
+
+// NewJWKS returns a new JWKS which loads the document by load, the document is loaded immediately.
+func NewJWKS(load func() ([]byte, error)) (*JWKS, error) {
+	if load == nil {
+		panic("load can not be nil")
+	}
+	jwks := &JWKS{load: load}
+	if err := jwks.Refresh(); err != nil {
+		return nil, err
+	}
+	return jwks, nil
+}
+
+// NewJWKSFromFile returns a new JWKS which loads the document from the file.
+func NewJWKSFromFile(filename string) (*JWKS, error) {
+	return NewJWKS(func() ([]byte, error) {
+		return ioutil.ReadFile(filename)
+	})
+}
+
+// NewJWKSFromHandler returns a new JWKS which loads the document by calling the local handler
+// with a GET request of path, e.g. the JWKS endpoint of the auth service in the same process.
+func NewJWKSFromHandler(h http.Handler, path string) (*JWKS, error) {
+	if h == nil {
+		panic("handler can not be nil")
+	}
+	return NewJWKS(func() ([]byte, error) {
+		r, err := http.NewRequest(http.MethodGet, path, nil)
+		if err != nil {
+			return nil, err
+		}
+		w := response.NewBufferWriter()
+		h.ServeHTTP(w, r)
+		if w.Status() != http.StatusOK {
+			return nil, errors.New("middleware: loading JWKS got http status " + strconv.Itoa(w.Status()))
+		}
+		return w.Body(), nil
+	})
+}
+
+// Refresh reloads the document, the keys are not changed if it fails.
+func (jwks *JWKS) Refresh() error {
+	jwks.mu.Lock()
+	defer jwks.mu.Unlock()
+	return jwks.refresh()
+}
+
+func (jwks *JWKS) refresh() error {
+	jwks.lastRefresh = time.Now()
+	data, err := jwks.load()
+	if err != nil {
+		return err
+	}
+	keys, err := ParseJWKS(data)
+	if err != nil {
+		return err
+	}
+	jwks.keys.Store(keys)
+	return nil
+}
+
+// Key implements JWTKeySet.
+func (jwks *JWKS) Key(kid, alg string) (interface{}, error) {
+	keys, _ := jwks.keys.Load().(JWTKeys)
+	if key, ok := keys[kid]; ok {
+		return key, nil
+	}
+
+	jwks.mu.Lock()
+	defer jwks.mu.Unlock()
+	if keys, _ = jwks.keys.Load().(JWTKeys); keys[kid] == nil { // may be reloaded while waiting for the lock
+		interval := jwks.MinRefreshInterval
+		if interval <= 0 {
+			interval = time.Minute
+		}
+		if time.Since(jwks.lastRefresh) >= interval {
+			if err := jwks.refresh(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	keys, _ = jwks.keys.Load().(JWTKeys)
+	return keys.Key(kid, alg)
+}
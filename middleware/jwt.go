@@ -0,0 +1,344 @@
+// Copyright 2014 Manu Martinez-Almeida.  All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package middleware
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/chanxuehong/gin"
+)
+
+// The JWT algorithms supported by JWT.
+const (
+	JWTAlgHS256 = "HS256"
+	JWTAlgRS256 = "RS256"
+	JWTAlgES256 = "ES256"
+	JWTAlgEdDSA = "EdDSA"
+)
+
+// JWTKeySet looks up the keys to verify the tokens, see JWTKeys and JWKS.
+type JWTKeySet interface {
+	// Key returns the key of the kid and alg in the header of token, the key must be
+	// []byte for HS256, *rsa.PublicKey for RS256, *ecdsa.PublicKey (P-256) for ES256
+	// and ed25519.PublicKey for EdDSA.
+	Key(kid, alg string) (interface{}, error)
+}
+
+// JWTConfig is the configuration of JWT.
+type JWTConfig struct {
+	// Keys looks up the keys to verify the tokens, it is required.
+	Keys JWTKeySet
+
+	// Algorithms is the allow-list of the algorithms of tokens.
+	// Default is all the supported algorithms, the type of key must match the algorithm anyway.
+	Algorithms []string
+
+	// Issuer is the expected "iss" claim, it is not checked if empty.
+	Issuer string
+
+	// Audience is the expected value in the "aud" claim, it is not checked if empty.
+	Audience string
+
+	// Leeway is the clock skew allowed when checking the "exp" and "nbf" claims.
+	Leeway time.Duration
+
+	// RequireExpiration rejects the tokens without the "exp" claim if true.
+	RequireExpiration bool
+
+	// Claims returns a pointer to a new value which the claims of token are unmarshaled into,
+	// the value is set as the principal on the Context, see AuthPrincipal().
+	// Default returns a new(JWTStandardClaims).
+	Claims func() interface{}
+
+	// Realm is the realm of the WWW-Authenticate challenge, it is omitted if empty.
+	Realm string
+}
+
+// JWTStandardClaims is the registered claims of JWT (RFC 7519), it can be embedded in the
+// custom claims struct.
+type JWTStandardClaims struct {
+	Issuer    string         `json:"iss,omitempty"`
+	Subject   string         `json:"sub,omitempty"`
+	Audience  JWTAudience    `json:"aud,omitempty"`
+	ExpiresAt JWTNumericDate `json:"exp,omitempty"`
+	NotBefore JWTNumericDate `json:"nbf,omitempty"`
+	IssuedAt  JWTNumericDate `json:"iat,omitempty"`
+	ID        string         `json:"jti,omitempty"`
+}
+
+// JWTNumericDate is the seconds since the epoch, the fractional part of it in JSON is truncated.
+type JWTNumericDate int64
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (date *JWTNumericDate) UnmarshalJSON(data []byte) error {
+	var f float64
+	if err := json.Unmarshal(data, &f); err != nil {
+		return err
+	}
+	*date = JWTNumericDate(f)
+	return nil
+}
+
+// Time returns the time of date.
+func (date JWTNumericDate) Time() time.Time {
+	return time.Unix(int64(date), 0)
+}
+
+// JWTAudience is the "aud" claim, which is a string or an array of strings in JSON.
+type JWTAudience []string
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (aud *JWTAudience) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*aud = JWTAudience{s}
+		return nil
+	}
+	var ss []string
+	if err := json.Unmarshal(data, &ss); err != nil {
+		return err
+	}
+	*aud = ss
+	return nil
+}
+
+// JWTClaims returns the claims of the token authenticated by JWT, or nil if the request
+// is not authenticated by JWT. It is the pointer returned by JWTConfig.Claims.
+func JWTClaims(ctx *gin.Context) interface{} {
+	v, _ := ctx.Get(jwtClaimsKey)
+	return v
+}
+
+// The key of Context to store the claims, see JWTClaims().
+const jwtClaimsKey = "github.com/chanxuehong/gin/middleware.JWTClaims"
+
+// JWT returns a middleware that authenticates the requests by the JSON Web Token (RFC 7519) in the
+// Authorization header as the bearer token, see BearerAuth(). The tokens signed by HS256, RS256, ES256
+// and EdDSA (Ed25519) are verified with the keys of config.Keys selected by the "kid" header,
+// and the "exp", "nbf", "iss" and "aud" claims are validated. The claims are unmarshaled into
+// the value returned by config.Claims, which is set on the Context, see JWTClaims() and AuthPrincipal():
+//     type Claims struct {
+//         middleware.JWTStandardClaims
+//         Role string `json:"role"`
+//     }
+//     engine.Group("/api", middleware.JWT(middleware.JWTConfig{
+//         Keys:   jwks,
+//         Claims: func() interface{} { return new(Claims) },
+//     }))
+func JWT(config JWTConfig) gin.HandlerFunc {
+	verifier := newJWTVerifier(config)
+	return BearerAuthWithConfig(BearerAuthConfig{
+		Validator: func(ctx *gin.Context, token string) (interface{}, bool) {
+			claims, err := verifier.verify(token, time.Now())
+			if err != nil {
+				return nil, false
+			}
+			ctx.Set(jwtClaimsKey, claims)
+			return claims, true
+		},
+		Realm: config.Realm,
+	})
+}
+
+type jwtVerifier struct {
+	JWTConfig
+	algorithms map[string]bool
+}
+
+func newJWTVerifier(config JWTConfig) *jwtVerifier {
+	if config.Keys == nil {
+		panic("Keys of JWTConfig can not be nil")
+	}
+	algorithms := config.Algorithms
+	if len(algorithms) == 0 {
+		algorithms = []string{JWTAlgHS256, JWTAlgRS256, JWTAlgES256, JWTAlgEdDSA}
+	}
+	verifier := &jwtVerifier{JWTConfig: config, algorithms: make(map[string]bool, len(algorithms))}
+	for _, alg := range algorithms {
+		switch alg {
+		case JWTAlgHS256, JWTAlgRS256, JWTAlgES256, JWTAlgEdDSA:
+			verifier.algorithms[alg] = true
+		default:
+			panic("unsupported JWT algorithm '" + alg + "'")
+		}
+	}
+	if verifier.Claims == nil {
+		verifier.Claims = func() interface{} { return new(JWTStandardClaims) }
+	}
+	return verifier
+}
+
+var (
+	errJWTMalformed = errors.New("middleware: malformed JWT")
+	errJWTSignature = errors.New("middleware: invalid JWT signature")
+)
+
+// verify verifies the token at now and returns the claims of it.
+func (verifier *jwtVerifier) verify(token string, now time.Time) (interface{}, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errJWTMalformed
+	}
+	headerJSON, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, errJWTMalformed
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, errJWTMalformed
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errJWTMalformed
+	}
+	var header struct {
+		Alg  string   `json:"alg"`
+		Kid  string   `json:"kid"`
+		Crit []string `json:"crit"`
+	}
+	if err = json.Unmarshal(headerJSON, &header); err != nil {
+		return nil, errJWTMalformed
+	}
+	if !verifier.algorithms[header.Alg] {
+		return nil, errors.New("middleware: JWT algorithm '" + header.Alg + "' is not allowed")
+	}
+	if len(header.Crit) > 0 {
+		return nil, errors.New("middleware: JWT critical header parameters are not supported")
+	}
+	key, err := verifier.Keys.Key(header.Kid, header.Alg)
+	if err != nil {
+		return nil, err
+	}
+	if err = verifyJWTSignature(header.Alg, key, parts[0]+"."+parts[1], signature); err != nil {
+		return nil, err
+	}
+
+	var std struct {
+		Issuer    string       `json:"iss"`
+		Audience  JWTAudience  `json:"aud"`
+		ExpiresAt *json.Number `json:"exp"`
+		NotBefore *json.Number `json:"nbf"`
+	}
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	decoder.UseNumber()
+	if err = decoder.Decode(&std); err != nil {
+		return nil, errJWTMalformed
+	}
+	if err = verifier.validate(std.Issuer, std.Audience, std.ExpiresAt, std.NotBefore, now); err != nil {
+		return nil, err
+	}
+
+	claims := verifier.Claims()
+	if err = json.Unmarshal(payload, claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+func (verifier *jwtVerifier) validate(iss string, aud JWTAudience, exp, nbf *json.Number, now time.Time) error {
+	if exp != nil {
+		t, err := numericDate(*exp)
+		if err != nil {
+			return err
+		}
+		if !now.Before(t.Add(verifier.Leeway)) {
+			return errors.New("middleware: JWT is expired")
+		}
+	} else if verifier.RequireExpiration {
+		return errors.New(`middleware: JWT has no "exp" claim`)
+	}
+	if nbf != nil {
+		t, err := numericDate(*nbf)
+		if err != nil {
+			return err
+		}
+		if now.Add(verifier.Leeway).Before(t) {
+			return errors.New("middleware: JWT is not valid yet")
+		}
+	}
+	if verifier.Issuer != "" && iss != verifier.Issuer {
+		return errors.New("middleware: invalid JWT issuer")
+	}
+	if verifier.Audience != "" {
+		for _, v := range aud {
+			if v == verifier.Audience {
+				return nil
+			}
+		}
+		return errors.New("middleware: invalid JWT audience")
+	}
+	return nil
+}
+
+// numericDate converts the NumericDate (seconds since the epoch, may be fractional) to time.
+func numericDate(n json.Number) (time.Time, error) {
+	f, err := n.Float64()
+	if err != nil {
+		return time.Time{}, errJWTMalformed
+	}
+	sec := int64(f)
+	return time.Unix(sec, int64((f-float64(sec))*1e9)), nil
+}
+
+func verifyJWTSignature(alg string, key interface{}, signingInput string, signature []byte) error {
+	digest := sha256.Sum256([]byte(signingInput))
+	switch alg {
+	case JWTAlgHS256:
+		secret, ok := key.([]byte)
+		if !ok || len(secret) == 0 {
+			return errors.New("middleware: JWT key is not a HMAC secret")
+		}
+		mac := hmac.New(sha256.New, secret)
+		mac.Write([]byte(signingInput))
+		if !hmac.Equal(mac.Sum(nil), signature) {
+			return errJWTSignature
+		}
+	case JWTAlgRS256:
+		publicKey, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return errors.New("middleware: JWT key is not a RSA public key")
+		}
+		if rsa.VerifyPKCS1v15(publicKey, crypto.SHA256, digest[:], signature) != nil {
+			return errJWTSignature
+		}
+	case JWTAlgES256:
+		publicKey, ok := key.(*ecdsa.PublicKey)
+		if !ok || publicKey.Curve != elliptic.P256() {
+			return errors.New("middleware: JWT key is not a P-256 public key")
+		}
+		if len(signature) != 64 {
+			return errJWTSignature
+		}
+		r := new(big.Int).SetBytes(signature[:32])
+		s := new(big.Int).SetBytes(signature[32:])
+		if !ecdsa.Verify(publicKey, digest[:], r, s) {
+			return errJWTSignature
+		}
+	case JWTAlgEdDSA:
+		publicKey, ok := key.(ed25519.PublicKey)
+		if !ok || len(publicKey) != ed25519.PublicKeySize {
+			return errors.New("middleware: JWT key is not a Ed25519 public key")
+		}
+		if !ed25519.Verify(publicKey, []byte(signingInput), signature) {
+			return errJWTSignature
+		}
+	default:
+		return errors.New("middleware: unsupported JWT algorithm '" + alg + "'")
+	}
+	return nil
+}
@@ -0,0 +1,444 @@
+// Copyright 2014 Manu Martinez-Almeida.  All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package middleware
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/chanxuehong/gin"
+)
+
+type jwtTestKeys struct {
+	secret     []byte
+	rsaKey     *rsa.PrivateKey
+	ecKey      *ecdsa.PrivateKey
+	edKey      ed25519.PrivateKey
+	rsaKey2    *rsa.PrivateKey // not in the key set
+	ecKey2     *ecdsa.PrivateKey
+	edKey2     ed25519.PrivateKey
+	rsaDERAsHS []byte // the public key of rsaKey in DER, for the HS256 confusion
+}
+
+var (
+	__jwtTestKeysOnce sync.Once
+	__jwtTestKeys     jwtTestKeys
+)
+
+func testJWTKeys(t *testing.T) *jwtTestKeys {
+	__jwtTestKeysOnce.Do(func() {
+		keys := &__jwtTestKeys
+		keys.secret = []byte("0123456789abcdef0123456789abcdef")
+		keys.rsaKey, _ = rsa.GenerateKey(rand.Reader, 2048)
+		keys.rsaKey2, _ = rsa.GenerateKey(rand.Reader, 2048)
+		keys.ecKey, _ = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		keys.ecKey2, _ = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		_, keys.edKey, _ = ed25519.GenerateKey(rand.Reader)
+		_, keys.edKey2, _ = ed25519.GenerateKey(rand.Reader)
+		keys.rsaDERAsHS, _ = x509.MarshalPKIXPublicKey(&keys.rsaKey.PublicKey)
+	})
+	if __jwtTestKeys.rsaKey == nil || __jwtTestKeys.ecKey == nil || __jwtTestKeys.edKey == nil {
+		t.Fatal("failed to generate the keys")
+	}
+	return &__jwtTestKeys
+}
+
+func (keys *jwtTestKeys) keySet() JWTKeys {
+	return JWTKeys{
+		"hs":  keys.secret,
+		"rsa": &keys.rsaKey.PublicKey,
+		"ec":  &keys.ecKey.PublicKey,
+		"ed":  keys.edKey.Public().(ed25519.PublicKey),
+	}
+}
+
+// signJWT returns a token of header and claims signed by key, which is []byte, *rsa.PrivateKey,
+// *ecdsa.PrivateKey or ed25519.PrivateKey, the signature is empty if key is nil.
+func signJWT(t *testing.T, header, claims map[string]interface{}, key interface{}) string {
+	headerJSON, err := json.Marshal(header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claimsJSON, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := base64.RawURLEncoding.EncodeToString(headerJSON) + "." + base64.RawURLEncoding.EncodeToString(claimsJSON)
+	digest := sha256.Sum256([]byte(input))
+	var signature []byte
+	switch key := key.(type) {
+	case nil:
+	case []byte:
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(input))
+		signature = mac.Sum(nil)
+	case *rsa.PrivateKey:
+		if signature, err = rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:]); err != nil {
+			t.Fatal(err)
+		}
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		signature = make([]byte, 64)
+		r.FillBytes(signature[:32])
+		s.FillBytes(signature[32:])
+	case ed25519.PrivateKey:
+		signature = ed25519.Sign(key, []byte(input))
+	default:
+		t.Fatalf("unsupported key %T", key)
+	}
+	return input + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func TestJWTVerify(t *testing.T) {
+	keys := testJWTKeys(t)
+	now := time.Unix(1600000000, 0)
+	claims := map[string]interface{}{"sub": "alice", "iss": "auth", "aud": []string{"api", "web"}, "exp": now.Unix() + 60}
+	header := func(alg, kid string) map[string]interface{} {
+		return map[string]interface{}{"alg": alg, "kid": kid, "typ": "JWT"}
+	}
+	valid := signJWT(t, header("HS256", "hs"), claims, keys.secret)
+	parts := strings.Split(valid, ".")
+	otherPayload := strings.Split(signJWT(t, header("HS256", "hs"), map[string]interface{}{"sub": "admin"}, keys.secret), ".")[1]
+	flipped := []byte(parts[2])
+	flipped[0] ^= 'A' ^ 'B'
+
+	tests := []struct {
+		name  string
+		token string
+		ok    bool
+	}{
+		{"HS256", valid, true},
+		{"RS256", signJWT(t, header("RS256", "rsa"), claims, keys.rsaKey), true},
+		{"ES256", signJWT(t, header("ES256", "ec"), claims, keys.ecKey), true},
+		{"EdDSA", signJWT(t, header("EdDSA", "ed"), claims, keys.edKey), true},
+		{"HS256 wrong secret", signJWT(t, header("HS256", "hs"), claims, []byte("another secret")), false},
+		{"RS256 wrong key", signJWT(t, header("RS256", "rsa"), claims, keys.rsaKey2), false},
+		{"ES256 wrong key", signJWT(t, header("ES256", "ec"), claims, keys.ecKey2), false},
+		{"EdDSA wrong key", signJWT(t, header("EdDSA", "ed"), claims, keys.edKey2), false},
+		{"unknown kid", signJWT(t, header("HS256", "unknown"), claims, keys.secret), false},
+		// alg mismatch
+		{"alg none", signJWT(t, header("none", "hs"), claims, nil), false},
+		{"HS256 with RSA public key", signJWT(t, header("HS256", "rsa"), claims, keys.rsaDERAsHS), false},
+		{"RS256 with HMAC secret", signJWT(t, header("RS256", "hs"), claims, keys.rsaKey), false},
+		{"ES256 with Ed25519 key", signJWT(t, header("ES256", "ed"), claims, keys.ecKey), false},
+		// tampered
+		{"tampered payload", parts[0] + "." + otherPayload + "." + parts[2], false},
+		{"tampered signature", parts[0] + "." + parts[1] + "." + string(flipped), false},
+		{"truncated signature", parts[0] + "." + parts[1] + "." + parts[2][:10], false},
+		{"no signature", parts[0] + "." + parts[1] + ".", false},
+		{"malformed", parts[0] + "." + parts[1], false},
+		{"bad base64", parts[0] + ".!!!." + parts[2], false},
+		{"crit header", signJWT(t, map[string]interface{}{"alg": "HS256", "kid": "hs", "crit": []string{"exp"}}, claims, keys.secret), false},
+	}
+	verifier := newJWTVerifier(JWTConfig{Keys: keys.keySet(), Issuer: "auth", Audience: "api"})
+	for _, tt := range tests {
+		got, err := verifier.verify(tt.token, now)
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: got error %v, want ok %t", tt.name, err, tt.ok)
+			continue
+		}
+		if tt.ok && got.(*JWTStandardClaims).Subject != "alice" {
+			t.Errorf("%s: got claims %+v", tt.name, got)
+		}
+	}
+}
+
+func TestJWTAlgorithms(t *testing.T) {
+	keys := testJWTKeys(t)
+	verifier := newJWTVerifier(JWTConfig{Keys: keys.keySet(), Algorithms: []string{JWTAlgRS256}})
+	claims := map[string]interface{}{"sub": "alice"}
+	if _, err := verifier.verify(signJWT(t, map[string]interface{}{"alg": "RS256", "kid": "rsa"}, claims, keys.rsaKey), time.Now()); err != nil {
+		t.Errorf("RS256 got error %v", err)
+	}
+	if _, err := verifier.verify(signJWT(t, map[string]interface{}{"alg": "HS256", "kid": "hs"}, claims, keys.secret), time.Now()); err == nil {
+		t.Error("HS256 is not rejected by the allow-list")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("newJWTVerifier() with an unsupported algorithm did not panic")
+		}
+	}()
+	newJWTVerifier(JWTConfig{Keys: keys.keySet(), Algorithms: []string{"none"}})
+}
+
+func TestJWTValidateClaims(t *testing.T) {
+	keys := testJWTKeys(t)
+	now := time.Unix(1600000000, 0)
+	tests := []struct {
+		name   string
+		config JWTConfig
+		claims map[string]interface{}
+		ok     bool
+	}{
+		{"no claims", JWTConfig{}, map[string]interface{}{}, true},
+		{"exp in future", JWTConfig{}, map[string]interface{}{"exp": now.Unix() + 1}, true},
+		{"exp now", JWTConfig{}, map[string]interface{}{"exp": now.Unix()}, false},
+		{"exp within leeway", JWTConfig{Leeway: 10 * time.Second}, map[string]interface{}{"exp": now.Unix() - 5}, true},
+		{"exp beyond leeway", JWTConfig{Leeway: 10 * time.Second}, map[string]interface{}{"exp": now.Unix() - 10}, false},
+		{"fractional exp", JWTConfig{}, map[string]interface{}{"exp": float64(now.Unix()) + 0.5}, true},
+		{"exp required", JWTConfig{RequireExpiration: true}, map[string]interface{}{}, false},
+		{"nbf in past", JWTConfig{}, map[string]interface{}{"nbf": now.Unix()}, true},
+		{"nbf in future", JWTConfig{}, map[string]interface{}{"nbf": now.Unix() + 1}, false},
+		{"nbf within leeway", JWTConfig{Leeway: 10 * time.Second}, map[string]interface{}{"nbf": now.Unix() + 10}, true},
+		{"nbf beyond leeway", JWTConfig{Leeway: 10 * time.Second}, map[string]interface{}{"nbf": now.Unix() + 11}, false},
+		{"bad exp", JWTConfig{}, map[string]interface{}{"exp": "tomorrow"}, false},
+		{"iss", JWTConfig{Issuer: "auth"}, map[string]interface{}{"iss": "auth"}, true},
+		{"iss mismatch", JWTConfig{Issuer: "auth"}, map[string]interface{}{"iss": "evil"}, false},
+		{"iss missing", JWTConfig{Issuer: "auth"}, map[string]interface{}{}, false},
+		{"aud string", JWTConfig{Audience: "api"}, map[string]interface{}{"aud": "api"}, true},
+		{"aud array", JWTConfig{Audience: "api"}, map[string]interface{}{"aud": []string{"web", "api"}}, true},
+		{"aud mismatch", JWTConfig{Audience: "api"}, map[string]interface{}{"aud": []string{"web"}}, false},
+		{"aud missing", JWTConfig{Audience: "api"}, map[string]interface{}{}, false},
+	}
+	for _, tt := range tests {
+		tt.config.Keys = keys.keySet()
+		verifier := newJWTVerifier(tt.config)
+		_, err := verifier.verify(signJWT(t, map[string]interface{}{"alg": "HS256", "kid": "hs"}, tt.claims, keys.secret), now)
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: got error %v, want ok %t", tt.name, err, tt.ok)
+		}
+	}
+}
+
+func TestJWT(t *testing.T) {
+	keys := testJWTKeys(t)
+	type claims struct {
+		JWTStandardClaims
+		Role string `json:"role"`
+	}
+	engine := gin.New()
+	engine.Use(JWT(JWTConfig{
+		Keys:   keys.keySet(),
+		Claims: func() interface{} { return new(claims) },
+		Realm:  "api",
+	}))
+	engine.Get("/", func(ctx *gin.Context) {
+		c := JWTClaims(ctx).(*claims)
+		if principal, _ := AuthPrincipal(ctx); principal != c {
+			t.Errorf("got principal %v, want the claims", principal)
+		}
+		ctx.String(200, "%s %s", c.Subject, c.Role)
+	})
+
+	exp := time.Now().Add(time.Minute).Unix()
+	tests := []struct {
+		token     string
+		code      int
+		body      string
+		challenge string
+	}{
+		{signJWT(t, map[string]interface{}{"alg": "EdDSA", "kid": "ed"}, map[string]interface{}{"sub": "alice", "role": "admin", "exp": exp}, keys.edKey), 200, "alice admin", ""},
+		{signJWT(t, map[string]interface{}{"alg": "EdDSA", "kid": "ed"}, map[string]interface{}{"sub": "alice", "exp": exp - 120}, keys.edKey), 401, "", `Bearer realm="api", error="invalid_token"`},
+		{"", 401, "", `Bearer realm="api"`},
+	}
+	for i, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		if tt.token != "" {
+			r.Header.Set(gin.HeaderAuthorization, "Bearer "+tt.token)
+		}
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, r)
+		if got := w.Header().Get(gin.HeaderWWWAuthenticate); w.Code != tt.code || got != tt.challenge {
+			t.Errorf("#%d: got %d %q, want %d %q", i, w.Code, got, tt.code, tt.challenge)
+		}
+		if tt.code == 200 && w.Body.String() != tt.body {
+			t.Errorf("#%d: got body %q, want %q", i, w.Body.String(), tt.body)
+		}
+	}
+}
+
+// jwksDocument returns the JWK Set document of the public keys of keys, kid --> key.
+func jwksDocument(t *testing.T, keys map[string]interface{}) []byte {
+	b64 := base64.RawURLEncoding.EncodeToString
+	var set struct {
+		Keys []map[string]string `json:"keys"`
+	}
+	for kid, key := range keys {
+		var jwk map[string]string
+		switch key := key.(type) {
+		case []byte:
+			jwk = map[string]string{"kty": "oct", "k": b64(key)}
+		case *rsa.PrivateKey:
+			jwk = map[string]string{"kty": "RSA", "n": b64(key.N.Bytes()), "e": b64(big.NewInt(int64(key.E)).Bytes())}
+		case *ecdsa.PrivateKey:
+			x, y := make([]byte, 32), make([]byte, 32)
+			key.X.FillBytes(x)
+			key.Y.FillBytes(y)
+			jwk = map[string]string{"kty": "EC", "crv": "P-256", "x": b64(x), "y": b64(y)}
+		case ed25519.PrivateKey:
+			jwk = map[string]string{"kty": "OKP", "crv": "Ed25519", "x": b64(key.Public().(ed25519.PublicKey))}
+		default:
+			t.Fatalf("unsupported key %T", key)
+		}
+		jwk["kid"] = kid
+		set.Keys = append(set.Keys, jwk)
+	}
+	data, err := json.Marshal(set)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestParseJWKS(t *testing.T) {
+	keys := testJWTKeys(t)
+	set, err := ParseJWKS(jwksDocument(t, map[string]interface{}{
+		"rsa": keys.rsaKey, "ec": keys.ecKey, "ed": keys.edKey,
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifier := newJWTVerifier(JWTConfig{Keys: set})
+	for kid, key := range map[string]interface{}{"rsa": keys.rsaKey, "ec": keys.ecKey, "ed": keys.edKey} {
+		alg := map[string]string{"rsa": "RS256", "ec": "ES256", "ed": "EdDSA"}[kid]
+		if _, err := verifier.verify(signJWT(t, map[string]interface{}{"alg": alg, "kid": kid}, map[string]interface{}{}, key), time.Now()); err != nil {
+			t.Errorf("%s: got error %v", alg, err)
+		}
+	}
+
+	// the symmetric keys are skipped
+	set, err = ParseJWKS(jwksDocument(t, map[string]interface{}{"hs": keys.secret}))
+	if err != nil || len(set) != 0 {
+		t.Errorf("got %v %v, want the oct key skipped", set, err)
+	}
+	set, err = ParseJWKS([]byte(`{"keys":[{"kty":"RSA","kid":"enc","use":"enc","n":"AQ","e":"AQAB"},{"kty":"EC","kid":"p384","crv":"P-384"}]}`))
+	if err != nil || len(set) != 0 {
+		t.Errorf("got %v %v, want the keys skipped", set, err)
+	}
+	if _, err = ParseJWKS([]byte(`{"keys":[{"kty":"EC","crv":"P-256","x":"AQ","y":"AQ"}]}`)); err == nil {
+		t.Error("the EC key not on the curve is not rejected")
+	}
+}
+
+func TestParseJWKSAlg(t *testing.T) {
+	keys := testJWTKeys(t)
+	var document map[string][]map[string]string
+	if err := json.Unmarshal(jwksDocument(t, map[string]interface{}{"rsa": keys.rsaKey}), &document); err != nil {
+		t.Fatal(err)
+	}
+	document["keys"][0]["alg"] = "RS256"
+	data, err := json.Marshal(document)
+	if err != nil {
+		t.Fatal(err)
+	}
+	set, err := ParseJWKS(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key, ok := set["rsa"].(JWTKey); !ok || key.Alg != JWTAlgRS256 {
+		t.Fatalf("got %#v, want JWTKey of RS256", set["rsa"])
+	}
+	if key, err := set.Key("rsa", JWTAlgRS256); err != nil {
+		t.Error(err)
+	} else if _, ok := key.(*rsa.PublicKey); !ok {
+		t.Errorf("got key %T", key)
+	}
+	if _, err := set.Key("rsa", JWTAlgES256); err == nil {
+		t.Error("the key declared for RS256 is returned for ES256")
+	}
+}
+
+func TestJWKSRefresh(t *testing.T) {
+	keys := testJWTKeys(t)
+	var mu sync.Mutex
+	document := jwksDocument(t, map[string]interface{}{"old": keys.rsaKey})
+	loads := 0
+	jwks, err := NewJWKS(func() ([]byte, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		loads++
+		return document, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwks.MinRefreshInterval = time.Hour
+	setDocument := func(keys map[string]interface{}) {
+		data := jwksDocument(t, keys)
+		mu.Lock()
+		document = data
+		mu.Unlock()
+	}
+
+	if _, err := jwks.Key("old", JWTAlgRS256); err != nil || loads != 1 {
+		t.Fatalf("got error %v after %d loads", err, loads)
+	}
+
+	// the key is rotated, the unknown kid is rate-limited by MinRefreshInterval
+	setDocument(map[string]interface{}{"old": keys.rsaKey, "new": keys.edKey})
+	if _, err := jwks.Key("new", JWTAlgEdDSA); err == nil || loads != 1 {
+		t.Errorf("got error %v after %d loads, want the refresh rate-limited", err, loads)
+	}
+
+	// the unknown kid refreshes the keys after MinRefreshInterval
+	jwks.lastRefresh = time.Now().Add(-2 * time.Hour)
+	if key, err := jwks.Key("new", JWTAlgEdDSA); err != nil || loads != 2 {
+		t.Errorf("got error %v after %d loads, want the keys refreshed", err, loads)
+	} else if _, ok := key.(ed25519.PublicKey); !ok {
+		t.Errorf("got key %T", key)
+	}
+	if _, err := jwks.Key("unknown", JWTAlgEdDSA); err == nil || loads != 2 {
+		t.Errorf("got error %v after %d loads, want the refresh rate-limited", err, loads)
+	}
+
+	// the keys are not changed if the reloading fails
+	mu.Lock()
+	document = []byte("{")
+	mu.Unlock()
+	if err := jwks.Refresh(); err == nil {
+		t.Error("Refresh() of the invalid document got no error")
+	}
+	if _, err := jwks.Key("new", JWTAlgEdDSA); err != nil || loads != 3 {
+		t.Errorf("got error %v after %d loads, want the old keys", err, loads)
+	}
+}
+
+func TestNewJWKSFromHandler(t *testing.T) {
+	keys := testJWTKeys(t)
+	document := jwksDocument(t, map[string]interface{}{"rsa": keys.rsaKey})
+	mux := http.NewServeMux()
+	mux.HandleFunc("/jwks.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(gin.HeaderContentType, gin.MIMEApplicationJSON)
+		w.Write(document)
+	})
+	mux.HandleFunc("/broken", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "broken", http.StatusInternalServerError)
+	})
+
+	jwks, err := NewJWKSFromHandler(mux, "/jwks.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := jwks.Key("rsa", JWTAlgRS256); err != nil {
+		t.Error(err)
+	}
+	if _, err := NewJWKSFromHandler(mux, "/broken"); err == nil || !strings.Contains(err.Error(), "500") {
+		t.Errorf("got error %v, want the http status", err)
+	}
+	if _, err := NewJWKS(func() ([]byte, error) { return nil, errors.New("unavailable") }); err == nil {
+		t.Error("NewJWKS() got no error when loading fails")
+	}
+}
@@ -0,0 +1,82 @@
+// Copyright 2014 Manu Martinez-Almeida.  All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package middleware
+
+import (
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/chanxuehong/gin"
+)
+
+// MethodOverrideConfig is the configuration of MethodOverrideWithConfig.
+type MethodOverrideConfig struct {
+	// Methods is the allow-list of the methods which a POST request can be overridden to.
+	// Default is PUT, PATCH and DELETE.
+	Methods []string
+
+	// Header is the name of the header which carries the method.
+	// Default is X-HTTP-Method-Override.
+	Header string
+
+	// FormField is the name of the form field which carries the method, it is read only if
+	// the header is absent and the body of request is a form (urlencoded or multipart).
+	// Default is "_method", "-" disables reading the form.
+	FormField string
+}
+
+// MethodOverride returns a pre-routing hook which overrides the method of POST request
+// with the X-HTTP-Method-Override header or the "_method" form field, the method can be
+// overridden to PUT, PATCH or DELETE. It should be added by Engine.PreRouting():
+//     engine.PreRouting(middleware.MethodOverride())
+func MethodOverride() func(*http.Request) {
+	return MethodOverrideWithConfig(MethodOverrideConfig{})
+}
+
+// MethodOverrideWithConfig returns a pre-routing hook like MethodOverride with the config.
+func MethodOverrideWithConfig(config MethodOverrideConfig) func(*http.Request) {
+	methods := config.Methods
+	if len(methods) == 0 {
+		methods = []string{http.MethodPut, http.MethodPatch, http.MethodDelete}
+	}
+	allowed := make(map[string]struct{}, len(methods))
+	for _, method := range methods {
+		allowed[strings.ToUpper(method)] = struct{}{}
+	}
+	header := config.Header
+	if header == "" {
+		header = gin.HeaderXHTTPMethodOverride
+	}
+	formField := config.FormField
+	if formField == "" {
+		formField = "_method"
+	}
+
+	return func(r *http.Request) {
+		if r.Method != http.MethodPost {
+			return
+		}
+		method := r.Header.Get(header)
+		if method == "" && formField != "-" && isForm(r) {
+			method = r.PostFormValue(formField)
+		}
+		if method == "" {
+			return
+		}
+		method = strings.ToUpper(method)
+		if _, ok := allowed[method]; ok {
+			r.Method = method
+		}
+	}
+}
+
+func isForm(r *http.Request) bool {
+	contentType, _, err := mime.ParseMediaType(r.Header.Get(gin.HeaderContentType))
+	if err != nil {
+		return false
+	}
+	return contentType == gin.MIMEApplicationURLEncodedForm || contentType == gin.MIMEMultipartForm
+}
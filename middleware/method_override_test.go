@@ -0,0 +1,83 @@
+// Copyright 2014 Manu Martinez-Almeida.  All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/chanxuehong/gin"
+)
+
+func TestMethodOverride(t *testing.T) {
+	engine := gin.New()
+	engine.PreRouting(MethodOverride())
+	for _, method := range []string{http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete, http.MethodGet} {
+		method := method
+		engine.Handle(method, "/items", func(ctx *gin.Context) { ctx.String(200, "%s", method) })
+	}
+
+	tests := []struct {
+		method string
+		header string
+		form   string
+		want   string
+	}{
+		{http.MethodPost, "", "", http.MethodPost},
+		{http.MethodPost, "put", "", http.MethodPut},
+		{http.MethodPost, "", "_method=DELETE", http.MethodDelete},
+		// the header takes precedence over the form field
+		{http.MethodPost, "PATCH", "_method=DELETE", http.MethodPatch},
+		// the methods not in the allow-list are ignored
+		{http.MethodPost, "GET", "", http.MethodPost},
+		{http.MethodPost, "", "_method=CONNECT", http.MethodPost},
+		// only POST can be overridden
+		{http.MethodGet, "DELETE", "", http.MethodGet},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(tt.method, "/items", strings.NewReader(tt.form))
+		if tt.form != "" {
+			r.Header.Set(gin.HeaderContentType, gin.MIMEApplicationURLEncodedForm)
+		}
+		if tt.header != "" {
+			r.Header.Set(gin.HeaderXHTTPMethodOverride, tt.header)
+		}
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, r)
+		if w.Body.String() != tt.want {
+			t.Errorf("%s header=%q form=%q got %q, want %q", tt.method, tt.header, tt.form, w.Body.String(), tt.want)
+		}
+	}
+}
+
+func TestMethodOverrideWithConfig(t *testing.T) {
+	hook := MethodOverrideWithConfig(MethodOverrideConfig{
+		Methods:   []string{"delete"},
+		Header:    "X-Method",
+		FormField: "-",
+	})
+	tests := []struct {
+		header string
+		form   string
+		want   string
+	}{
+		{"DELETE", "", http.MethodDelete},
+		{"PUT", "", http.MethodPost},
+		{"", "_method=DELETE", http.MethodPost}, // the form is disabled
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.form))
+		r.Header.Set(gin.HeaderContentType, gin.MIMEApplicationURLEncodedForm)
+		if tt.header != "" {
+			r.Header.Set("X-Method", tt.header)
+		}
+		hook(r)
+		if r.Method != tt.want {
+			t.Errorf("header=%q form=%q got %s, want %s", tt.header, tt.form, r.Method, tt.want)
+		}
+	}
+}
@@ -0,0 +1,260 @@
+// Copyright 2014 Manu Martinez-Almeida.  All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package middleware
+
+import (
+	"hash/fnv"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/chanxuehong/gin"
+)
+
+// RateLimitRule is the rule of a token bucket: Requests tokens are refilled every Period,
+// and the bucket holds at most Burst tokens. Each request takes one token.
+type RateLimitRule struct {
+	Requests int
+	Period   time.Duration
+	Burst    int // default is Requests
+}
+
+func (rule RateLimitRule) rate() float64 {
+	return float64(rule.Requests) / rule.Period.Seconds()
+}
+
+func (rule RateLimitRule) burst() int {
+	if rule.Burst > 0 {
+		return rule.Burst
+	}
+	return rule.Requests
+}
+
+func (rule RateLimitRule) check() {
+	if rule.Requests <= 0 || rule.Period <= 0 || rule.Burst < 0 {
+		panic("invalid rate limit rule")
+	}
+}
+
+// RateLimitResult is the result of taking a token from a bucket.
+type RateLimitResult struct {
+	Allowed    bool
+	Limit      int           // the burst of the rule
+	Remaining  int           // the remaining tokens
+	Reset      time.Duration // the time until the bucket is full
+	RetryAfter time.Duration // the time until a token is available, zero if Allowed
+}
+
+// RateLimitStore is the storage of the token buckets, it must be safe for concurrent use.
+// A shared backend (e.g. redis) can be plugged in by implementing it.
+type RateLimitStore interface {
+	// Take takes a token from the bucket of key, the bucket is created with the rule if it does not exist.
+	Take(key string, rule RateLimitRule) (RateLimitResult, error)
+}
+
+// RateLimitConfig is the configuration of RateLimit.
+type RateLimitConfig struct {
+	// Rule is the default rule of the requests, it is required.
+	Rule RateLimitRule
+
+	// Classes are the rules of the routes with the MetaRateLimitClass metadata (see RegisteredRoute.RateLimitClass()),
+	// rule name --> rule. The routes of each class have their own buckets.
+	Classes map[string]RateLimitRule
+
+	// Key returns the key of bucket of the request, the request is not limited if it returns "".
+	// Default is RateLimitKeyByClientIP.
+	Key func(ctx *gin.Context) string
+
+	// Store stores the buckets. Default is a NewMemoryRateLimitStore(0).
+	// If Store returns an error, the request is allowed.
+	Store RateLimitStore
+
+	// Exceeded is called when the request is limited, the handler chain is aborted after it returns.
+	// The Retry-After header has been set. Default writes 429 Too Many Requests.
+	Exceeded gin.HandlerFunc
+}
+
+// RateLimitKeyByClientIP returns the client IP as the key, see Context.ClientIP().
+func RateLimitKeyByClientIP(ctx *gin.Context) string {
+	return ctx.ClientIP()
+}
+
+// RateLimitKeyByHeader returns a key function which returns the value of header as the key,
+// e.g. RateLimitKeyByHeader("X-API-Key").
+func RateLimitKeyByHeader(header string) func(ctx *gin.Context) string {
+	return func(ctx *gin.Context) string {
+		return ctx.Request.Header.Get(header)
+	}
+}
+
+// RateLimitKeyByRoute returns the method and path pattern of the matched route as the key,
+// e.g. "GET /users/:id", so all the clients share the bucket of a route.
+func RateLimitKeyByRoute(ctx *gin.Context) string {
+	route, ok := ctx.Route()
+	if !ok {
+		return ""
+	}
+	return route.Method + " " + route.Host + route.Path
+}
+
+// RateLimit returns a middleware that limits the rate of requests by the token bucket of each key.
+// It sets the RateLimit-Limit, RateLimit-Remaining and RateLimit-Reset headers, and the Retry-After
+// header if the request is limited.
+//
+// Different limits can be used for route groups by the middlewares of groups:
+//     api := engine.Group("/api", middleware.RateLimit(middleware.RateLimitConfig{
+//         Rule: middleware.RateLimitRule{Requests: 100, Period: time.Minute},
+//     }))
+func RateLimit(config RateLimitConfig) gin.HandlerFunc {
+	config.Rule.check()
+	for _, rule := range config.Classes {
+		rule.check()
+	}
+	key := config.Key
+	if key == nil {
+		key = RateLimitKeyByClientIP
+	}
+	store := config.Store
+	if store == nil {
+		store = NewMemoryRateLimitStore(0)
+	}
+	exceeded := config.Exceeded
+	if exceeded == nil {
+		exceeded = func(ctx *gin.Context) {
+			ctx.String(http.StatusTooManyRequests, "429 too many requests")
+		}
+	}
+
+	return func(ctx *gin.Context) {
+		k := key(ctx)
+		if k == "" {
+			ctx.Next()
+			return
+		}
+		rule := config.Rule
+		if len(config.Classes) > 0 {
+			if route, ok := ctx.Route(); ok {
+				class := route.Meta.String(gin.MetaRateLimitClass)
+				if classRule, ok := config.Classes[class]; ok {
+					rule = classRule
+					k = class + "\x00" + k
+				}
+			}
+		}
+
+		result, err := store.Take(k, rule)
+		if err != nil {
+			ctx.Next()
+			return
+		}
+		header := ctx.ResponseWriter.Header()
+		header.Set(gin.HeaderRateLimitLimit, strconv.Itoa(result.Limit))
+		header.Set(gin.HeaderRateLimitRemaining, strconv.Itoa(result.Remaining))
+		header.Set(gin.HeaderRateLimitReset, ceilSeconds(result.Reset))
+		if !result.Allowed {
+			header.Set(gin.HeaderRetryAfter, ceilSeconds(result.RetryAfter))
+			exceeded(ctx)
+			ctx.Abort()
+			return
+		}
+		ctx.Next()
+	}
+}
+
+func ceilSeconds(d time.Duration) string {
+	return strconv.FormatInt(int64(math.Ceil(d.Seconds())), 10)
+}
+
+// ================================================================================================================
+
+const (
+	__defaultRateLimitShards = 32
+	__rateLimitSweepInterval = time.Minute
+)
+
+// memoryRateLimitStore is the in-memory RateLimitStore, the buckets are sharded by the hash of key
+// to reduce the lock contention.
+type memoryRateLimitStore struct {
+	shards []rateLimitShard
+	now    func() time.Time
+}
+
+type rateLimitShard struct {
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time // the time tokens was updated
+	fullAt time.Time // the time the bucket is full, the full buckets are removed by sweep
+}
+
+// NewMemoryRateLimitStore returns an in-memory RateLimitStore with the number of shards,
+// the default number is used if shards <= 0. The full buckets are removed periodically.
+func NewMemoryRateLimitStore(shards int) RateLimitStore {
+	if shards <= 0 {
+		shards = __defaultRateLimitShards
+	}
+	store := &memoryRateLimitStore{
+		shards: make([]rateLimitShard, shards),
+		now:    time.Now,
+	}
+	for i := range store.shards {
+		store.shards[i].buckets = make(map[string]*tokenBucket)
+	}
+	return store
+}
+
+func (store *memoryRateLimitStore) Take(key string, rule RateLimitRule) (RateLimitResult, error) {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	shard := &store.shards[h.Sum32()%uint32(len(store.shards))]
+	now := store.now()
+	rate, burst := rule.rate(), float64(rule.burst())
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	if now.Sub(shard.lastSweep) >= __rateLimitSweepInterval {
+		shard.sweep(now)
+	}
+	bucket := shard.buckets[key]
+	if bucket == nil {
+		bucket = &tokenBucket{tokens: burst, last: now}
+		shard.buckets[key] = bucket
+	} else if elapsed := now.Sub(bucket.last); elapsed > 0 {
+		bucket.tokens = math.Min(burst, bucket.tokens+elapsed.Seconds()*rate)
+		bucket.last = now
+	}
+
+	result := RateLimitResult{Limit: int(burst)}
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = secondsToDuration((1 - bucket.tokens) / rate)
+	}
+	result.Remaining = int(bucket.tokens)
+	result.Reset = secondsToDuration((burst - bucket.tokens) / rate)
+	bucket.fullAt = now.Add(result.Reset)
+	return result, nil
+}
+
+func (shard *rateLimitShard) sweep(now time.Time) {
+	for key, bucket := range shard.buckets {
+		if !now.Before(bucket.fullAt) {
+			delete(shard.buckets, key)
+		}
+	}
+	shard.lastSweep = now
+}
+
+func secondsToDuration(seconds float64) time.Duration {
+	return time.Duration(seconds * float64(time.Second))
+}
@@ -0,0 +1,137 @@
+// Copyright 2014 Manu Martinez-Almeida.  All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package middleware
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/chanxuehong/gin"
+)
+
+func newTestRateLimitStore(now *time.Time) *memoryRateLimitStore {
+	store := NewMemoryRateLimitStore(1).(*memoryRateLimitStore)
+	store.now = func() time.Time { return *now }
+	return store
+}
+
+func TestMemoryRateLimitStore(t *testing.T) {
+	now := time.Unix(1000, 0)
+	store := newTestRateLimitStore(&now)
+	rule := RateLimitRule{Requests: 2, Period: time.Second, Burst: 4}
+
+	tests := []struct {
+		advance   time.Duration
+		allowed   bool
+		remaining int
+		reset     time.Duration
+		retry     time.Duration
+	}{
+		{0, true, 3, 500 * time.Millisecond, 0},
+		{0, true, 2, time.Second, 0},
+		{0, true, 1, 1500 * time.Millisecond, 0},
+		{0, true, 0, 2 * time.Second, 0},
+		{0, false, 0, 2 * time.Second, 500 * time.Millisecond},
+		// half a token is refilled
+		{250 * time.Millisecond, false, 0, 1750 * time.Millisecond, 250 * time.Millisecond},
+		{250 * time.Millisecond, true, 0, 2 * time.Second, 0},
+		// the bucket does not hold more than Burst tokens
+		{time.Hour, true, 3, 500 * time.Millisecond, 0},
+	}
+	for i, tt := range tests {
+		now = now.Add(tt.advance)
+		result, err := store.Take("key", rule)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := RateLimitResult{Allowed: tt.allowed, Limit: 4, Remaining: tt.remaining, Reset: tt.reset, RetryAfter: tt.retry}
+		if result != want {
+			t.Errorf("#%d: got %+v, want %+v", i, result, want)
+		}
+	}
+
+	// the buckets of the other keys are independent
+	if result, _ := store.Take("other", rule); !result.Allowed || result.Remaining != 3 {
+		t.Errorf("got %+v for the other key", result)
+	}
+}
+
+func TestMemoryRateLimitStoreSweep(t *testing.T) {
+	now := time.Unix(1000, 0)
+	store := newTestRateLimitStore(&now)
+	rule := RateLimitRule{Requests: 1, Period: time.Second}
+	store.Take("a", rule)
+	store.Take("b", rule)
+
+	now = now.Add(2 * __rateLimitSweepInterval)
+	store.Take("c", rule)
+	if n := len(store.shards[0].buckets); n != 1 {
+		t.Errorf("got %d buckets after the sweep, want 1", n)
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	now := time.Unix(1000, 0)
+	engine := gin.New()
+	engine.Use(RateLimit(RateLimitConfig{
+		Rule:    RateLimitRule{Requests: 1, Period: 4 * time.Second},
+		Classes: map[string]RateLimitRule{"login": {Requests: 1, Period: time.Minute}},
+		Key:     RateLimitKeyByHeader("X-API-Key"),
+		Store:   newTestRateLimitStore(&now),
+	}))
+	engine.Get("/", func(ctx *gin.Context) { ctx.String(200, "ok") })
+	engine.Post("/login", func(ctx *gin.Context) { ctx.String(200, "ok") }).RateLimitClass("login")
+
+	tests := []struct {
+		advance    time.Duration
+		method     string
+		path       string
+		key        string
+		code       int
+		remaining  string
+		reset      string
+		retryAfter string
+	}{
+		{0, "GET", "/", "a", 200, "0", "4", ""},
+		{0, "GET", "/", "a", 429, "0", "4", "4"},
+		{time.Second, "GET", "/", "a", 429, "0", "3", "3"},
+		{0, "GET", "/", "b", 200, "0", "4", ""},
+		// the class has its own bucket
+		{0, "POST", "/login", "a", 200, "0", "60", ""},
+		{0, "POST", "/login", "a", 429, "0", "60", "60"},
+		{3 * time.Second, "GET", "/", "a", 200, "0", "4", ""},
+		// the request without key is not limited
+		{0, "GET", "/", "", 200, "", "", ""},
+	}
+	for i, tt := range tests {
+		now = now.Add(tt.advance)
+		r := httptest.NewRequest(tt.method, tt.path, nil)
+		if tt.key != "" {
+			r.Header.Set("X-API-Key", tt.key)
+		}
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, r)
+		header := w.Header()
+		if w.Code != tt.code || header.Get(gin.HeaderRateLimitRemaining) != tt.remaining ||
+			header.Get(gin.HeaderRateLimitReset) != tt.reset || header.Get(gin.HeaderRetryAfter) != tt.retryAfter {
+			t.Errorf("#%d %s %s key=%q: got %d remaining=%q reset=%q retry-after=%q, want %d %q %q %q", i, tt.method, tt.path, tt.key,
+				w.Code, header.Get(gin.HeaderRateLimitRemaining), header.Get(gin.HeaderRateLimitReset), header.Get(gin.HeaderRetryAfter),
+				tt.code, tt.remaining, tt.reset, tt.retryAfter)
+		}
+		if tt.key != "" && header.Get(gin.HeaderRateLimitLimit) != "1" {
+			t.Errorf("#%d: got limit %q", i, header.Get(gin.HeaderRateLimitLimit))
+		}
+	}
+}
+
+func TestRateLimitInvalidRule(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("RateLimit() with an invalid rule did not panic")
+		}
+	}()
+	RateLimit(RateLimitConfig{Rule: RateLimitRule{Requests: 1}})
+}
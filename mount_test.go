@@ -0,0 +1,63 @@
+// Copyright 2014 Manu Martinez-Almeida.  All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package gin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMount(t *testing.T) {
+	sub := New()
+	sub.Get("/", func(ctx *Context) { ctx.String(200, "sub root") })
+	sub.Get("/users/:id", func(ctx *Context) { ctx.String(200, "sub user %s", ctx.Param("id")) })
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/vars", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("vars " + r.URL.Path + " " + r.URL.RawPath))
+	})
+	raw := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("raw " + r.URL.Path + " " + r.URL.RawPath))
+	})
+
+	engine := New()
+	engine.Get("/v2/local", func(ctx *Context) { ctx.String(200, "local") })
+	engine.Mount("/v2/", sub)
+	engine.Group("/debug").Mount("", mux)
+	engine.Mount("/raw", raw)
+
+	tests := [...]struct {
+		method, path string
+		code         int
+		body         string
+	}{
+		{"GET", "/v2", 200, "sub root"},
+		{"GET", "/v2/", 200, "sub root"},
+		{"GET", "/v2/users/12", 200, "sub user 12"},
+		{"GET", "/v2/local", 200, "local"},
+		{"GET", "/v2/unknown", 404, "404 page not found"},
+		{"POST", "/v2/users/12", 404, "404 page not found"},
+		{"GET", "/debug/vars", 200, "vars /vars "},
+		{"GET", "/raw/a%2Fb", 200, "raw /a/b /a%2Fb"},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+		if w.Code != tt.code || w.Body.String() != tt.body {
+			t.Errorf("%s %s: got %d %q, want %d %q", tt.method, tt.path, w.Code, w.Body.String(), tt.code, tt.body)
+		}
+	}
+
+	found := false
+	for _, route := range engine.Routes() {
+		if route.Method == "GET" && route.Path == "/v2/users/:id" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("the routes of the mounted engine are not listed")
+	}
+}
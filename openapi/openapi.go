@@ -0,0 +1,282 @@
+// Copyright 2014 Manu Martinez-Almeida.  All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+// Package openapi generates the OpenAPI 3.0 document from the routes registered in gin.Engine.
+//
+// The request and response types of a route are declared by the route metadata, for example:
+//
+//     router.Post("/users", createUser).
+//         Description("create a user").
+//         Tags("user").
+//         Request(CreateUserRequest{}).
+//         Response(201, User{}).
+//         Response(400, nil)
+//     router.Get("/openapi.json", openapi.Handler(router, openapi.Config{
+//         Info: openapi.Info{Title: "user service", Version: "1.0.0"},
+//     }))
+//
+// The `validate` tags of the struct fields are mapped to the schema constraints.
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/chanxuehong/gin"
+)
+
+const Version = "3.0.3"
+
+// Document is the root object of the OpenAPI document.
+type Document struct {
+	OpenAPI    string               `json:"openapi"`
+	Info       Info                 `json:"info"`
+	Servers    []Server             `json:"servers,omitempty"`
+	Paths      map[string]*PathItem `json:"paths"`
+	Components *Components          `json:"components,omitempty"`
+}
+
+type Info struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+type Server struct {
+	URL         string `json:"url"`
+	Description string `json:"description,omitempty"`
+}
+
+// PathItem is the operations available on a single path.
+type PathItem struct {
+	Get     *Operation `json:"get,omitempty"`
+	Put     *Operation `json:"put,omitempty"`
+	Post    *Operation `json:"post,omitempty"`
+	Delete  *Operation `json:"delete,omitempty"`
+	Options *Operation `json:"options,omitempty"`
+	Head    *Operation `json:"head,omitempty"`
+	Patch   *Operation `json:"patch,omitempty"`
+	Trace   *Operation `json:"trace,omitempty"`
+}
+
+func (item *PathItem) setOperation(method string, op *Operation) bool {
+	switch method {
+	case http.MethodGet:
+		item.Get = op
+	case http.MethodPut:
+		item.Put = op
+	case http.MethodPost:
+		item.Post = op
+	case http.MethodDelete:
+		item.Delete = op
+	case http.MethodOptions:
+		item.Options = op
+	case http.MethodHead:
+		item.Head = op
+	case http.MethodPatch:
+		item.Patch = op
+	case http.MethodTrace:
+		item.Trace = op
+	default:
+		return false // CONNECT and custom methods are not supported by OpenAPI
+	}
+	return true
+}
+
+type Operation struct {
+	Tags        []string             `json:"tags,omitempty"`
+	Description string               `json:"description,omitempty"`
+	OperationID string               `json:"operationId,omitempty"`
+	Parameters  []*Parameter         `json:"parameters,omitempty"`
+	RequestBody *RequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*Response `json:"responses"`
+	Deprecated  bool                 `json:"deprecated,omitempty"`
+}
+
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required"`
+	Schema      *Schema `json:"schema,omitempty"`
+}
+
+type RequestBody struct {
+	Required bool                  `json:"required"`
+	Content  map[string]*MediaType `json:"content"`
+}
+
+type Response struct {
+	Description string                `json:"description"`
+	Content     map[string]*MediaType `json:"content,omitempty"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema,omitempty"`
+}
+
+type Components struct {
+	Schemas map[string]*Schema `json:"schemas,omitempty"`
+}
+
+// Config is the configuration of the OpenAPI document generation.
+type Config struct {
+	Info    Info
+	Servers []Server
+
+	// Host is the host pattern (see gin.Engine.Host) whose routes are included in the document.
+	// Default is empty, only the routes not registered by gin.Engine.Host are included. The routes
+	// of the different hosts may have the same method and path, so they are generated in separate documents.
+	Host string
+
+	// CatchAll includes the routes with the *catchAll parameter (e.g. the static files and the mounted handlers).
+	// The OpenAPI path parameter can not contain '/', which is percent-encoded by the generated clients,
+	// so these routes are excluded by default.
+	CatchAll bool
+
+	// Filter reports whether the route should be included in the document.
+	// If Filter is nil, all the routes are included.
+	Filter func(route gin.Route) bool
+}
+
+// Generate generates the OpenAPI document from the routes registered in engine.
+func Generate(engine *gin.Engine, config Config) *Document {
+	doc := &Document{
+		OpenAPI: Version,
+		Info:    config.Info,
+		Servers: config.Servers,
+		Paths:   make(map[string]*PathItem),
+	}
+	schemas := newSchemaRegistry()
+
+	routes := engine.Routes()
+	sort.SliceStable(routes, func(i, j int) bool { return routes[i].Path < routes[j].Path })
+	for _, route := range routes {
+		if !strings.EqualFold(route.Host, config.Host) {
+			continue
+		}
+		if !config.CatchAll && strings.Contains(route.Path, "/*") {
+			continue
+		}
+		if config.Filter != nil && !config.Filter(route) {
+			continue
+		}
+		path, params := convertPath(route.Path)
+		op := &Operation{
+			Tags:        route.Meta.Strings(gin.MetaTags),
+			Description: route.Meta.String(gin.MetaDescription),
+			OperationID: route.Name,
+			Parameters:  params,
+			Responses:   make(map[string]*Response),
+			Deprecated:  route.Meta.Bool(gin.MetaDeprecated),
+		}
+		if obj, ok := route.Meta.Get(gin.MetaRequest); ok && obj != nil {
+			op.RequestBody = &RequestBody{
+				Required: true,
+				Content:  map[string]*MediaType{gin.MIMEApplicationJSON: {Schema: schemas.schemaOf(obj)}},
+			}
+		}
+		obj, _ := route.Meta.Get(gin.MetaResponses)
+		responses, _ := obj.(map[int]interface{})
+		for code, obj := range responses {
+			resp := &Response{Description: http.StatusText(code)}
+			if obj != nil {
+				resp.Content = map[string]*MediaType{gin.MIMEApplicationJSON: {Schema: schemas.schemaOf(obj)}}
+			}
+			op.Responses[strconv.Itoa(code)] = resp
+		}
+		if len(op.Responses) == 0 {
+			op.Responses["default"] = &Response{Description: "default response"}
+		}
+
+		item := doc.Paths[path]
+		if item == nil {
+			item = new(PathItem)
+		}
+		if item.setOperation(route.Method, op) {
+			doc.Paths[path] = item
+		}
+	}
+
+	if len(schemas.components) > 0 {
+		doc.Components = &Components{Schemas: schemas.components}
+	}
+	return doc
+}
+
+// convertPath converts the :param and *catchAll segments of path to the {param} form,
+// and returns the path parameters of it, the parameter constraints are converted to the schemas.
+func convertPath(path string) (string, []*Parameter) {
+	var params []*Parameter
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if segment == "" || (segment[0] != ':' && segment[0] != '*') {
+			continue
+		}
+		param := &Parameter{
+			Name:     segment[1:],
+			In:       "path",
+			Required: true,
+			Schema:   &Schema{Type: "string"},
+		}
+		if index := strings.IndexByte(segment, '<'); index >= 0 && segment[len(segment)-1] == '>' {
+			param.Name = segment[1:index]
+			param.Schema = constraintSchema(segment[index+1 : len(segment)-1])
+		}
+		if segment[0] == '*' {
+			param.Description = "the rest of the path, the '/' in it must not be percent-encoded"
+		}
+		params = append(params, param)
+		segments[i] = "{" + param.Name + "}"
+	}
+	return strings.Join(segments, "/"), params
+}
+
+// constraintSchema returns the schema of the path parameter constraint, see gin.RegisterParamConstraint.
+func constraintSchema(expr string) *Schema {
+	switch expr {
+	case "int":
+		return &Schema{Type: "integer", Format: "int64"}
+	case "uint":
+		zero := float64(0)
+		return &Schema{Type: "integer", Format: "int64", Minimum: &zero}
+	case "uuid":
+		return &Schema{Type: "string", Format: "uuid"}
+	case "alpha":
+		return &Schema{Type: "string", Pattern: "^[a-zA-Z]+$"}
+	case "alnum":
+		return &Schema{Type: "string", Pattern: "^[a-zA-Z0-9]+$"}
+	default:
+		for i := 0; i < len(expr); i++ {
+			if c := expr[i]; !('a' <= c && c <= 'z') && !('A' <= c && c <= 'Z') && !('0' <= c && c <= '9') && c != '_' {
+				return &Schema{Type: "string", Pattern: "^(?:" + expr + ")$"}
+			}
+		}
+		return &Schema{Type: "string"} // custom named constraint
+	}
+}
+
+// Handler returns a HandlerFunc which serves the OpenAPI document in JSON.
+// The document is generated when it is requested the first time.
+func Handler(engine *gin.Engine, config Config) gin.HandlerFunc {
+	var (
+		once sync.Once
+		blob []byte
+		err  error
+	)
+	return func(ctx *gin.Context) {
+		once.Do(func() {
+			blob, err = json.Marshal(Generate(engine, config))
+		})
+		if err != nil {
+			ctx.AbortWithError(http.StatusInternalServerError, "%s", err)
+			return
+		}
+		ctx.JSONBlob(http.StatusOK, blob)
+	}
+}
@@ -0,0 +1,294 @@
+// Copyright 2014 Manu Martinez-Almeida.  All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/chanxuehong/gin"
+)
+
+func TestConvertPath(t *testing.T) {
+	tests := []struct {
+		path    string
+		want    string
+		params  []string
+		schemas []Schema
+	}{
+		{"/users", "/users", nil, nil},
+		{"/users/:id", "/users/{id}", []string{"id"}, []Schema{{Type: "string"}}},
+		{
+			"/users/:id/posts/:post", "/users/{id}/posts/{post}",
+			[]string{"id", "post"}, []Schema{{Type: "string"}, {Type: "string"}},
+		},
+		{"/users/:id<int>", "/users/{id}", []string{"id"}, []Schema{{Type: "integer", Format: "int64"}}},
+		{"/items/:id<uuid>", "/items/{id}", []string{"id"}, []Schema{{Type: "string", Format: "uuid"}}},
+		{"/tags/:name<alpha>", "/tags/{name}", []string{"name"}, []Schema{{Type: "string", Pattern: "^[a-zA-Z]+$"}}},
+		{"/posts/:slug<[a-z-]+>", "/posts/{slug}", []string{"slug"}, []Schema{{Type: "string", Pattern: "^(?:[a-z-]+)$"}}},
+		{"/files/:name<custom>", "/files/{name}", []string{"name"}, []Schema{{Type: "string"}}},
+		{"/src/*filepath", "/src/{filepath}", []string{"filepath"}, []Schema{{Type: "string"}}},
+	}
+	for _, tt := range tests {
+		path, params := convertPath(tt.path)
+		if path != tt.want {
+			t.Errorf("convertPath(%q) got path %q, want %q", tt.path, path, tt.want)
+		}
+		if len(params) != len(tt.params) {
+			t.Errorf("convertPath(%q) got %d params, want %d", tt.path, len(params), len(tt.params))
+			continue
+		}
+		for i, param := range params {
+			if param.Name != tt.params[i] || param.In != "path" || !param.Required {
+				t.Errorf("convertPath(%q) got param %+v", tt.path, param)
+			}
+			if !reflect.DeepEqual(*param.Schema, tt.schemas[i]) {
+				t.Errorf("convertPath(%q) got schema %+v for %s, want %+v", tt.path, *param.Schema, param.Name, tt.schemas[i])
+			}
+		}
+	}
+}
+
+func TestGenerateMeta(t *testing.T) {
+	engine := gin.New()
+	engine.Get("/users/:id", func(*gin.Context) {}).Name("getUser").
+		Description("get a user").Tags("user").Deprecated()
+	engine.Get("/health", func(*gin.Context) {})
+	engine.Get("/internal", func(*gin.Context) {}).Tags("internal")
+
+	doc := Generate(engine, Config{
+		Filter: func(route gin.Route) bool {
+			for _, tag := range route.Meta.Strings(gin.MetaTags) {
+				if tag == "internal" {
+					return false
+				}
+			}
+			return true
+		},
+	})
+	if _, ok := doc.Paths["/internal"]; ok {
+		t.Error("the filtered route is in the document")
+	}
+	op := doc.Paths["/users/{id}"].Get
+	if op == nil {
+		t.Fatal("GET /users/{id} is not in the document")
+	}
+	if op.Description != "get a user" || !reflect.DeepEqual(op.Tags, []string{"user"}) ||
+		op.OperationID != "getUser" || !op.Deprecated {
+		t.Errorf("unexpected operation %+v", op)
+	}
+	if _, ok := op.Responses["default"]; !ok {
+		t.Errorf("got responses %v, want the default response", op.Responses)
+	}
+	if op := doc.Paths["/health"].Get; op.Description != "" || op.Tags != nil || op.Deprecated {
+		t.Errorf("unexpected operation %+v", op)
+	}
+}
+
+func TestGenerateHostAndCatchAll(t *testing.T) {
+	engine := gin.New()
+	engine.Get("/users", func(*gin.Context) {}).Name("listUsers")
+	engine.Get("/src/*filepath", func(*gin.Context) {})
+	engine.Host("api.example.com").Get("/users", func(*gin.Context) {}).Name("listAPIUsers")
+
+	doc := Generate(engine, Config{})
+	if op := doc.Paths["/users"].Get; op.OperationID != "listUsers" {
+		t.Errorf("got operation %q of GET /users, want the route without host", op.OperationID)
+	}
+	if _, ok := doc.Paths["/src/{filepath}"]; ok {
+		t.Error("the catch-all route is in the document by default")
+	}
+
+	doc = Generate(engine, Config{Host: "API.example.com", CatchAll: true})
+	if op := doc.Paths["/users"].Get; op.OperationID != "listAPIUsers" {
+		t.Errorf("got operation %q of GET /users, want the route of host", op.OperationID)
+	}
+	if _, ok := doc.Paths["/src/{filepath}"]; ok || len(doc.Paths) != 1 {
+		t.Errorf("got paths %v, want the routes of host only", doc.Paths)
+	}
+
+	doc = Generate(engine, Config{CatchAll: true})
+	if _, ok := doc.Paths["/src/{filepath}"]; !ok {
+		t.Error("the catch-all route is not in the document with CatchAll")
+	}
+}
+
+func TestValidateFormat(t *testing.T) {
+	type addresses struct {
+		IP   string `json:"ip" validate:"ip"`
+		IPv4 string `json:"ipv4" validate:"ipv4"`
+		IPv6 string `json:"ipv6" validate:"ipv6"`
+	}
+	schemas := newSchemaRegistry()
+	schemas.schemaOf(addresses{})
+	schema := schemas.components["addresses"]
+	if schema == nil {
+		t.Fatalf("got schemas %v", schemas.components)
+	}
+	for name, format := range map[string]string{"ip": "", "ipv4": "ipv4", "ipv6": "ipv6"} {
+		if got := schema.Properties[name].Format; got != format {
+			t.Errorf("got format %q of %s, want %q", got, name, format)
+		}
+	}
+}
+
+type testUser struct {
+	ID    int64   `json:"id"`
+	Name  string  `json:"name" validate:"required,min=1,max=32"`
+	Email *string `json:"email,omitempty" validate:"email"`
+}
+
+type testError struct {
+	Message string `json:"message"`
+}
+
+const __goldenDocument = `{
+  "openapi": "3.0.3",
+  "info": {
+    "title": "test",
+    "version": "1.0.0"
+  },
+  "paths": {
+    "/users": {
+      "post": {
+        "tags": [
+          "user"
+        ],
+        "description": "create a user",
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "$ref": "#/components/schemas/testUser"
+              }
+            }
+          }
+        },
+        "responses": {
+          "201": {
+            "description": "Created",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/testUser"
+                }
+              }
+            }
+          },
+          "400": {
+            "description": "Bad Request",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/testError"
+                }
+              }
+            }
+          }
+        }
+      }
+    },
+    "/users/{id}": {
+      "get": {
+        "operationId": "getUser",
+        "parameters": [
+          {
+            "name": "id",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "integer",
+              "format": "int64"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/testUser"
+                }
+              }
+            }
+          },
+          "404": {
+            "description": "Not Found"
+          }
+        }
+      }
+    }
+  },
+  "components": {
+    "schemas": {
+      "testError": {
+        "type": "object",
+        "properties": {
+          "message": {
+            "type": "string"
+          }
+        }
+      },
+      "testUser": {
+        "type": "object",
+        "properties": {
+          "email": {
+            "type": "string",
+            "format": "email",
+            "nullable": true
+          },
+          "id": {
+            "type": "integer",
+            "format": "int64"
+          },
+          "name": {
+            "type": "string",
+            "minLength": 1,
+            "maxLength": 32
+          }
+        },
+        "required": [
+          "name"
+        ]
+      }
+    }
+  }
+}`
+
+func TestHandlerGolden(t *testing.T) {
+	engine := gin.New()
+	engine.Post("/users", func(*gin.Context) {}).
+		Description("create a user").
+		Tags("user").
+		Request(testUser{}).
+		Response(201, &testUser{}).
+		Response(400, testError{})
+	engine.Get("/users/:id<int>", func(*gin.Context) {}).Name("getUser").
+		Response(200, testUser{}).
+		Response(404, nil)
+	engine.Get("/openapi.json", Handler(engine, Config{
+		Info:   Info{Title: "test", Version: "1.0.0"},
+		Filter: func(route gin.Route) bool { return route.Path != "/openapi.json" },
+	}))
+
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	if w.Code != 200 {
+		t.Fatalf("got status %d", w.Code)
+	}
+	var got bytes.Buffer
+	if err := json.Indent(&got, w.Body.Bytes(), "", "  "); err != nil {
+		t.Fatal(err)
+	}
+	if got.String() != __goldenDocument {
+		t.Errorf("got document:\n%s\nwant:\n%s", got.String(), __goldenDocument)
+	}
+}
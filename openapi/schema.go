@@ -0,0 +1,301 @@
+// Copyright 2014 Manu Martinez-Almeida.  All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package openapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Enum                 []interface{}      `json:"enum,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	ExclusiveMinimum     bool               `json:"exclusiveMinimum,omitempty"`
+	ExclusiveMaximum     bool               `json:"exclusiveMaximum,omitempty"`
+	MinLength            *uint64            `json:"minLength,omitempty"`
+	MaxLength            *uint64            `json:"maxLength,omitempty"`
+	MinItems             *uint64            `json:"minItems,omitempty"`
+	MaxItems             *uint64            `json:"maxItems,omitempty"`
+}
+
+var (
+	__timeType          = reflect.TypeOf(time.Time{})
+	__rawMessageType    = reflect.TypeOf(json.RawMessage(nil))
+	__jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+)
+
+// schemaRegistry converts go types to schemas, the named struct types are
+// registered as components and referenced by $ref.
+type schemaRegistry struct {
+	components map[string]*Schema
+	names      map[reflect.Type]string
+}
+
+func newSchemaRegistry() *schemaRegistry {
+	return &schemaRegistry{
+		components: make(map[string]*Schema),
+		names:      make(map[reflect.Type]string),
+	}
+}
+
+func (r *schemaRegistry) schemaOf(obj interface{}) *Schema {
+	return r.schemaOfType(reflect.TypeOf(obj))
+}
+
+func (r *schemaRegistry) schemaOfType(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch {
+	case t == __timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t == __rawMessageType, t.Implements(__jsonMarshalerType), reflect.PtrTo(t).Implements(__jsonMarshalerType):
+		return &Schema{} // any type
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uintptr:
+		return &Schema{Type: "integer"}
+	case reflect.Int32, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"} // encoding/json encodes []byte as base64 string
+		}
+		return &Schema{Type: "array", Items: r.schemaOfType(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: r.schemaOfType(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return r.structSchema(t)
+		}
+		name, ok := r.names[t]
+		if !ok {
+			name = r.componentName(t)
+			r.names[t] = name
+			r.components[name] = nil // placeholder for recursive types
+			r.components[name] = r.structSchema(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + name}
+	default:
+		return &Schema{} // interface{} and the types which encoding/json does not support
+	}
+}
+
+// componentName returns an unique component name for the named type t.
+func (r *schemaRegistry) componentName(t reflect.Type) string {
+	name := t.Name()
+	if _, ok := r.components[name]; !ok {
+		return name
+	}
+	pkg := t.PkgPath()
+	if index := strings.LastIndexByte(pkg, '/'); index >= 0 {
+		pkg = pkg[index+1:]
+	}
+	name = pkg + "." + t.Name()
+	for i := 2; ; i++ {
+		if _, ok := r.components[name]; !ok {
+			return name
+		}
+		name = pkg + "." + t.Name() + strconv.Itoa(i)
+	}
+}
+
+func (r *schemaRegistry) structSchema(t reflect.Type) *Schema {
+	schema := &Schema{
+		Type:       "object",
+		Properties: make(map[string]*Schema),
+	}
+	r.addFields(schema, t)
+	return schema
+}
+
+// addFields adds the fields of struct type t to schema, following the encoding/json rules.
+func (r *schemaRegistry) addFields(schema *Schema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts := tag, ""
+		if index := strings.IndexByte(tag, ','); index >= 0 {
+			name, opts = tag[:index], tag[index+1:]
+		}
+		if field.Anonymous && name == "" {
+			ft := field.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				r.addFields(schema, ft) // embedded struct, promote its fields
+				continue
+			}
+		}
+		if field.PkgPath != "" { // unexported
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		fieldSchema := r.schemaOfType(field.Type)
+		if strings.Contains(opts, "string") {
+			switch fieldSchema.Type {
+			case "integer", "number", "boolean":
+				fieldSchema = &Schema{Type: "string"}
+			}
+		}
+		if field.Type.Kind() == reflect.Ptr {
+			fieldSchema = withNullable(fieldSchema)
+		}
+		if applyValidateTag(fieldSchema, field.Tag.Get("validate")) {
+			schema.Required = append(schema.Required, name)
+		}
+		schema.Properties[name] = fieldSchema
+	}
+}
+
+// withNullable returns a nullable copy of schema, a $ref schema can not have siblings in OpenAPI 3.0.
+func withNullable(schema *Schema) *Schema {
+	if schema.Ref != "" {
+		return schema
+	}
+	s := *schema
+	s.Nullable = true
+	return &s
+}
+
+// applyValidateTag maps the validate tag (gopkg.in/go-playground/validator.v8) to
+// the constraints of schema, it reports whether the field is required.
+func applyValidateTag(schema *Schema, tag string) (required bool) {
+	if tag == "" || tag == "-" {
+		return false
+	}
+	for _, rule := range strings.Split(tag, ",") {
+		if rule == "dive" {
+			break // the rest rules are for the elements
+		}
+		if rule == "required" {
+			required = true
+			continue
+		}
+		if schema.Ref != "" || strings.ContainsRune(rule, '|') {
+			continue // a $ref schema can not have siblings, and or-rules can not be expressed
+		}
+		name, param := rule, ""
+		if index := strings.IndexByte(rule, '='); index >= 0 {
+			name, param = rule[:index], rule[index+1:]
+		}
+		switch name {
+		case "min", "gte":
+			setLowerBound(schema, param, false)
+		case "max", "lte":
+			setUpperBound(schema, param, false)
+		case "gt":
+			setLowerBound(schema, param, true)
+		case "lt":
+			setUpperBound(schema, param, true)
+		case "len":
+			setLowerBound(schema, param, false)
+			setUpperBound(schema, param, false)
+		case "email":
+			schema.Format = "email"
+		case "url", "uri":
+			schema.Format = "uri"
+		case "uuid", "uuid3", "uuid4", "uuid5":
+			schema.Format = "uuid"
+		case "ip":
+			// IPv4 or IPv6, no format of OpenAPI matches both
+		case "ipv4":
+			schema.Format = "ipv4"
+		case "ipv6":
+			schema.Format = "ipv6"
+		case "alpha":
+			schema.Pattern = "^[a-zA-Z]*$"
+		case "alphanum":
+			schema.Pattern = "^[a-zA-Z0-9]*$"
+		case "numeric":
+			schema.Pattern = "^[-+]?[0-9]+(?:\\.[0-9]+)?$"
+		case "hexadecimal":
+			schema.Pattern = "^(0[xX])?[0-9a-fA-F]+$"
+		}
+	}
+	return
+}
+
+func setLowerBound(schema *Schema, param string, exclusive bool) {
+	switch schema.Type {
+	case "integer", "number":
+		if f, err := strconv.ParseFloat(param, 64); err == nil {
+			schema.Minimum = &f
+			schema.ExclusiveMinimum = exclusive
+		}
+	case "string", "array":
+		n, err := strconv.ParseUint(param, 10, 64)
+		if err != nil {
+			return
+		}
+		if exclusive {
+			n++
+		}
+		switch schema.Type {
+		case "string":
+			schema.MinLength = &n
+		case "array":
+			schema.MinItems = &n
+		}
+	}
+}
+
+func setUpperBound(schema *Schema, param string, exclusive bool) {
+	switch schema.Type {
+	case "integer", "number":
+		if f, err := strconv.ParseFloat(param, 64); err == nil {
+			schema.Maximum = &f
+			schema.ExclusiveMaximum = exclusive
+		}
+	case "string", "array":
+		n, err := strconv.ParseUint(param, 10, 64)
+		if err != nil {
+			return
+		}
+		if exclusive {
+			if n == 0 {
+				return
+			}
+			n--
+		}
+		switch schema.Type {
+		case "string":
+			schema.MaxLength = &n
+		case "array":
+			schema.MaxItems = &n
+		}
+	}
+}
@@ -0,0 +1,174 @@
+// Copyright 2014 Manu Martinez-Almeida.  All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package gin
+
+import (
+	"regexp"
+	"strconv"
+	"sync"
+)
+
+// paramConstraint is the inline constraint of a path parameter, such as
+//     /users/:id<int>
+//     /objects/:uuid<uuid>
+//     /posts/:slug<[a-z0-9-]+>
+// the expression between '<' and '>' is the name of a registered constraint
+// or a regular expression which must match the whole parameter value.
+type paramConstraint struct {
+	key   string // the parameter name
+	expr  string // the constraint expression
+	match func(value string) bool
+}
+
+// splitWildcard splits the wildcard (e.g. ":id<int>") to the parameter name and the constraint expression.
+func splitWildcard(wildcard string) (name, expr string) {
+	name = wildcard[1:]
+	for i := 0; i < len(name); i++ {
+		if name[i] == '<' {
+			expr = name[i+1:]
+			if n := len(expr); n > 0 && expr[n-1] == '>' {
+				expr = expr[:n-1]
+			}
+			return name[:i], expr
+		}
+	}
+	return name, ""
+}
+
+// newParamConstraint returns the paramConstraint of wildcard, or nil if the wildcard has no constraint.
+func newParamConstraint(wildcard string) *paramConstraint {
+	name, expr := splitWildcard(wildcard)
+	if len(name)+1 == len(wildcard) {
+		return nil
+	}
+	if wildcard[len(wildcard)-1] != '>' {
+		panic("constraint must be the end of wildcard '" + wildcard + "'")
+	}
+	if wildcard[0] != ':' {
+		panic("constraint can only be used with the named parameter, has: '" + wildcard + "'")
+	}
+	if name == "" || expr == "" {
+		panic("wildcard '" + wildcard + "' must have a non-empty name and constraint")
+	}
+	match, err := lookupParamConstraint(expr)
+	if err != nil {
+		panic("invalid constraint of wildcard '" + wildcard + "': " + err.Error())
+	}
+	return &paramConstraint{
+		key:   name,
+		expr:  expr,
+		match: match,
+	}
+}
+
+var (
+	__paramConstraintsLock sync.RWMutex
+	__paramConstraints     = map[string]func(string) bool{
+		"int":   isInt,
+		"uint":  isUint,
+		"uuid":  isUUID,
+		"alpha": isAlpha,
+		"alnum": isAlnum,
+	}
+	__paramConstraintRegexps = make(map[string]func(string) bool) // cache of the compiled regexp constraints
+)
+
+// RegisterParamConstraint registers the named constraint of path parameters,
+// so that it can be used like this:
+//     gin.RegisterParamConstraint("even", isEven)
+//     router.Get("/numbers/:n<even>", handler)
+//
+// The builtin constraints are: int, uint, uuid, alpha and alnum.
+// The registration must be done before the routes using it are registered.
+func RegisterParamConstraint(name string, match func(value string) bool) {
+	if name == "" {
+		panic("constraint name can not be empty")
+	}
+	if match == nil {
+		panic("constraint function can not be nil")
+	}
+	__paramConstraintsLock.Lock()
+	__paramConstraints[name] = match
+	__paramConstraintsLock.Unlock()
+}
+
+func lookupParamConstraint(expr string) (func(string) bool, error) {
+	__paramConstraintsLock.RLock()
+	match, ok := __paramConstraints[expr]
+	if !ok {
+		match, ok = __paramConstraintRegexps[expr]
+	}
+	__paramConstraintsLock.RUnlock()
+	if ok {
+		return match, nil
+	}
+
+	re, err := regexp.Compile("^(?:" + expr + ")$")
+	if err != nil {
+		return nil, err
+	}
+	match = re.MatchString
+	__paramConstraintsLock.Lock()
+	__paramConstraintRegexps[expr] = match
+	__paramConstraintsLock.Unlock()
+	return match, nil
+}
+
+func isInt(s string) bool {
+	_, err := strconv.ParseInt(s, 10, 64)
+	return err == nil
+}
+
+func isUint(s string) bool {
+	_, err := strconv.ParseUint(s, 10, 64)
+	return err == nil
+}
+
+func isUUID(s string) bool {
+	if len(s) != 36 {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		switch i {
+		case 8, 13, 18, 23:
+			if s[i] != '-' {
+				return false
+			}
+		default:
+			if !isHex(s[i]) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func isHex(c byte) bool {
+	return ('0' <= c && c <= '9') || ('a' <= c && c <= 'f') || ('A' <= c && c <= 'F')
+}
+
+func isAlpha(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; !('a' <= c && c <= 'z') && !('A' <= c && c <= 'Z') {
+			return false
+		}
+	}
+	return true
+}
+
+func isAlnum(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; !('a' <= c && c <= 'z') && !('A' <= c && c <= 'Z') && !('0' <= c && c <= '9') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,145 @@
+// Copyright 2014 Manu Martinez-Almeida.  All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package gin
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// RedirectPolicy is the policy of the requests redirected by RedirectTrailingSlash and RedirectFixedPath,
+// see Engine.RedirectPolicy().
+type RedirectPolicy struct {
+	// GetCode is the http status code for GET requests.
+	// Default is 301 (http.StatusMovedPermanently).
+	GetCode int
+
+	// Code is the http status code for all other request methods,
+	// it should be 307 or 308 (http.StatusPermanentRedirect) which preserve the method and body.
+	// Default is 307 (http.StatusTemporaryRedirect).
+	Code int
+
+	// If enabled, the request is routed internally with the corrected path instead of redirecting the client,
+	// the URL.Path of the request is changed to the corrected path.
+	Rewrite bool
+
+	// If enabled, the value of the X-Forwarded-Prefix header is prepended to the location of redirection,
+	// so that the prefix stripped by the reverse proxy is kept. The header is used only if the request
+	// comes from a trusted proxy, see Engine.TrustedProxies().
+	ForwardedPrefix bool
+
+	// If enabled, the redirection is done by the handler chain with the middlewares of Engine,
+	// so that the redirected requests are logged, for example.
+	UseMiddleware bool
+}
+
+// RedirectPolicy sets the policy of redirecting the requests whose path is corrected
+// by RedirectTrailingSlash and RedirectFixedPath.
+//
+// Default is RedirectPolicy{GetCode: 301, Code: 307}.
+func (engine *Engine) RedirectPolicy(policy RedirectPolicy) {
+	engine.startedChecker.check() // check if engine has been started.
+	if policy.GetCode == 0 {
+		policy.GetCode = http.StatusMovedPermanently
+	}
+	if policy.Code == 0 {
+		policy.Code = http.StatusTemporaryRedirect
+	}
+	if policy.GetCode < 300 || policy.GetCode > 399 || policy.Code < 300 || policy.Code > 399 {
+		panic("redirect status code must be in the range [300, 399]")
+	}
+	engine.redirectPolicy = policy
+}
+
+func (policy *RedirectPolicy) code(method string) int {
+	if method == http.MethodGet {
+		return policy.GetCode
+	}
+	return policy.Code
+}
+
+// redirect redirects the request to the corrected path, or routes it internally, according to the redirect policy.
+func (engine *Engine) redirect(ctx *Context, path string) {
+	policy := &engine.redirectPolicy
+	req := ctx.Request
+
+	if policy.Rewrite {
+		debugPrintf("rewriting request: %s --> %s\r\n", req.URL.Path, path)
+		if !engine.useRawPath {
+			req.URL.Path, req.URL.RawPath = path, ""
+		} else if unescaped, err := url.PathUnescape(path); err == nil {
+			req.URL.Path, req.URL.RawPath = unescaped, path
+		}
+		engine.serveHTTP(ctx)
+		return
+	}
+
+	code := policy.code(req.Method)
+	location := path
+	if !engine.useRawPath {
+		location = (&url.URL{Path: path}).EscapedPath()
+	}
+	location = strippedPrefix(req) + location
+	if policy.ForwardedPrefix {
+		location = engine.forwardedPrefix(req) + location
+	}
+	if req.URL.RawQuery != "" {
+		location += "?" + req.URL.RawQuery
+	}
+	debugPrintf("redirecting request %d: %s --> %s\r\n", code, req.URL.Path, location)
+
+	handler := func(ctx *Context) {
+		http.Redirect(ctx.ResponseWriter, ctx.Request, location, code)
+	}
+	if policy.UseMiddleware && len(engine.middlewares) > 0 {
+		ctx.handlers = combineHandlerChain(engine.middlewares, HandlerChain{handler})
+		ctx.Next()
+		return
+	}
+	handler(ctx)
+}
+
+// strippedPrefix returns the prefix of the path in Request.RequestURI which has been stripped
+// from Request.URL.Path, e.g. by the Pre middlewares or RouteGroup.Mount(), so that the client is
+// redirected to the URL it requested.
+func strippedPrefix(req *http.Request) string {
+	if req.RequestURI == "" {
+		return ""
+	}
+	original, err := url.ParseRequestURI(req.RequestURI)
+	if err != nil {
+		return ""
+	}
+	originalPath, path := original.EscapedPath(), req.URL.EscapedPath()
+	if len(originalPath) <= len(path) || !strings.HasSuffix(originalPath, path) {
+		return ""
+	}
+	prefix := originalPath[:len(originalPath)-len(path)]
+	if hasDotSegment(prefix) {
+		return "" // the path has been cleaned, see Engine.DotSegments()
+	}
+	return prefix
+}
+
+// forwardedPrefix returns the X-Forwarded-Prefix header of request from a trusted proxy without the trailing '/',
+// an invalid prefix which may redirect the client to another host is ignored.
+func (engine *Engine) forwardedPrefix(req *http.Request) string {
+	if !engine.trustedRequest(req) {
+		return ""
+	}
+	prefix := strings.TrimRight(req.Header.Get(HeaderXForwardedPrefix), "/")
+	if prefix == "" || prefix[0] != '/' || strings.HasPrefix(prefix, "//") || strings.ContainsAny(prefix, "\\?#\r\n") {
+		return ""
+	}
+	return prefix
+}
+
+func toggleTrailingSlash(path string) string {
+	if len(path) > 1 && path[len(path)-1] == '/' {
+		return path[:len(path)-1]
+	}
+	return path + "/"
+}
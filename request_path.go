@@ -0,0 +1,82 @@
+// Copyright 2014 Manu Martinez-Almeida.  All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package gin
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// DotSegmentsPolicy is the policy of the request paths with dot segments ('.' and '..'), see Engine.DotSegments().
+type DotSegmentsPolicy uint8
+
+const (
+	DotSegmentsAllow  DotSegmentsPolicy = iota // the path is routed as it is
+	DotSegmentsClean                           // the path is cleaned before routing, e.g. /a/../b is routed as /b
+	DotSegmentsReject                          // the request is answered with 400 Bad Request
+)
+
+// If enabled, the router matches the escaped path of request (URL.EscapedPath()) instead of URL.Path,
+// so that the encoded slash is a part of segment, e.g. /files/a%2Fb matches /files/:name,
+// and the value of each path parameter is unescaped individually, e.g. ctx.Param("name") returns "a/b".
+//
+// Default is false.
+func (engine *Engine) UseRawPath(b bool) {
+	engine.startedChecker.check() // check if engine has been started.
+	engine.useRawPath = b
+}
+
+// DotSegments sets the policy of the request paths with dot segments, the encoded dot segments
+// (e.g. /%2e%2e/) are also detected. The path is cleaned as pathClean() does if the policy is DotSegmentsClean,
+// and the handlers see the cleaned path.
+//
+// Default is DotSegmentsAllow.
+func (engine *Engine) DotSegments(policy DotSegmentsPolicy) {
+	if policy > DotSegmentsReject {
+		panic("invalid dot segments policy")
+	}
+	engine.startedChecker.check() // check if engine has been started.
+	engine.dotSegments = policy
+}
+
+// hasDotSegment reports whether the path has '.' or '..' segment.
+func hasDotSegment(path string) bool {
+	for len(path) > 0 {
+		end := strings.IndexByte(path, '/')
+		if end < 0 {
+			end = len(path)
+		}
+		if segment := path[:end]; segment == "." || segment == ".." {
+			return true
+		}
+		if end == len(path) {
+			break
+		}
+		path = path[end+1:]
+	}
+	return false
+}
+
+// cleanRequestPath cleans URL.Path and URL.RawPath of request, the RawPath is ignored by
+// URL.EscapedPath() if it is not an encoding of the cleaned Path.
+func cleanRequestPath(r *http.Request) {
+	r.URL.Path = pathClean(r.URL.Path)
+	if r.URL.RawPath != "" {
+		r.URL.RawPath = pathClean(r.URL.RawPath)
+	}
+}
+
+// unescapeParams unescapes the values of params in place, the value which is not a valid escaping is not changed.
+func unescapeParams(params Params) {
+	for i := range params {
+		if strings.IndexByte(params[i].Value, '%') < 0 {
+			continue
+		}
+		if value, err := url.PathUnescape(params[i].Value); err == nil {
+			params[i].Value = value
+		}
+	}
+}
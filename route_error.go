@@ -0,0 +1,59 @@
+// Copyright 2014 Manu Martinez-Almeida.  All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package gin
+
+import (
+	"fmt"
+)
+
+// RouteConflictError is the error of registering a route which conflicts with an existing route,
+// for example:
+//     GET /users/:id
+//     GET /users/:name  // wildcard ':name' conflicts with existing wildcard ':id'
+//
+// It is the value of panic when registering the route by RouteGroup.Handle() and its shortcuts,
+// and it is returned by RouteGroup.TryHandle() and the hot-swapping methods of Engine.
+type RouteConflictError struct {
+	Method       string // the http method of both routes, empty if the route is not registered by Engine
+	Path         string // the path of the new route
+	ExistingPath string // the path of the existing route
+	Reason       string
+}
+
+func (err *RouteConflictError) Error() string {
+	method := err.Method
+	if method != "" {
+		method += " "
+	}
+	return "gin: route '" + method + err.Path + "' conflicts with existing route '" +
+		method + err.ExistingPath + "': " + err.Reason
+}
+
+// TryHandle is like Handle, but it returns the error instead of panicking if the route can not be registered,
+// the error is a *RouteConflictError if the route conflicts with an existing route.
+// The routes registered before are not affected by the failed registration, the trees are restored
+// from the copy taken before registering.
+func (group *RouteGroup) TryHandle(httpMethod, relativePath string, handlers ...HandlerFunc) (route *RegisteredRoute, err error) {
+	v := &group.engine.trees
+	if group.host != nil {
+		v = &group.host.trees
+	}
+	snapshot := v.clone()
+	defer func() {
+		if r := recover(); r != nil {
+			*v = snapshot
+			route, err = nil, recoveredRouteError(r)
+		}
+	}()
+	return group.Handle(httpMethod, relativePath, handlers...), nil
+}
+
+// recoveredRouteError converts the value of panic when registering a route to error.
+func recoveredRouteError(r interface{}) error {
+	if err, ok := r.(error); ok {
+		return err
+	}
+	return fmt.Errorf("gin: %v", r)
+}
@@ -0,0 +1,94 @@
+// Copyright 2014 Manu Martinez-Almeida.  All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package gin
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// dumpTrees returns the structure of the trees, including the priority, indices and maxParams of the nodes.
+func dumpTrees(v trees) string {
+	var b strings.Builder
+	var dump func(n *node, depth int)
+	dump = func(n *node, depth int) {
+		fmt.Fprintf(&b, "%s%q type=%d wild=%t indices=%q priority=%d maxParams=%d handlers=%d\n",
+			strings.Repeat("  ", depth), n.path, n.nType, n.wildChild, n.indices, n.priority, n.maxParams, len(n.handlers))
+		for _, child := range n.children {
+			dump(child, depth+1)
+		}
+	}
+	for _, t := range v {
+		b.WriteString(t.method + "\n")
+		dump(t.root, 1)
+	}
+	return b.String()
+}
+
+func TestTryHandle(t *testing.T) {
+	engine := New()
+	handler := func(ctx *Context) { ctx.String(200, "%s", ctx.Request.URL.Path) }
+	engine.Get("/users/:id", handler).Name("user")
+	engine.Get("/users/:id/posts/:post", handler)
+	engine.Get("/src/*filepath", handler)
+	engine.Get("/doc/go_faq.html", handler)
+
+	trees, routes, maxParams := dumpTrees(engine.trees), engine.Routes(), engine.maxParams
+	tests := []struct {
+		method   string
+		path     string
+		conflict *RouteConflictError
+	}{
+		{"GET", "/users/:name", &RouteConflictError{Method: "GET", Path: "/users/:name", ExistingPath: "/users/:id"}},
+		{"GET", "/users/:name/posts/:post/comments/:c", &RouteConflictError{Method: "GET", Path: "/users/:name/posts/:post/comments/:c", ExistingPath: "/users/:id"}},
+		{"GET", "/users/:id", &RouteConflictError{Method: "GET", Path: "/users/:id", ExistingPath: "/users/:id"}},
+		{"GET", "/doc/new/:a/:b/:c/:d:e", nil},
+		{"POST", "/new/:a:b", nil},
+	}
+	for _, tt := range tests {
+		route, err := engine.TryHandle(tt.method, tt.path, handler)
+		if err == nil || route != nil {
+			t.Errorf("TryHandle(%s %s): got %v, %v, want error", tt.method, tt.path, route, err)
+			continue
+		}
+		if tt.conflict != nil {
+			conflict, ok := err.(*RouteConflictError)
+			if !ok {
+				t.Errorf("TryHandle(%s %s): got %T %v, want *RouteConflictError", tt.method, tt.path, err, err)
+				continue
+			}
+			conflict.Reason = ""
+			if *conflict != *tt.conflict {
+				t.Errorf("TryHandle(%s %s): got %+v, want %+v", tt.method, tt.path, *conflict, *tt.conflict)
+			}
+		}
+
+		// the routes registered before are not affected
+		if got := dumpTrees(engine.trees); got != trees {
+			t.Errorf("TryHandle(%s %s): the trees are changed:\n%s\nwant:\n%s", tt.method, tt.path, got, trees)
+		}
+		if got := engine.Routes(); !reflect.DeepEqual(got, routes) || engine.maxParams != maxParams {
+			t.Errorf("TryHandle(%s %s): got routes %v, want %v", tt.method, tt.path, got, routes)
+		}
+	}
+
+	if url, err := engine.URL("user", 1); err != nil || url != "/users/1" {
+		t.Errorf("URL(user) got %q, %v", url, err)
+	}
+	if _, err := engine.TryHandle("GET", "/doc/new/:a", handler); err != nil {
+		t.Errorf("TryHandle after the failures got %v", err)
+	}
+	for _, path := range []string{"/users/1", "/users/1/posts/2", "/src/a/b", "/doc/go_faq.html", "/doc/new/x"} {
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != 200 || w.Body.String() != path {
+			t.Errorf("GET %s: got %d %q", path, w.Code, w.Body.String())
+		}
+	}
+}
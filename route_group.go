@@ -6,6 +6,7 @@ package gin
 
 import (
 	"net/http"
+	"net/url"
 	"path"
 	"regexp"
 	"strings"
@@ -20,6 +21,7 @@ type RouteGroup struct {
 	basePath    string
 	middlewares HandlerChain
 	engine      *Engine
+	host        *hostRoutes // nil if the group is not created by Engine.Host()
 }
 
 // Group creates a new RouteGroup.
@@ -33,6 +35,7 @@ func (group *RouteGroup) Group(relativePath string, middlewares ...HandlerFunc)
 		basePath:    pathJoin(group.basePath, relativePath),
 		middlewares: combineHandlerChain(group.middlewares, middlewares),
 		engine:      group.engine,
+		host:        group.host,
 	}
 }
 
@@ -57,83 +60,90 @@ var __httpMethodRegexp = regexp.MustCompile("^[A-Z]+$") // support custom method
 // This function is intended for bulk loading and to allow the usage of less
 // frequently used, non-standardized or custom methods (e.g. for internal
 // communication with a proxy).
-func (group *RouteGroup) Handle(httpMethod, relativePath string, handlers ...HandlerFunc) {
+//
+// The returned RegisteredRoute can be used to name the route, see RegisteredRoute.Name().
+func (group *RouteGroup) Handle(httpMethod, relativePath string, handlers ...HandlerFunc) *RegisteredRoute {
 	if !__httpMethodRegexp.MatchString(httpMethod) {
 		panic(`http method "` + httpMethod + `" is not valid`)
 	}
-	group.handle(httpMethod, relativePath, handlers)
+	return group.handle(httpMethod, relativePath, handlers)
 }
 
-func (group *RouteGroup) handle(httpMethod, relativePath string, handlers HandlerChain) {
+func (group *RouteGroup) handle(httpMethod, relativePath string, handlers HandlerChain) *RegisteredRoute {
 	if len(handlers) == 0 {
 		panic("there must be at least one handler")
 	}
 	absolutePath := pathJoin(group.basePath, relativePath)
 	handlers = combineHandlerChain(group.middlewares, handlers)
-	group.engine.addRoute(httpMethod, absolutePath, handlers)
+	info := group.engine.addRoute(group.host, httpMethod, absolutePath, handlers)
+	return &RegisteredRoute{
+		engine: group.engine,
+		infos:  []*routeInfo{info},
+	}
 }
 
 // Any registers a route that matches all the HTTP methods:
 // GET, POST, PUT, PATCH, HEAD, OPTIONS, DELETE, CONNECT, TRACE.
-func (group *RouteGroup) Any(relativePath string, handlers ...HandlerFunc) {
+func (group *RouteGroup) Any(relativePath string, handlers ...HandlerFunc) *RegisteredRoute {
+	route := &RegisteredRoute{engine: group.engine}
 	for _, method := range __httpMethods {
-		group.handle(method, relativePath, handlers)
+		route.merge(group.handle(method, relativePath, handlers))
 	}
+	return route
 }
 
 // Get is a shortcut for group.Handle("GET", relativePath, handlers)
-func (group *RouteGroup) Get(relativePath string, handlers ...HandlerFunc) {
-	group.handle(http.MethodGet, relativePath, handlers)
+func (group *RouteGroup) Get(relativePath string, handlers ...HandlerFunc) *RegisteredRoute {
+	return group.handle(http.MethodGet, relativePath, handlers)
 }
 
 // Head is a shortcut for group.Handle("HEAD", relativePath, handlers)
-func (group *RouteGroup) Head(relativePath string, handlers ...HandlerFunc) {
-	group.handle(http.MethodHead, relativePath, handlers)
+func (group *RouteGroup) Head(relativePath string, handlers ...HandlerFunc) *RegisteredRoute {
+	return group.handle(http.MethodHead, relativePath, handlers)
 }
 
 // Post is a shortcut for group.Handle("POST", relativePath, handlers)
-func (group *RouteGroup) Post(relativePath string, handlers ...HandlerFunc) {
-	group.handle(http.MethodPost, relativePath, handlers)
+func (group *RouteGroup) Post(relativePath string, handlers ...HandlerFunc) *RegisteredRoute {
+	return group.handle(http.MethodPost, relativePath, handlers)
 }
 
 // Put is a shortcut for group.Handle("PUT", relativePath, handlers)
-func (group *RouteGroup) Put(relativePath string, handlers ...HandlerFunc) {
-	group.handle(http.MethodPut, relativePath, handlers)
+func (group *RouteGroup) Put(relativePath string, handlers ...HandlerFunc) *RegisteredRoute {
+	return group.handle(http.MethodPut, relativePath, handlers)
 }
 
 // Patch is a shortcut for group.Handle("PATCH", relativePath, handlers)
-func (group *RouteGroup) Patch(relativePath string, handlers ...HandlerFunc) {
-	group.handle(http.MethodPatch, relativePath, handlers)
+func (group *RouteGroup) Patch(relativePath string, handlers ...HandlerFunc) *RegisteredRoute {
+	return group.handle(http.MethodPatch, relativePath, handlers)
 }
 
 // Delete is a shortcut for group.Handle("DELETE", relativePath, handlers)
-func (group *RouteGroup) Delete(relativePath string, handlers ...HandlerFunc) {
-	group.handle(http.MethodDelete, relativePath, handlers)
+func (group *RouteGroup) Delete(relativePath string, handlers ...HandlerFunc) *RegisteredRoute {
+	return group.handle(http.MethodDelete, relativePath, handlers)
 }
 
 // Connect is a shortcut for group.Handle("CONNECT", relativePath, handlers)
-func (group *RouteGroup) Connect(relativePath string, handlers ...HandlerFunc) {
-	group.handle(http.MethodConnect, relativePath, handlers)
+func (group *RouteGroup) Connect(relativePath string, handlers ...HandlerFunc) *RegisteredRoute {
+	return group.handle(http.MethodConnect, relativePath, handlers)
 }
 
 // Options is a shortcut for group.Handle("OPTIONS", relativePath, handlers)
-func (group *RouteGroup) Options(relativePath string, handlers ...HandlerFunc) {
-	group.handle(http.MethodOptions, relativePath, handlers)
+func (group *RouteGroup) Options(relativePath string, handlers ...HandlerFunc) *RegisteredRoute {
+	return group.handle(http.MethodOptions, relativePath, handlers)
 }
 
 // Trace is a shortcut for group.Handle("TRACE", relativePath, handlers)
-func (group *RouteGroup) Trace(relativePath string, handlers ...HandlerFunc) {
-	group.handle(http.MethodTrace, relativePath, handlers)
+func (group *RouteGroup) Trace(relativePath string, handlers ...HandlerFunc) *RegisteredRoute {
+	return group.handle(http.MethodTrace, relativePath, handlers)
 }
 
 // StaticFile registers a single route in order to serve a single file of the local filesystem.
 //   group.StaticFile("favicon.ico", "./resources/favicon.ico")
-func (group *RouteGroup) StaticFile(relativePath, filepath string) {
+func (group *RouteGroup) StaticFile(relativePath, filepath string) *RegisteredRoute {
 	handler := func(ctx *Context) {
 		ctx.ServeFile(filepath)
 	}
-	group.Head(relativePath, handler)
-	group.Get(relativePath, handler)
+	return group.Head(relativePath, handler).merge(group.Get(relativePath, handler))
 }
 
 // StaticRoot like nginx root location but relativePath must be ended with '/' if not empty.
@@ -145,7 +155,7 @@ func (group *RouteGroup) StaticFile(relativePath, filepath string) {
 //   location ^~ /abc/ {
 //       root   /home/root;
 //   }
-func (group *RouteGroup) StaticRoot(relativePath string, root http.FileSystem) {
+func (group *RouteGroup) StaticRoot(relativePath string, root http.FileSystem) *RegisteredRoute {
 	if relativePath == "" {
 		relativePath = "/"
 	}
@@ -159,8 +169,7 @@ func (group *RouteGroup) StaticRoot(relativePath string, root http.FileSystem) {
 		http.FileServer(root).ServeHTTP(ctx.ResponseWriter, ctx.Request)
 	}
 	relativePath = path.Join(relativePath, "*filepath")
-	group.Head(relativePath, handler)
-	group.Get(relativePath, handler)
+	return group.Head(relativePath, handler).merge(group.Get(relativePath, handler))
 }
 
 // StaticAlias like nginx alias location(also relativePath must be ended with '/' if not empty).
@@ -172,7 +181,7 @@ func (group *RouteGroup) StaticRoot(relativePath string, root http.FileSystem) {
 //   location ^~ /abc/ {
 //       alias  /home/root/abc/;
 //   }
-func (group *RouteGroup) StaticAlias(relativePath string, dir http.FileSystem) {
+func (group *RouteGroup) StaticAlias(relativePath string, dir http.FileSystem) *RegisteredRoute {
 	if relativePath == "" {
 		relativePath = "/"
 	}
@@ -186,8 +195,77 @@ func (group *RouteGroup) StaticAlias(relativePath string, dir http.FileSystem) {
 		http.StripPrefix(pathJoin(group.basePath, relativePath), http.FileServer(dir)).ServeHTTP(ctx.ResponseWriter, ctx.Request)
 	}
 	relativePath = path.Join(relativePath, "*filepath")
-	group.Head(relativePath, handler)
-	group.Get(relativePath, handler)
+	return group.Head(relativePath, handler).merge(group.Get(relativePath, handler))
+}
+
+// Mount registers the handler h for all the HTTP methods to serve the requests under prefix,
+// the prefix is stripped from the URL.Path (and URL.RawPath) of request before calling h, for example:
+//     v2 := gin.New()
+//     v2.Get("/users", listUsers)
+//     router.Mount("/v2", v2)                      // GET /v2/users is served by v2 as GET /users
+//     router.Mount("/debug", http.DefaultServeMux) // GET /debug/vars is served as GET /vars
+//
+// Both the request for prefix itself and the requests under it are served, h sees the path "/" for the former.
+// The middlewares of group are called before h, and h is responsible for the 404 of the paths under prefix.
+// If h is a *Engine, the routes of it are listed in Engine.Routes() with prefix.
+func (group *RouteGroup) Mount(prefix string, h http.Handler) *RegisteredRoute {
+	if h == nil {
+		panic("mounted handler can not be nil")
+	}
+	if strings.ContainsRune(prefix, ':') || strings.ContainsRune(prefix, '*') {
+		panic("path parameters can not be used when mounting a handler")
+	}
+	absolutePrefix := pathJoin(group.basePath, prefix)
+	if len(absolutePrefix) > 1 {
+		absolutePrefix = strings.TrimSuffix(absolutePrefix, "/")
+	}
+	handler := func(ctx *Context) {
+		h.ServeHTTP(ctx.ResponseWriter, stripPrefix(ctx.Request, absolutePrefix))
+	}
+
+	route := &RegisteredRoute{engine: group.engine}
+	for _, method := range __httpMethods {
+		if absolutePrefix != "/" {
+			route.merge(group.handle(method, strings.TrimSuffix(prefix, "/"), HandlerChain{handler}))
+		}
+		route.merge(group.handle(method, path.Join(prefix, "*mountpath"), HandlerChain{handler}))
+	}
+	if sub, ok := h.(*Engine); ok {
+		host := ""
+		if group.host != nil {
+			host = group.host.pattern
+		}
+		group.engine.mounts = append(group.engine.mounts, mountedEngine{host: host, prefix: absolutePrefix, engine: sub})
+	}
+	return route
+}
+
+// stripPrefix returns a shallow copy of r with prefix removed from the URL.Path and URL.RawPath,
+// the path of the returned request is always started with '/'.
+func stripPrefix(r *http.Request, prefix string) *http.Request {
+	if prefix == "/" {
+		return r
+	}
+	r2 := new(http.Request)
+	*r2 = *r
+	r2.URL = new(url.URL)
+	*r2.URL = *r.URL
+	r2.URL.Path = ensureLeadingSlash(strings.TrimPrefix(r.URL.Path, prefix))
+	if r.URL.RawPath != "" {
+		if rawPath := strings.TrimPrefix(r.URL.RawPath, prefix); rawPath != r.URL.RawPath {
+			r2.URL.RawPath = ensureLeadingSlash(rawPath)
+		} else {
+			r2.URL.RawPath = "" // the prefix is escaped in RawPath
+		}
+	}
+	return r2
+}
+
+func ensureLeadingSlash(p string) string {
+	if p == "" || p[0] != '/' {
+		return "/" + p
+	}
+	return p
 }
 
 func pathJoin(basePath, relativePath string) string {
@@ -209,8 +287,7 @@ func combineHandlerChain(middlewares, handlers HandlerChain) HandlerChain {
 	if len(middlewares) == 0 {
 		return handlers
 	}
-	if size := len(middlewares) + len(handlers); size > __maxHandlerChainSize {
-		panic("too many handlers")
-	}
-	return append(middlewares, handlers...)
+	chain := make(HandlerChain, 0, len(middlewares)+len(handlers))
+	chain = append(chain, middlewares...)
+	return append(chain, handlers...)
 }
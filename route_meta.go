@@ -0,0 +1,167 @@
+// Copyright 2014 Manu Martinez-Almeida.  All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package gin
+
+import "sort"
+
+// The well-known keys of RouteMeta.
+const (
+	MetaDescription    = "description"      // string
+	MetaTags           = "tags"             // []string
+	MetaScopes         = "scopes"           // []string, the auth scopes required by the route
+	MetaDeprecated     = "deprecated"       // bool
+	MetaRateLimitClass = "rate_limit_class" // string
+	MetaRequest        = "request"          // interface{}, a value of the request body type
+	MetaResponses      = "responses"        // map[int]interface{}, http status code --> a value of the response body type
+)
+
+// RouteMeta is the arbitrary metadata attached to a route, see RegisteredRoute.Meta().
+//
+// The metadata can be read back from Engine.Routes() and Context.Route(),
+// so that middlewares can enforce per-route policy, for example:
+//
+//     func requireScopes(ctx *gin.Context) {
+//         if route, ok := ctx.Route(); ok {
+//             for _, scope := range route.Meta.Strings(gin.MetaScopes) {
+//                 ...
+//             }
+//         }
+//         ctx.Next()
+//     }
+//
+// RouteMeta is read-only since it is shared by all the requests of the route, the values returned
+// must not be modified either. The zero value is the empty metadata.
+type RouteMeta struct {
+	m map[string]interface{}
+}
+
+// Get returns the value for the given key, ie: (value, true).
+// If the value does not exists it returns (nil, false)
+func (meta RouteMeta) Get(key string) (value interface{}, exists bool) {
+	value, exists = meta.m[key]
+	return
+}
+
+// String returns the value for the given key if it is a string, otherwise it returns "".
+func (meta RouteMeta) String(key string) string {
+	s, _ := meta.m[key].(string)
+	return s
+}
+
+// Strings returns the value for the given key if it is a []string, otherwise it returns nil.
+func (meta RouteMeta) Strings(key string) []string {
+	ss, _ := meta.m[key].([]string)
+	return ss
+}
+
+// Bool returns the value for the given key if it is a bool, otherwise it returns false.
+func (meta RouteMeta) Bool(key string) bool {
+	b, _ := meta.m[key].(bool)
+	return b
+}
+
+// Keys returns the sorted keys of the metadata.
+func (meta RouteMeta) Keys() []string {
+	keys := make([]string, 0, len(meta.m))
+	for key := range meta.m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Meta attaches the key/value pair to the metadata of the route.
+//
+// The metadata of the routes of Engine can be attached at runtime in hot-swap mode, see Engine.HotSwapRoutes().
+func (route *RegisteredRoute) Meta(key string, value interface{}) *RegisteredRoute {
+	if key == "" {
+		panic("metadata key can not be empty")
+	}
+	swapped := route.updateSwappedRoutes(func(infos []*routeInfo) {
+		for _, info := range infos {
+			if info.meta == nil {
+				info.meta = make(map[string]interface{})
+			}
+			info.meta[key] = value
+		}
+	})
+	if swapped {
+		return route
+	}
+
+	route.engine.startedChecker.check() // check if engine has been started.
+	for _, info := range route.infos {
+		if info.meta == nil {
+			info.meta = make(map[string]interface{})
+		}
+		info.meta[key] = value
+	}
+	return route
+}
+
+// Description is a shortcut for route.Meta(MetaDescription, description).
+func (route *RegisteredRoute) Description(description string) *RegisteredRoute {
+	return route.Meta(MetaDescription, description)
+}
+
+// Tags is a shortcut for route.Meta(MetaTags, tags).
+func (route *RegisteredRoute) Tags(tags ...string) *RegisteredRoute {
+	return route.Meta(MetaTags, tags)
+}
+
+// Scopes is a shortcut for route.Meta(MetaScopes, scopes).
+func (route *RegisteredRoute) Scopes(scopes ...string) *RegisteredRoute {
+	return route.Meta(MetaScopes, scopes)
+}
+
+// Deprecated is a shortcut for route.Meta(MetaDeprecated, true).
+func (route *RegisteredRoute) Deprecated() *RegisteredRoute {
+	return route.Meta(MetaDeprecated, true)
+}
+
+// RateLimitClass is a shortcut for route.Meta(MetaRateLimitClass, class).
+func (route *RegisteredRoute) RateLimitClass(class string) *RegisteredRoute {
+	return route.Meta(MetaRateLimitClass, class)
+}
+
+// Request is a shortcut for route.Meta(MetaRequest, obj),
+// obj is a value (or a pointer to a value) of the request body type, for example:
+//     group.Post("/users", createUser).Request(CreateUserRequest{})
+func (route *RegisteredRoute) Request(obj interface{}) *RegisteredRoute {
+	return route.Meta(MetaRequest, obj)
+}
+
+// Response adds the response body type for http status code to the MetaResponses metadata of the route,
+// obj is a value (or a pointer to a value) of the response body type and it can be nil if the response has no body.
+//     group.Get("/users/:id", getUser).Response(200, User{}).Response(404, nil)
+func (route *RegisteredRoute) Response(code int, obj interface{}) *RegisteredRoute {
+	responses := make(map[int]interface{})
+	if len(route.infos) > 0 {
+		if old, ok := route.infos[0].meta[MetaResponses].(map[int]interface{}); ok {
+			for k, v := range old {
+				responses[k] = v
+			}
+		}
+	}
+	responses[code] = obj
+	return route.Meta(MetaResponses, responses)
+}
+
+// Route returns the route matched by the current request, ie: (route, true).
+// If no route matched, for example in NoRoute and NoMethod handlers, it returns (Route{}, false).
+func (ctx *Context) Route() (route Route, ok bool) {
+	info := ctx.route
+	if info == nil {
+		return Route{}, false
+	}
+	return Route{
+		Method:  info.method,
+		Host:    info.host,
+		Path:    info.path,
+		Handler: info.handler,
+		Name:    info.name,
+		Meta:    RouteMeta{m: info.meta},
+	}, true
+}
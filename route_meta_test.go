@@ -0,0 +1,55 @@
+// Copyright 2014 Manu Martinez-Almeida.  All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package gin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestRouteMeta(t *testing.T) {
+	engine := New()
+	var got Route
+	engine.Get("/users/:id", func(ctx *Context) { got, _ = ctx.Route() }).
+		Description("get user").
+		Tags("users", "admin").
+		Deprecated().
+		Response(200, nil).
+		Response(404, nil).
+		Meta("owner", "team-a")
+	engine.Get("/plain", func(ctx *Context) { got, _ = ctx.Route() })
+
+	engine.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/users/1", nil))
+	meta := got.Meta
+	if meta.String(MetaDescription) != "get user" || !meta.Bool(MetaDeprecated) || meta.String("owner") != "team-a" {
+		t.Errorf("unexpected metadata %v", meta.Keys())
+	}
+	if tags := meta.Strings(MetaTags); !reflect.DeepEqual(tags, []string{"users", "admin"}) {
+		t.Errorf("got tags %v", tags)
+	}
+	if responses, _ := meta.Get(MetaResponses); len(responses.(map[int]interface{})) != 2 {
+		t.Errorf("got responses %v", responses)
+	}
+	want := []string{MetaDeprecated, MetaDescription, "owner", MetaResponses, MetaTags}
+	if keys := meta.Keys(); !reflect.DeepEqual(keys, want) {
+		t.Errorf("got keys %v, want %v", keys, want)
+	}
+	if _, ok := meta.Get(MetaScopes); ok {
+		t.Error("unexpected scopes")
+	}
+
+	for _, route := range engine.Routes() {
+		if route.Path == "/users/:id" && route.Meta.String(MetaDescription) != "get user" {
+			t.Errorf("Engine.Routes() got description %q", route.Meta.String(MetaDescription))
+		}
+	}
+
+	engine.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/plain", nil))
+	if keys := got.Meta.Keys(); len(keys) != 0 || got.Meta.String(MetaDescription) != "" {
+		t.Errorf("got metadata %v for the route without metadata", keys)
+	}
+}
@@ -0,0 +1,204 @@
+// Copyright 2014 Manu Martinez-Almeida.  All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package gin
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// RegisteredRoute is returned by the route registration methods of RouteGroup,
+// it refers to the route(s) just registered, for example:
+//     group.Get("/users/:id", handler).Name("user.show")
+type RegisteredRoute struct {
+	engine *Engine
+	infos  []*routeInfo // Any() and Static*() register more than one route
+}
+
+func (route *RegisteredRoute) merge(other *RegisteredRoute) *RegisteredRoute {
+	route.infos = append(route.infos, other.infos...)
+	return route
+}
+
+// Name names the route, so that the path of the route can be generated by Engine.URL().
+// The name must be unique in the Engine and a route can be named only once.
+//
+// The routes of Engine can be named at runtime in hot-swap mode, see Engine.HotSwapRoutes().
+func (route *RegisteredRoute) Name(name string) *RegisteredRoute {
+	if name == "" {
+		panic("route name can not be empty")
+	}
+	engine := route.engine
+	for _, info := range route.infos {
+		if info.name != "" {
+			panic(`route "` + info.method + " " + info.path + `" has been named "` + info.name + `"`)
+		}
+	}
+	checkName := func() {
+		if info, ok := engine.lookupNamedRoute(name); ok {
+			panic(`route name "` + name + `" has been used by path '` + info.path + `'`)
+		}
+	}
+	swapped := route.updateSwappedRoutes(func(infos []*routeInfo) {
+		checkName()
+		for _, info := range infos {
+			info.name = name
+		}
+	})
+	if swapped {
+		return route
+	}
+
+	engine.startedChecker.check() // check if engine has been started.
+	checkName()
+	for _, info := range route.infos {
+		info.name = name
+	}
+	if len(route.infos) > 0 {
+		if engine.namedRoutes == nil {
+			engine.namedRoutes = make(map[string]*routeInfo)
+		}
+		engine.namedRoutes[name] = route.infos[0]
+	}
+	return route
+}
+
+// URL generates the path of the route named name, the :param and *catchAll segments
+// of the route are replaced in order by params, which are formatted using fmt.Sprint.
+// The value of :param is escaped as a single path segment, the value of *catchAll
+// is escaped segment by segment, and the value must satisfy the constraint of the parameter if any.
+//
+// If the route is registered by Engine.Host(), the param labels of the host pattern are replaced first
+// and a scheme-relative URL is returned, for example:
+//     engine.Host(":tenant.example.com").Get("/users/:id", handler).Name("tenant.user")
+//     url, err := engine.URL("tenant.user", "acme", 10) // url == "//acme.example.com/users/10"
+// The host pattern with the wildcard label '*' can not be generated.
+//
+// An error is returned if there is no route named name (or the route has been removed by Engine.RemoveRoute()
+// or Engine.ReplaceRoutes()), or the number of params does not match the number of the route's parameters.
+func (engine *Engine) URL(name string, params ...interface{}) (string, error) {
+	info, ok := engine.lookupNamedRoute(name)
+	if !ok {
+		return "", errors.New(`gin: no route named "` + name + `"`)
+	}
+	if info.host == "" {
+		return buildURL(info.path, params)
+	}
+	hostName, n, err := engine.lookupHost(info.host).buildHost(params)
+	if err != nil {
+		return "", err
+	}
+	path, err := buildURL(info.path, params[n:])
+	if err != nil {
+		return "", err
+	}
+	return "//" + hostName + path, nil
+}
+
+// buildHost replaces the param labels of the host pattern with params,
+// it returns the host name and the number of used params.
+func (host *hostRoutes) buildHost(params []interface{}) (string, int, error) {
+	buf := make([]byte, 0, len(host.pattern)+32)
+	n := 0 // number of used params
+	for i, label := range host.labels {
+		if i > 0 {
+			buf = append(buf, '.')
+		}
+		switch {
+		case label.value == "*":
+			return "", 0, fmt.Errorf("gin: can not generate the host of pattern '%s' with wildcard label", host.pattern)
+		case label.value[0] == ':':
+			name, _ := splitWildcard(label.value)
+			if n >= len(params) {
+				return "", 0, fmt.Errorf("gin: missing value of parameter %q for host '%s'", name, host.pattern)
+			}
+			value := fmt.Sprint(params[n])
+			n++
+			if !validHostLabel(value) {
+				return "", 0, fmt.Errorf("gin: invalid value %q of parameter %q for host '%s'", value, name, host.pattern)
+			}
+			if label.constraint != nil && !label.constraint.match(value) {
+				return "", 0, fmt.Errorf("gin: value %q of parameter %q does not satisfy the constraint <%s>", value, name, label.constraint.expr)
+			}
+			buf = append(buf, value...)
+		default:
+			buf = append(buf, label.value...)
+		}
+	}
+	return string(buf), n, nil
+}
+
+// validHostLabel reports whether value can be a label of the host name.
+func validHostLabel(value string) bool {
+	if value == "" {
+		return false
+	}
+	for i := 0; i < len(value); i++ {
+		switch c := value[i]; {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9', c == '-', c == '_', c >= 0x80:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// buildURL replaces the :param and *catchAll segments of path with params.
+func buildURL(path string, params []interface{}) (string, error) {
+	buf := make([]byte, 0, len(path)+32)
+	n := 0 // number of used params
+	for i := 0; i < len(path); {
+		c := path[i]
+		if c != ':' && c != '*' {
+			buf = append(buf, c)
+			i++
+			continue
+		}
+
+		// find wildcard end (either '/' or path end)
+		end := i + 1
+		for end < len(path) && path[end] != '/' {
+			end++
+		}
+		name, expr := splitWildcard(path[i:end])
+		if n >= len(params) {
+			return "", fmt.Errorf("gin: missing value of parameter %q for path '%s'", name, path)
+		}
+		value := fmt.Sprint(params[n])
+		n++
+
+		if c == ':' {
+			if value == "" {
+				return "", fmt.Errorf("gin: empty value of parameter %q for path '%s'", name, path)
+			}
+			if expr != "" {
+				match, err := lookupParamConstraint(expr)
+				if err != nil {
+					return "", err
+				}
+				if !match(value) {
+					return "", fmt.Errorf("gin: value %q of parameter %q does not satisfy the constraint <%s>", value, name, expr)
+				}
+			}
+			buf = append(buf, url.PathEscape(value)...)
+		} else {
+			// the catch-all value begins with '/', which has been written before the wildcard.
+			segments := strings.Split(strings.TrimPrefix(value, "/"), "/")
+			for j, segment := range segments {
+				if j > 0 {
+					buf = append(buf, '/')
+				}
+				buf = append(buf, url.PathEscape(segment)...)
+			}
+		}
+		i = end
+	}
+	if n < len(params) {
+		return "", fmt.Errorf("gin: too many parameters for path '%s', want %d but got %d", path, n, len(params))
+	}
+	return string(buf), nil
+}
@@ -0,0 +1,81 @@
+// Copyright 2014 Manu Martinez-Almeida.  All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package gin
+
+import (
+	"testing"
+)
+
+func TestBuildURL(t *testing.T) {
+	tests := []struct {
+		path   string
+		params []interface{}
+		url    string
+		err    bool
+	}{
+		{"/", nil, "/", false},
+		{"/users", nil, "/users", false},
+		{"/users/:id", []interface{}{10}, "/users/10", false},
+		{"/users/:id/posts/:post", []interface{}{"john", 2}, "/users/john/posts/2", false},
+		{"/users/:id", []interface{}{"a/b c"}, "/users/a%2Fb%20c", false},
+		{"/src/*filepath", []interface{}{"/dir/a b.txt"}, "/src/dir/a%20b.txt", false},
+		{"/src/*filepath", []interface{}{"dir/file"}, "/src/dir/file", false},
+		{"/src/*filepath", []interface{}{""}, "/src/", false},
+		{"/users/:id<int>", []interface{}{-12}, "/users/-12", false},
+		{"/posts/:slug<[a-z0-9-]+>", []interface{}{"hello-world"}, "/posts/hello-world", false},
+		{"/users/:id", nil, "", true},
+		{"/users/:id<int>", []interface{}{"abc"}, "", true},
+		{"/posts/:slug<[a-z0-9-]+>", []interface{}{"Hello"}, "", true},
+		{"/users/:id", []interface{}{""}, "", true},
+		{"/users/:id", []interface{}{1, 2}, "", true},
+		{"/users", []interface{}{1}, "", true},
+	}
+	for _, test := range tests {
+		url, err := buildURL(test.path, test.params)
+		if test.err {
+			if err == nil {
+				t.Errorf("buildURL(%q, %v): expected error, got %q", test.path, test.params, url)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("buildURL(%q, %v): unexpected error: %v", test.path, test.params, err)
+			continue
+		}
+		if url != test.url {
+			t.Errorf("buildURL(%q, %v): got %q, want %q", test.path, test.params, url, test.url)
+		}
+	}
+}
+
+func TestEngineURLHost(t *testing.T) {
+	engine := New()
+	handler := func(ctx *Context) {}
+	engine.Get("/users/:id", handler).Name("user")
+	engine.Host("api.example.com").Get("/users/:id", handler).Name("api.user")
+	engine.Host(":tenant<alpha>.example.com").Get("/users/:id", handler).Name("tenant.user")
+	engine.Host("*.example.org").Get("/", handler).Name("any")
+
+	tests := []struct {
+		name   string
+		params []interface{}
+		url    string
+		err    bool
+	}{
+		{"user", []interface{}{10}, "/users/10", false},
+		{"api.user", []interface{}{10}, "//api.example.com/users/10", false},
+		{"tenant.user", []interface{}{"acme", 10}, "//acme.example.com/users/10", false},
+		{"tenant.user", []interface{}{"acme"}, "", true},
+		{"tenant.user", []interface{}{"acme1", 10}, "", true},
+		{"tenant.user", []interface{}{"evil.com/", 10}, "", true},
+		{"any", nil, "", true},
+	}
+	for _, test := range tests {
+		url, err := engine.URL(test.name, test.params...)
+		if (err != nil) != test.err || url != test.url {
+			t.Errorf("URL(%q, %v): got %q, %v, want %q", test.name, test.params, url, err, test.url)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+// Copyright 2014 Manu Martinez-Almeida.  All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package sessions
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/gob"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/chanxuehong/gin"
+)
+
+// The max length of the cookie value, most browsers limit the cookie to 4096 bytes.
+const __maxCookieLength = 4000
+
+var errCookieTooLong = errors.New("sessions: the session is too large to be stored in the cookie")
+
+// cookieSession is the session encoded in the cookie.
+type cookieSession struct {
+	ID      string
+	Values  map[string]interface{}
+	Expires int64 // unix time
+}
+
+// EncodeValues encodes the values of session by encoding/gob, it is used by the stores.
+// The types of the values except the basic types must be registered by gob.Register().
+func EncodeValues(values map[string]interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(values); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// DecodeValues decodes the values encoded by EncodeValues.
+func DecodeValues(data []byte) (map[string]interface{}, error) {
+	var values map[string]interface{}
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&values); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// signedCookieStore stores the sessions in the cookies signed by HMAC-SHA256.
+type signedCookieStore struct {
+	keyring *gin.Keyring
+}
+
+// NewCookieStore returns a Store which stores the sessions in the cookies signed by the keyring,
+// the values are visible to the client but can not be modified. The cookie name is signed with
+// the values, so that the value of the session cookie can not be used as another cookie.
+//
+// The keys are rotated like Context.SetSignedCookie() by Keyring.Rotate(), the keyring can be
+// shared with Engine.CookieKeyring().
+func NewCookieStore(keyring *gin.Keyring) Store {
+	if keyring == nil {
+		panic("sessions: keyring can not be nil")
+	}
+	return &signedCookieStore{keyring: keyring}
+}
+
+func (store *signedCookieStore) Load(name, cookie string) (string, map[string]interface{}, bool) {
+	dot := strings.LastIndexByte(cookie, '.')
+	if dot < 0 {
+		return "", nil, false
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(cookie[dot+1:])
+	if err != nil || !store.keyring.Verify(signedData(name, cookie[:dot]), signature) {
+		return "", nil, false
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(cookie[:dot])
+	if err != nil {
+		return "", nil, false
+	}
+	return decodeCookieSession(payload)
+}
+
+func (store *signedCookieStore) Save(name, id string, values map[string]interface{}, maxAge time.Duration) (string, error) {
+	payload, err := encodeCookieSession(id, values, maxAge)
+	if err != nil {
+		return "", err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	cookie := encoded + "." + base64.RawURLEncoding.EncodeToString(store.keyring.Sign(signedData(name, encoded)))
+	if len(cookie) > __maxCookieLength {
+		return "", errCookieTooLong
+	}
+	return cookie, nil
+}
+
+func (store *signedCookieStore) Delete(string) error { return nil }
+
+// signedData returns the data signed for the session cookie, it is the same as Context.SetSignedCookie().
+func signedData(name, payload string) []byte {
+	return []byte(name + "=" + payload)
+}
+
+// encryptedCookieStore stores the sessions in the cookies encrypted by AES-GCM.
+type encryptedCookieStore struct {
+	aeads []cipher.AEAD
+}
+
+// NewEncryptedCookieStore returns a Store which stores the sessions in the cookies encrypted
+// by AES-GCM, the values are neither visible to the client nor can be modified.
+// Each key must be 16, 24 or 32 bytes to select AES-128, AES-192 or AES-256.
+//
+// The first key encrypts the cookies and all the keys decrypt the cookies, so the keys can be rotated
+// by adding the new key at the front, and removing the old key after the cookies encrypted by it are expired.
+// The cookie name is authenticated with the values like NewCookieStore.
+func NewEncryptedCookieStore(keys ...[]byte) Store {
+	if len(keys) == 0 {
+		panic("sessions: at least one key is required")
+	}
+	store := &encryptedCookieStore{aeads: make([]cipher.AEAD, 0, len(keys))}
+	for _, key := range keys {
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			panic("sessions: invalid AES key: " + err.Error())
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			panic("sessions: " + err.Error())
+		}
+		store.aeads = append(store.aeads, aead)
+	}
+	return store
+}
+
+func (store *encryptedCookieStore) Load(name, cookie string) (string, map[string]interface{}, bool) {
+	data, err := base64.RawURLEncoding.DecodeString(cookie)
+	if err != nil {
+		return "", nil, false
+	}
+	for _, aead := range store.aeads {
+		if len(data) < aead.NonceSize() {
+			continue
+		}
+		nonce, ciphertext := data[:aead.NonceSize()], data[aead.NonceSize():]
+		if payload, err := aead.Open(nil, nonce, ciphertext, []byte(name)); err == nil {
+			return decodeCookieSession(payload)
+		}
+	}
+	return "", nil, false
+}
+
+func (store *encryptedCookieStore) Save(name, id string, values map[string]interface{}, maxAge time.Duration) (string, error) {
+	payload, err := encodeCookieSession(id, values, maxAge)
+	if err != nil {
+		return "", err
+	}
+	aead := store.aeads[0]
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(payload)+aead.Overhead())
+	if _, err = rand.Read(nonce); err != nil {
+		return "", err
+	}
+	cookie := base64.RawURLEncoding.EncodeToString(aead.Seal(nonce, nonce, payload, []byte(name)))
+	if len(cookie) > __maxCookieLength {
+		return "", errCookieTooLong
+	}
+	return cookie, nil
+}
+
+func (store *encryptedCookieStore) Delete(string) error { return nil }
+
+func encodeCookieSession(id string, values map[string]interface{}, maxAge time.Duration) ([]byte, error) {
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(cookieSession{
+		ID:      id,
+		Values:  values,
+		Expires: time.Now().Add(maxAge).Unix(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func decodeCookieSession(payload []byte) (string, map[string]interface{}, bool) {
+	var session cookieSession
+	if err := gob.NewDecoder(bytes.NewReader(payload)).Decode(&session); err != nil {
+		return "", nil, false
+	}
+	if time.Now().Unix() >= session.Expires {
+		return "", nil, false
+	}
+	return session.ID, session.Values, true
+}
@@ -0,0 +1,73 @@
+// Copyright 2014 Manu Martinez-Almeida.  All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package sessions
+
+import (
+	"sync"
+	"time"
+)
+
+// memoryStore is a server-side Store which stores the sessions in memory, the cookie is the session ID.
+type memoryStore struct {
+	mu        sync.Mutex
+	sessions  map[string]memorySession
+	lastSweep time.Time
+}
+
+type memorySession struct {
+	data    []byte // encoded by EncodeValues
+	expires time.Time
+}
+
+// NewMemoryStore returns a server-side Store which stores the sessions in memory,
+// the value of the session cookie is the session ID. The expired sessions are removed periodically.
+//
+// The sessions are lost when the process exits and are not shared by the processes,
+// so it is mostly for the development and the single-instance deployment.
+func NewMemoryStore() Store {
+	return &memoryStore{sessions: make(map[string]memorySession)}
+}
+
+func (store *memoryStore) Load(_, cookie string) (string, map[string]interface{}, bool) {
+	store.mu.Lock()
+	session, ok := store.sessions[cookie]
+	store.mu.Unlock()
+	if !ok || !time.Now().Before(session.expires) {
+		return "", nil, false
+	}
+	values, err := DecodeValues(session.data)
+	if err != nil {
+		return "", nil, false
+	}
+	return cookie, values, true
+}
+
+func (store *memoryStore) Save(_, id string, values map[string]interface{}, maxAge time.Duration) (string, error) {
+	data, err := EncodeValues(values)
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	if now.Sub(store.lastSweep) >= time.Minute {
+		for key, session := range store.sessions {
+			if !now.Before(session.expires) {
+				delete(store.sessions, key)
+			}
+		}
+		store.lastSweep = now
+	}
+	store.sessions[id] = memorySession{data: data, expires: now.Add(maxAge)}
+	return id, nil
+}
+
+func (store *memoryStore) Delete(id string) error {
+	store.mu.Lock()
+	delete(store.sessions, id)
+	store.mu.Unlock()
+	return nil
+}
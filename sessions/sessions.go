@@ -0,0 +1,268 @@
+// Copyright 2014 Manu Martinez-Almeida.  All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+// Package sessions provides the sessions of gin, the session is stored in the cookie by the cookie stores
+// (signed or encrypted), or stored in the server by the server-side stores with the session ID in the cookie.
+//
+//     store := sessions.NewCookieStore(gin.NewKeyring([]byte("new-secret"), []byte("old-secret")))
+//     router.Use(sessions.Sessions(sessions.Config{Store: store}))
+//     router.Post("/login", func(ctx *gin.Context) {
+//         session := sessions.Get(ctx)
+//         session.RegenerateID()
+//         session.Set("user", "bob")
+//         session.AddFlash("welcome back")
+//         ctx.Redirect(303, "/")
+//     })
+//
+// The session is loaded from the request when it is first accessed by Get(), and it is saved
+// just before the response header is written if it has been changed.
+package sessions
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/gob"
+	"net/http"
+	"time"
+
+	"github.com/chanxuehong/gin"
+)
+
+func init() {
+	gob.Register([]interface{}{})
+	gob.Register(map[string]interface{}{})
+}
+
+const (
+	__defaultName   = "session"
+	__defaultMaxAge = 7 * 24 * time.Hour
+	__flashesKey    = "_flashes"
+)
+
+// The key of gin.Context to store the *Session, see Get().
+const sessionKey = "github.com/chanxuehong/gin/sessions.Session"
+
+// Store loads and saves the sessions, it must be safe for concurrent use.
+// The value of the session cookie is returned by Save and passed to Load, name is the name of
+// the session cookie, the cookie stores bind the value to it.
+type Store interface {
+	// Load returns the ID and values of the session of the cookie value,
+	// ok is false if the session does not exist, is expired or is invalid.
+	Load(name, cookie string) (id string, values map[string]interface{}, ok bool)
+
+	// Save saves the session which expires after maxAge, and returns the value of the session cookie.
+	Save(name, id string, values map[string]interface{}, maxAge time.Duration) (cookie string, err error)
+
+	// Delete deletes the session of id, it is a no-op for the cookie stores.
+	Delete(id string) error
+}
+
+// Config is the configuration of Sessions.
+type Config struct {
+	// Store stores the sessions, it is required.
+	Store Store
+
+	// Name is the name of the session cookie. Default is "session".
+	Name string
+
+	// MaxAge is the lifetime of the sessions. Default is 7 days.
+	MaxAge time.Duration
+
+	// The attributes of the session cookie, the cookie is always HttpOnly.
+	// Default path is "/", default SameSite is http.SameSiteLaxMode.
+	Path     string
+	Domain   string
+	Secure   bool
+	SameSite http.SameSite
+
+	// ErrorHandler is called if the session can not be saved, it is called before the response header
+	// is written and it must not write the response. Default ignores the error.
+	ErrorHandler func(ctx *gin.Context, err error)
+}
+
+// Session is the session of a request, it can not be used outside the request's scope.
+type Session struct {
+	id      string
+	oldID   string // the ID before RegenerateID(), it is deleted from the store when saving
+	values  map[string]interface{}
+	isNew   bool
+	changed bool
+	deleted bool
+}
+
+// Sessions returns a middleware that provides the session of request by Get().
+func Sessions(config Config) gin.HandlerFunc {
+	if config.Store == nil {
+		panic("Store of sessions.Config can not be nil")
+	}
+	if config.Name == "" {
+		config.Name = __defaultName
+	}
+	if config.MaxAge <= 0 {
+		config.MaxAge = __defaultMaxAge
+	}
+	if config.Path == "" {
+		config.Path = "/"
+	}
+	if config.SameSite == 0 {
+		config.SameSite = http.SameSiteLaxMode
+	}
+
+	return func(ctx *gin.Context) {
+		var session *Session
+		ctx.Set(sessionKey, func() *Session {
+			if session == nil {
+				session = config.load(ctx.Request)
+			}
+			return session
+		})
+		ctx.BeforeWriteHeader(func() {
+			if session != nil && session.changed {
+				if err := config.save(ctx, session); err != nil && config.ErrorHandler != nil {
+					config.ErrorHandler(ctx, err)
+				}
+			}
+		})
+		ctx.Next()
+	}
+}
+
+// Get returns the session of the request, the session is loaded when it is first accessed.
+// It panics if the Sessions middleware is not used.
+func Get(ctx *gin.Context) *Session {
+	load, ok := ctx.MustGet(sessionKey).(func() *Session)
+	if !ok {
+		panic("sessions: the value of key '" + sessionKey + "' is not a session")
+	}
+	return load()
+}
+
+func (config *Config) load(r *http.Request) *Session {
+	if cookie, err := r.Cookie(config.Name); err == nil && cookie.Value != "" {
+		if id, values, ok := config.Store.Load(config.Name, cookie.Value); ok {
+			if values == nil {
+				values = make(map[string]interface{})
+			}
+			return &Session{id: id, values: values}
+		}
+	}
+	return &Session{id: newSessionID(), values: make(map[string]interface{}), isNew: true}
+}
+
+func (config *Config) save(ctx *gin.Context, session *Session) error {
+	cookie := &http.Cookie{
+		Name:     config.Name,
+		Path:     config.Path,
+		Domain:   config.Domain,
+		Secure:   config.Secure,
+		HttpOnly: true,
+		SameSite: config.SameSite,
+	}
+	if session.oldID != "" {
+		if err := config.Store.Delete(session.oldID); err != nil {
+			return err
+		}
+		session.oldID = ""
+	}
+	if session.deleted {
+		if err := config.Store.Delete(session.id); err != nil {
+			return err
+		}
+		cookie.MaxAge = -1
+		http.SetCookie(ctx.ResponseWriter, cookie)
+		return nil
+	}
+	value, err := config.Store.Save(config.Name, session.id, session.values, config.MaxAge)
+	if err != nil {
+		return err
+	}
+	cookie.Value = value
+	cookie.MaxAge = int(config.MaxAge / time.Second)
+	http.SetCookie(ctx.ResponseWriter, cookie)
+	session.changed = false
+	return nil
+}
+
+// ID returns the ID of the session.
+func (session *Session) ID() string {
+	return session.id
+}
+
+// IsNew reports whether the session is created by the current request.
+func (session *Session) IsNew() bool {
+	return session.isNew
+}
+
+// Get returns the value for the given key, or nil if the value does not exist.
+func (session *Session) Get(key string) interface{} {
+	return session.values[key]
+}
+
+// Set sets the value for the given key.
+func (session *Session) Set(key string, value interface{}) {
+	session.values[key] = value
+	session.changed = true
+	session.deleted = false
+}
+
+// Delete deletes the value for the given key.
+func (session *Session) Delete(key string) {
+	if _, ok := session.values[key]; ok {
+		delete(session.values, key)
+		session.changed = true
+	}
+}
+
+// Clear deletes all the values of the session.
+func (session *Session) Clear() {
+	if len(session.values) > 0 {
+		session.values = make(map[string]interface{})
+		session.changed = true
+	}
+}
+
+// AddFlash adds a flash message, which is removed after it is read by Flashes().
+func (session *Session) AddFlash(value interface{}) {
+	flashes, _ := session.values[__flashesKey].([]interface{})
+	session.values[__flashesKey] = append(flashes, value)
+	session.changed = true
+	session.deleted = false
+}
+
+// Flashes returns and removes the flash messages.
+func (session *Session) Flashes() []interface{} {
+	flashes, ok := session.values[__flashesKey].([]interface{})
+	if ok {
+		delete(session.values, __flashesKey)
+		session.changed = true
+	}
+	return flashes
+}
+
+// RegenerateID changes the ID of the session and keeps the values, the session of the old ID is deleted
+// from the store. It should be called when the privilege changes, e.g. after login, to prevent session fixation.
+func (session *Session) RegenerateID() {
+	if session.oldID == "" && !session.isNew {
+		session.oldID = session.id
+	}
+	session.id = newSessionID()
+	session.changed = true
+}
+
+// Destroy deletes the session from the store and expires the session cookie.
+// If a value is set after Destroy(), a new session is created.
+func (session *Session) Destroy() {
+	session.RegenerateID()
+	session.values = make(map[string]interface{})
+	session.deleted = true
+}
+
+// newSessionID returns a random session ID of 256 bits.
+func newSessionID() string {
+	var b [32]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic("sessions: failed to read random bytes: " + err.Error())
+	}
+	return base64.RawURLEncoding.EncodeToString(b[:])
+}
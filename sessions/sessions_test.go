@@ -0,0 +1,282 @@
+// Copyright 2014 Manu Martinez-Almeida.  All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package sessions
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chanxuehong/gin"
+)
+
+var (
+	__testKey    = []byte("0123456789abcdef0123456789abcdef")
+	__testOldKey = []byte("fedcba9876543210fedcba9876543210")
+)
+
+func newTestCookieStore(keys ...[]byte) Store {
+	return NewCookieStore(gin.NewKeyring(keys...))
+}
+
+func TestCookieStore(t *testing.T) {
+	values := map[string]interface{}{"user": "alice", "n": 1}
+	tests := []struct {
+		name    string
+		store   Store
+		rotated Store // the store which has the new key at the front
+		other   Store // the store without the key
+	}{
+		{"signed", newTestCookieStore(__testOldKey), newTestCookieStore(__testKey, __testOldKey), newTestCookieStore(__testKey)},
+		{"encrypted", NewEncryptedCookieStore(__testOldKey), NewEncryptedCookieStore(__testKey, __testOldKey), NewEncryptedCookieStore(__testKey)},
+	}
+	for _, tt := range tests {
+		cookie, err := tt.store.Save("sid", "id1", values, time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		id, got, ok := tt.store.Load("sid", cookie)
+		if !ok || id != "id1" || got["user"] != "alice" || got["n"] != 1 {
+			t.Errorf("%s: got %q %v %t", tt.name, id, got, ok)
+		}
+		if tt.name == "encrypted" && strings.Contains(cookie, "alice") {
+			t.Errorf("%s: the values are visible in the cookie", tt.name)
+		}
+
+		// the cookie of the old key is still valid after the rotation
+		if id, _, ok := tt.rotated.Load("sid", cookie); !ok || id != "id1" {
+			t.Errorf("%s: the cookie of the old key got %q %t after the rotation", tt.name, id, ok)
+		}
+		if _, _, ok := tt.other.Load("sid", cookie); ok {
+			t.Errorf("%s: the cookie is loaded without the key", tt.name)
+		}
+
+		for _, i := range []int{0, len(cookie) / 2, len(cookie) - 1} {
+			// flips the highest bit of the base64 digit, the lowest bits of the last digit may be ignored
+			const alphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789-_"
+			tampered := []byte(cookie)
+			tampered[i] = alphabet[strings.IndexByte(alphabet, tampered[i])^32]
+			if _, _, ok := tt.store.Load("sid", string(tampered)); ok {
+				t.Errorf("%s: the cookie tampered at %d is loaded", tt.name, i)
+			}
+		}
+		if _, _, ok := tt.store.Load("sid", ""); ok {
+			t.Errorf("%s: the empty cookie is loaded", tt.name)
+		}
+		// the cookie is bound to the cookie name
+		if _, _, ok := tt.store.Load("other", cookie); ok {
+			t.Errorf("%s: the cookie is loaded as another cookie", tt.name)
+		}
+	}
+}
+
+func TestCookieStoreSignedPayload(t *testing.T) {
+	store := newTestCookieStore(__testKey)
+	cookie, _ := store.Save("sid", "id1", map[string]interface{}{"role": "user"}, time.Hour)
+	other, _ := store.Save("sid", "id1", map[string]interface{}{"role": "admin"}, time.Hour)
+
+	// the payload of other with the signature of cookie
+	tampered := other[:strings.LastIndexByte(other, '.')] + cookie[strings.LastIndexByte(cookie, '.'):]
+	if _, _, ok := store.Load("sid", tampered); ok {
+		t.Error("the cookie with the swapped payload is loaded")
+	}
+}
+
+func TestCookieStoreRotate(t *testing.T) {
+	keyring := gin.NewKeyring(__testOldKey)
+	store := NewCookieStore(keyring)
+	cookie, _ := store.Save("sid", "id1", nil, time.Hour)
+
+	// the keyring is rotated while the store is in use
+	keyring.Rotate(__testKey, 1)
+	if id, _, ok := store.Load("sid", cookie); !ok || id != "id1" {
+		t.Errorf("the cookie of the old key got %q %t after the rotation", id, ok)
+	}
+	cookie, _ = store.Save("sid", "id2", nil, time.Hour)
+	if id, _, ok := newTestCookieStore(__testKey).Load("sid", cookie); !ok || id != "id2" {
+		t.Errorf("got %q %t, want the cookie signed by the new key", id, ok)
+	}
+	keyring.Rotate(__testKey, 0)
+	if _, _, ok := store.Load("sid", cookie); !ok {
+		t.Error("the cookie of the current key is not loaded")
+	}
+}
+
+func TestStoreExpiry(t *testing.T) {
+	for name, store := range map[string]Store{
+		"signed":    newTestCookieStore(__testKey),
+		"encrypted": NewEncryptedCookieStore(__testKey),
+		"memory":    NewMemoryStore(),
+	} {
+		cookie, err := store.Save("sid", "id1", map[string]interface{}{"user": "alice"}, -time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, _, ok := store.Load("sid", cookie); ok {
+			t.Errorf("%s: the expired session is loaded", name)
+		}
+	}
+}
+
+func TestCookieStoreTooLong(t *testing.T) {
+	store := newTestCookieStore(__testKey)
+	if _, err := store.Save("sid", "id1", map[string]interface{}{"big": strings.Repeat("x", __maxCookieLength)}, time.Hour); err != errCookieTooLong {
+		t.Errorf("got error %v, want %v", err, errCookieTooLong)
+	}
+}
+
+func TestNewCookieStoreInvalid(t *testing.T) {
+	for name, fn := range map[string]func(){
+		"no keyring":      func() { NewCookieStore(nil) },
+		"no AES key":      func() { NewEncryptedCookieStore() },
+		"invalid AES key": func() { NewEncryptedCookieStore([]byte("short")) },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s: did not panic", name)
+				}
+			}()
+			fn()
+		}()
+	}
+}
+
+func newTestEngine(store Store) *gin.Engine {
+	engine := gin.New()
+	engine.Use(Sessions(Config{Store: store, Name: "sid", MaxAge: time.Hour}))
+	engine.Post("/login", func(ctx *gin.Context) {
+		session := Get(ctx)
+		session.RegenerateID()
+		session.Set("user", ctx.Query("user"))
+		session.AddFlash("welcome")
+		// writes nothing, the cookie is set before the header is written by the engine
+	})
+	engine.Get("/me", func(ctx *gin.Context) {
+		session := Get(ctx)
+		ctx.String(200, "%v %v", session.Get("user"), session.Flashes())
+	})
+	engine.Get("/peek", func(ctx *gin.Context) {
+		ctx.String(200, "%v", Get(ctx).Get("user"))
+	})
+	engine.Post("/logout", func(ctx *gin.Context) {
+		Get(ctx).Destroy()
+		ctx.String(200, "bye")
+	})
+	return engine
+}
+
+// serve serves the request with the session cookie, and returns the response and the Set-Cookie of "sid".
+func serve(engine *gin.Engine, method, path, session string) (*httptest.ResponseRecorder, *http.Cookie) {
+	r := httptest.NewRequest(method, path, nil)
+	if session != "" {
+		r.AddCookie(&http.Cookie{Name: "sid", Value: session})
+	}
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, r)
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.Name == "sid" {
+			return w, cookie
+		}
+	}
+	return w, nil
+}
+
+func TestSessions(t *testing.T) {
+	for name, store := range map[string]Store{
+		"signed":    newTestCookieStore(__testKey),
+		"encrypted": NewEncryptedCookieStore(__testKey),
+		"memory":    NewMemoryStore(),
+	} {
+		engine := newTestEngine(store)
+
+		// the Set-Cookie is emitted by BeforeWriteHeader although the handler writes nothing
+		w, cookie := serve(engine, "POST", "/login?user=alice", "")
+		if w.Code != 200 || cookie == nil {
+			t.Fatalf("%s: login got %d and cookie %v", name, w.Code, cookie)
+		}
+		if !cookie.HttpOnly || cookie.Path != "/" || cookie.MaxAge != 3600 || cookie.SameSite != http.SameSiteLaxMode {
+			t.Errorf("%s: got cookie %+v", name, cookie)
+		}
+		session := cookie.Value
+
+		// the flashes are consumed once
+		w, cookie = serve(engine, "GET", "/me", session)
+		if w.Body.String() != "alice [welcome]" || cookie == nil {
+			t.Fatalf("%s: got %q and cookie %v", name, w.Body.String(), cookie)
+		}
+		session = cookie.Value
+		if w, _ := serve(engine, "GET", "/me", session); w.Body.String() != "alice []" {
+			t.Errorf("%s: got %q after the flashes are read", name, w.Body.String())
+		}
+
+		// the unchanged session is not saved
+		if _, cookie := serve(engine, "GET", "/peek", session); cookie != nil {
+			t.Errorf("%s: got cookie %v for the unchanged session", name, cookie)
+		}
+
+		// the tampered or unknown session is replaced by a new session
+		if w, _ := serve(engine, "GET", "/peek", session+"x"); w.Body.String() != "<nil>" {
+			t.Errorf("%s: got %q for the tampered session", name, w.Body.String())
+		}
+
+		// the session is deleted on logout
+		w, cookie = serve(engine, "POST", "/logout", session)
+		if cookie == nil || cookie.MaxAge >= 0 {
+			t.Errorf("%s: logout got cookie %v, want expired", name, cookie)
+		}
+		if name == "memory" {
+			if w, _ := serve(engine, "GET", "/peek", session); w.Body.String() != "<nil>" {
+				t.Errorf("%s: got %q after logout", name, w.Body.String())
+			}
+		}
+	}
+}
+
+func TestSessionRegenerateID(t *testing.T) {
+	store := NewMemoryStore()
+	engine := newTestEngine(store)
+
+	_, cookie := serve(engine, "POST", "/login?user=alice", "")
+	oldID := cookie.Value
+	if id, _, ok := store.Load("sid", oldID); !ok || id != oldID {
+		t.Fatalf("the session %q is not stored", oldID)
+	}
+
+	// login again in the same session
+	_, cookie = serve(engine, "POST", "/login?user=bob", oldID)
+	if cookie == nil || cookie.Value == oldID {
+		t.Fatalf("got cookie %v, want a new session ID", cookie)
+	}
+	if _, _, ok := store.Load("sid", oldID); ok {
+		t.Error("the old session ID is still valid")
+	}
+	if w, _ := serve(engine, "GET", "/peek", oldID); w.Body.String() != "<nil>" {
+		t.Errorf("got %q by the old session ID", w.Body.String())
+	}
+	if w, _ := serve(engine, "GET", "/peek", cookie.Value); w.Body.String() != "bob" {
+		t.Errorf("got %q by the new session ID", w.Body.String())
+	}
+}
+
+func TestSessionsErrorHandler(t *testing.T) {
+	var got error
+	engine := gin.New()
+	engine.Use(Sessions(Config{
+		Store:        newTestCookieStore(__testKey),
+		ErrorHandler: func(_ *gin.Context, err error) { got = err },
+	}))
+	engine.Get("/", func(ctx *gin.Context) {
+		Get(ctx).Set("big", strings.Repeat("x", __maxCookieLength))
+		ctx.String(200, "ok")
+	})
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if got != errCookieTooLong || w.Header().Get("Set-Cookie") != "" {
+		t.Errorf("got error %v and Set-Cookie %q", got, w.Header().Get("Set-Cookie"))
+	}
+}
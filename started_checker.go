@@ -26,3 +26,7 @@ func (v startedChecker) check() {
 		panic("the service has been started.")
 	}
 }
+
+func (v startedChecker) started() bool {
+	return uintptr(v) != __startedCheckerInitialValue
+}
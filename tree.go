@@ -24,6 +24,10 @@ func countParams(path string) uint8 {
 			continue
 		}
 		n++
+		// skip the rest of the wildcard, the constraint may contain ':' and '*', e.g. ':time<\d+:\d+>'
+		for i+1 < len(path) && path[i+1] != '/' {
+			i++
+		}
 	}
 	if n >= 255 {
 		return 255
@@ -40,30 +44,50 @@ const (
 	catchAll
 )
 
+// The children of a node are ordered by matching priority:
+//     1. the static children, indexed by indices and ordered by priority
+//     2. the param children with constraint, in the registration order
+//     3. the param child without constraint
+//     4. the catch-all child
+// Static segments win over params, and params win over catch-all, if a child
+// does not lead to a handle the next one is tried (backtracking).
+//
+// The path of a param node is the wildcard, e.g. ':id' or ':id<int>',
+// the path of a catch-all node is the wildcard with the leading '/', e.g. '/*filepath'.
 type node struct {
 	path      string
-	wildChild bool
+	wildChild bool // has param or catch-all children
 	nType     nodeType
 	maxParams uint8
-	indices   string
+	indices   string // the first bytes of the static children
 	children  []*node
 	handlers  HandlerChain
 	priority  uint32
+	route     *routeInfo // registration details of the route stored in this node
+
+	constraint *paramConstraint // constraint of the param node, nil if none
+}
+
+// staticChildren returns the static children of n.
+func (n *node) staticChildren() []*node {
+	return n.children[:len(n.indices)]
+}
+
+// wildChildren returns the param and catch-all children of n.
+func (n *node) wildChildren() []*node {
+	return n.children[len(n.indices):]
 }
 
-// increments priority of the given child and reorders if necessary
-func (n *node) incrementChildPrio(pos int) int {
-	n.children[pos].priority++
+// reorderChild moves the static child at pos to front according to its priority,
+// it returns the new position of the child.
+func (n *node) reorderChild(pos int) int {
 	prio := n.children[pos].priority
 
 	// adjust position (move to front)
 	newPos := pos
 	for newPos > 0 && n.children[newPos-1].priority < prio {
 		// swap node positions
-		tmpN := n.children[newPos-1]
-		n.children[newPos-1] = n.children[newPos]
-		n.children[newPos] = tmpN
-
+		n.children[newPos-1], n.children[newPos] = n.children[newPos], n.children[newPos-1]
 		newPos--
 	}
 
@@ -77,242 +101,416 @@ func (n *node) incrementChildPrio(pos int) int {
 	return newPos
 }
 
-// addRoute adds a node with the given handle to the path.
+// addRoute adds a node with the given handle to the path,
+// it returns the node which holds the handle.
 // Not concurrency-safe!
-func (n *node) addRoute(path string, handlers HandlerChain) {
+func (n *node) addRoute(path string, handlers HandlerChain) *node {
 	fullPath := path
-	n.priority++
 	numParams := countParams(path)
 
-	// non-empty tree
-	if len(n.path) > 0 || len(n.children) > 0 {
-	walk:
-		for {
-			// Update maxParams of the current node
-			if numParams > n.maxParams {
-				n.maxParams = numParams
-			}
+	// Empty tree
+	if len(n.path) == 0 && len(n.children) == 0 {
+		n.nType = root
+	}
 
-			// Find the longest common prefix.
-			// This also implies that the common prefix contains no ':' or '*'
-			// since the existing key can't contain those chars.
-			i := 0
-			max := min(len(path), len(n.path))
-			for i < max && path[i] == n.path[i] {
-				i++
+	// static prefix of the path
+	end := nextWildcard(path, fullPath)
+	n = n.insertStatic(path[:end], numParams)
+	path = path[end:]
+
+	for len(path) > 0 {
+		// wildcard
+		end = wildcardEnd(path, fullPath)
+		n = n.insertWildcard(path[:end], fullPath, fullPath[:len(fullPath)-len(path)], numParams)
+		numParams--
+		path = path[end:]
+
+		// static segment after the wildcard
+		if end = nextWildcard(path, fullPath); end > 0 {
+			n = n.insertStaticChild(path[:end], numParams)
+			path = path[end:]
+		}
+	}
+
+	if n.handlers != nil {
+		panic(&RouteConflictError{
+			Path:         fullPath,
+			ExistingPath: fullPath,
+			Reason:       "handlers are already registered for path '" + fullPath + "'",
+		})
+	}
+	n.handlers = handlers
+	return n
+}
+
+// addRouteMethod is like addRoute, but it sets the method of the RouteConflictError panicked,
+// and the tree is repaired before panicking.
+func (n *node) addRouteMethod(method, path string, handlers HandlerChain) *node {
+	defer func() {
+		if r := recover(); r != nil {
+			n.repair()
+			if err, ok := r.(*RouteConflictError); ok {
+				err.Method = method
 			}
+			panic(r)
+		}
+	}()
+	return n.addRoute(path, handlers)
+}
 
-			// Split edge
-			if i < len(n.path) {
-				child := node{
-					path:      n.path[i:],
-					wildChild: n.wildChild,
-					nType:     static,
-					indices:   n.indices,
-					children:  n.children,
-					handlers:  n.handlers,
-					priority:  n.priority - 1,
-				}
-
-				// Update maxParams (max of all children)
-				for i := range child.children {
-					if child.children[i].maxParams > child.maxParams {
-						child.maxParams = child.children[i].maxParams
-					}
-				}
-
-				n.children = []*node{&child}
-				// []byte for proper unicode char conversion, see #65
-				n.indices = string([]byte{n.path[i]})
-				n.path = path[:i]
-				n.handlers = nil
-				n.wildChild = false
+// nextWildcard returns the start index of the next wildcard in path,
+// for catch-all it is the index of the '/' before '*'.
+// If there is no wildcard, len(path) is returned.
+func nextWildcard(path, fullPath string) int {
+	for i := 0; i < len(path); i++ {
+		switch path[i] {
+		case ':':
+			return i
+		case '*':
+			if i == 0 || path[i-1] != '/' {
+				panic("no / before catch-all in path '" + fullPath + "'")
 			}
+			return i - 1
+		}
+	}
+	return len(path)
+}
+
+// wildcardEnd returns the end index of the wildcard at the beginning of path,
+// and validates the wildcard.
+func wildcardEnd(path, fullPath string) int {
+	start := 1 // ':'
+	if path[0] == '/' {
+		start = 2 // "/*"
+	}
 
-			// Make new node a child of this node
-			if i < len(path) {
-				path = path[i:]
-
-				if n.wildChild {
-					n = n.children[0]
-					n.priority++
-
-					// Update maxParams of the child node
-					if numParams > n.maxParams {
-						n.maxParams = numParams
-					}
-					numParams--
-
-					// Check if the wildcard matches
-					if len(path) >= len(n.path) && n.path == path[:len(n.path)] {
-						// check for longer wildcard, e.g. :name and :names
-						if len(n.path) >= len(path) || path[len(n.path)] == '/' {
-							continue walk
-						}
-					}
-
-					panic("path segment '" + path +
-						"' conflicts with existing wildcard '" + n.path +
-						"' in path '" + fullPath + "'")
-				}
-
-				c := path[0]
-
-				// slash after param
-				if n.nType == param && c == '/' && len(n.children) == 1 {
-					n = n.children[0]
-					n.priority++
-					continue walk
-				}
-
-				// Check if a child with the next path byte exists
-				for i := 0; i < len(n.indices); i++ {
-					if c == n.indices[i] {
-						i = n.incrementChildPrio(i)
-						n = n.children[i]
-						continue walk
-					}
-				}
-
-				// Otherwise insert it
-				if c != ':' && c != '*' {
-					// []byte for proper unicode char conversion, see #65
-					n.indices += string([]byte{c})
-					child := &node{
-						maxParams: numParams,
-					}
-					n.children = append(n.children, child)
-					n.incrementChildPrio(len(n.indices) - 1)
-					n = child
-				}
-				n.insertChild(numParams, path, fullPath, handlers)
-				return
-
-			} else if i == len(path) { // Make node a (in-path) leaf
-				if n.handlers != nil {
-					panic("handlers are already registered for path '" + fullPath + "'")
-				}
-				n.handlers = handlers
+	// find wildcard end (either '/' or path end)
+	end := start
+wildcard:
+	for end < len(path) && path[end] != '/' {
+		switch path[end] {
+		// the wildcard name must not contain ':' and '*'
+		case ':', '*':
+			panic("only one wildcard per path segment is allowed, has: '" +
+				path[start-1:] + "' in path '" + fullPath + "'")
+		// the constraint lasts until the end of path segment
+		case '<':
+			for end < len(path) && path[end] != '/' {
+				end++
 			}
-			return
+			break wildcard
+		default:
+			end++
 		}
-	} else { // Empty tree
-		n.insertChild(numParams, path, fullPath, handlers)
-		n.nType = root
 	}
+
+	// check if the wildcard has a name
+	if end == start {
+		panic("wildcards must be named with a non-empty name in path '" + fullPath + "'")
+	}
+	if start == 2 && end != len(path) {
+		panic("catch-all routes are only allowed at the end of the path in path '" + fullPath + "'")
+	}
+	return end
 }
 
-func (n *node) insertChild(numParams uint8, path, fullPath string, handlers HandlerChain) {
-	var offset int // already handled bytes of the path
+// insertStatic inserts the static path s into the static node n, which has been
+// visited by the route. It returns the node which ends exactly at the end of s.
+func (n *node) insertStatic(s string, numParams uint8) *node {
+	// empty tree
+	if len(n.path) == 0 && len(n.children) == 0 && n.handlers == nil {
+		n.path = s
+	}
 
-	// find prefix until first wildcard (beginning with ':'' or '*'')
-	for i, max := 0, len(path); numParams > 0; i++ {
-		c := path[i]
-		if c != ':' && c != '*' {
-			continue
+	// Find the longest common prefix.
+	i := 0
+	max := min(len(s), len(n.path))
+	for i < max && s[i] == n.path[i] {
+		i++
+	}
+
+	// Split edge
+	if i < len(n.path) {
+		child := node{
+			path:      n.path[i:],
+			wildChild: n.wildChild,
+			nType:     static,
+			maxParams: n.maxParams,
+			indices:   n.indices,
+			children:  n.children,
+			handlers:  n.handlers,
+			priority:  n.priority,
+			route:     n.route,
 		}
 
-		// find wildcard end (either '/' or path end)
-		end := i + 1
-		for end < max && path[end] != '/' {
-			switch path[end] {
-			// the wildcard name must not contain ':' and '*'
-			case ':', '*':
-				panic("only one wildcard per path segment is allowed, has: '" +
-					path[i:] + "' in path '" + fullPath + "'")
-			default:
-				end++
+		n.children = []*node{&child}
+		// []byte for proper unicode char conversion, see #65
+		n.indices = string([]byte{n.path[i]})
+		n.path = n.path[:i]
+		n.handlers = nil
+		n.route = nil
+		n.wildChild = false
+	}
+
+	n.priority++
+	if numParams > n.maxParams {
+		n.maxParams = numParams
+	}
+
+	if i == len(s) {
+		return n
+	}
+	return n.insertStaticChild(s[i:], numParams)
+}
+
+// insertStaticChild inserts the static path s into the children of n,
+// it returns the node which ends exactly at the end of s.
+func (n *node) insertStaticChild(s string, numParams uint8) *node {
+	// Check if a child with the next path byte exists
+	c := s[0]
+	for i := 0; i < len(n.indices); i++ {
+		if c == n.indices[i] {
+			leaf := n.children[i].insertStatic(s, numParams)
+			n.reorderChild(i)
+			return leaf
+		}
+	}
+
+	// Otherwise insert it before the wildcard children
+	child := &node{
+		path:      s,
+		maxParams: numParams,
+		priority:  1,
+	}
+	pos := len(n.indices)
+	n.children = append(n.children, nil)
+	copy(n.children[pos+1:], n.children[pos:])
+	n.children[pos] = child
+	// []byte for proper unicode char conversion, see #65
+	n.indices += string([]byte{c})
+	n.reorderChild(pos)
+	return child
+}
+
+// insertWildcard inserts the wildcard into the children of n, it returns the wildcard node.
+// The prefix is the part of fullPath before the wildcard, which leads to n.
+func (n *node) insertWildcard(wildcard, fullPath, prefix string, numParams uint8) *node {
+	nType := param
+	if wildcard[0] == '/' {
+		nType = catchAll
+	}
+	constraint := newParamConstraint(wildcard[nType-param:]) // skip the '/' of catch-all
+	if constraint != nil && nType == catchAll {
+		panic("catch-all routes can not have constraint in path '" + fullPath + "'")
+	}
+
+	for _, child := range n.wildChildren() {
+		if child.path == wildcard {
+			child.priority++
+			if numParams > child.maxParams {
+				child.maxParams = numParams
 			}
+			return child
 		}
+	}
 
-		// check if this Node existing children which would be
-		// unreachable if we insert the wildcard here
-		if len(n.children) > 0 {
-			panic("wildcard route '" + path[i:end] +
-				"' conflicts with existing children in path '" + fullPath + "'")
+	// the wildcards which match the same values must have the same name
+	for _, child := range n.wildChildren() {
+		if child.nType != nType {
+			continue
 		}
+		if nType == catchAll || (child.constraint == nil) == (constraint == nil) &&
+			(constraint == nil || child.constraint.expr == constraint.expr) {
+			panic(&RouteConflictError{
+				Path:         fullPath,
+				ExistingPath: prefix + child.firstRoutePath(),
+				Reason: "wildcard '" + wildcard + "' conflicts with existing wildcard '" + child.path +
+					"' in path '" + fullPath + "'",
+			})
+		}
+	}
+
+	child := &node{
+		path:       wildcard,
+		nType:      nType,
+		maxParams:  numParams,
+		priority:   1,
+		constraint: constraint,
+	}
 
-		// check if the wildcard has a name
-		if end-i < 2 {
-			panic("wildcards must be named with a non-empty name in path '" + fullPath + "'")
+	// keep the wildcard children ordered: params with constraint, param without constraint, catch-all
+	pos := len(n.children)
+	for i := len(n.indices); i < len(n.children); i++ {
+		if other := n.children[i]; other.nType == catchAll || (constraint != nil && other.constraint == nil) {
+			pos = i
+			break
 		}
+	}
+	n.children = append(n.children, nil)
+	copy(n.children[pos+1:], n.children[pos:])
+	n.children[pos] = child
+	n.wildChild = true
+	return child
+}
 
-		if c == ':' { // param
-			// split path at the beginning of the wildcard
-			if i > 0 {
-				n.path = path[offset:i]
-				offset = i
-			}
+// firstRoutePath returns the path from n to the first node holding a handle in the subtree n.
+func (n *node) firstRoutePath() string {
+	path := n.path
+	for n.handlers == nil && len(n.children) > 0 {
+		n = n.children[0]
+		path += n.path
+	}
+	return path
+}
 
-			child := &node{
-				nType:     param,
-				maxParams: numParams,
-			}
-			n.children = []*node{child}
-			n.wildChild = true
-			n = child
-			n.priority++
-			numParams--
-
-			// if the path doesn't end with the wildcard, then there
-			// will be another non-wildcard subpath starting with '/'
-			if end < max {
-				n.path = path[offset:end]
-				offset = end
-
-				child := &node{
-					maxParams: numParams,
-					priority:  1,
-				}
-				n.children = []*node{child}
-				n = child
-			}
+// removeRoute removes the handle registered with the path, which must be the same as
+// it was registered, e.g. '/users/:id<int>'. It reports whether the handle was found.
+// The nodes left without handle are removed or merged with their child, and the
+// priority, indices and maxParams of the nodes along the path are recomputed.
+// Not concurrency-safe!
+func (n *node) removeRoute(path string) bool {
+	if !n.removePath(path) {
+		return false
+	}
+	n.fixRoot()
+	return true
+}
 
-		} else { // catchAll
-			if end != max || numParams > 1 {
-				panic("catch-all routes are only allowed at the end of the path in path '" + fullPath + "'")
-			}
+// clone returns a deep copy of the tree n, the handlers and the routes are shared.
+func (n *node) clone() *node {
+	c := *n
+	if n.children != nil {
+		c.children = make([]*node, len(n.children))
+		for i, child := range n.children {
+			c.children[i] = child.clone()
+		}
+	}
+	return &c
+}
 
-			if len(n.path) > 0 && n.path[len(n.path)-1] == '/' {
-				panic("catch-all conflicts with existing handle for the path segment root in path '" + fullPath + "'")
-			}
+// repair fixes the tree after addRoute panicked in the middle of inserting,
+// the nodes inserted for the failed route are removed.
+// Not concurrency-safe!
+func (n *node) repair() {
+	n.repairRec()
+	n.fixRoot()
+}
 
-			// currently fixed width 1 for '/'
-			i--
-			if path[i] != '/' {
-				panic("no / before catch-all in path '" + fullPath + "'")
-			}
+func (n *node) repairRec() {
+	for _, child := range n.children {
+		child.repairRec()
+	}
+	n.fixChildren()
+}
 
-			n.path = path[offset:i]
+// removePath removes the handle registered with the path from the subtree n,
+// the path must begin with n.path.
+func (n *node) removePath(path string) bool {
+	if len(path) < len(n.path) || path[:len(n.path)] != n.path {
+		return false
+	}
+	if n.nType == param && len(path) > len(n.path) && path[len(n.path)] != '/' {
+		return false // the wildcard in path is longer than n.path
+	}
+	path = path[len(n.path):]
 
-			// first node: catchAll node with empty path
-			child := &node{
-				wildChild: true,
-				nType:     catchAll,
-				maxParams: 1,
-			}
-			n.children = []*node{child}
-			n.indices = string(path[i])
-			n = child
-			n.priority++
-
-			// second node: node holding the variable
-			child = &node{
-				path:      path[i:],
-				nType:     catchAll,
-				maxParams: 1,
-				handlers:  handlers,
-				priority:  1,
-			}
-			n.children = []*node{child}
+	if len(path) == 0 {
+		if n.handlers == nil {
+			return false
+		}
+		n.handlers = nil
+		n.route = nil
+	} else if !n.removeChildPath(path) {
+		return false
+	}
+	n.fixChildren()
+	return true
+}
 
-			return
+// fixChildren removes the children without handle, merges the static children with their
+// only static child, and recomputes the priority, indices and maxParams of n.
+func (n *node) fixChildren() {
+	children := n.children[:0]
+	indices := make([]byte, 0, len(n.indices))
+	for i, child := range n.children {
+		if child.handlers == nil && len(child.children) == 0 {
+			continue
 		}
+		if child.canMerge() {
+			child.mergeChild()
+		}
+		children = append(children, child)
+		if i < len(n.indices) {
+			indices = append(indices, n.indices[i])
+		}
+	}
+	for i := len(children); i < len(n.children); i++ {
+		n.children[i] = nil
 	}
+	n.children = children
+	n.indices = string(indices)
+	n.wildChild = len(n.children) > len(n.indices)
 
-	// insert remaining path part and handle to the leaf
-	n.path = path[offset:]
-	n.handlers = handlers
+	n.priority = 0
+	if n.handlers != nil {
+		n.priority++
+	}
+	n.maxParams = 0
+	for _, child := range n.children {
+		n.priority += child.priority
+		if child.maxParams > n.maxParams {
+			n.maxParams = child.maxParams
+		}
+	}
+	if n.nType == param || n.nType == catchAll {
+		n.maxParams++
+	}
+	for i := 1; i < len(n.indices); i++ {
+		n.reorderChild(i)
+	}
+}
+
+// fixRoot resets the root n to the empty tree if it has no handle, or merges it with its only static child.
+func (n *node) fixRoot() {
+	if n.handlers == nil && len(n.children) == 0 {
+		*n = node{}
+		return
+	}
+	if n.canMerge() {
+		n.mergeChild()
+	}
+}
+
+func (n *node) removeChildPath(path string) bool {
+	if path[0] == ':' || strings.HasPrefix(path, "/*") {
+		for _, child := range n.wildChildren() {
+			if child.removePath(path) {
+				return true
+			}
+		}
+		return false
+	}
+	if i := strings.IndexByte(n.indices, path[0]); i >= 0 {
+		return n.children[i].removePath(path)
+	}
+	return false
+}
+
+// canMerge reports whether the static node n can be merged with its only child.
+func (n *node) canMerge() bool {
+	return n.nType != param && n.nType != catchAll && n.handlers == nil &&
+		len(n.children) == 1 && len(n.indices) == 1
+}
+
+// mergeChild merges the only static child into n.
+func (n *node) mergeChild() {
+	child := n.children[0]
+	n.path += child.path
+	n.wildChild = child.wildChild
+	n.indices = child.indices
+	n.children = child.children
+	n.handlers = child.handlers
+	n.route = child.route
 }
 
 // Returns the handle registered with the given path (key). The values of
@@ -321,124 +519,119 @@ func (n *node) insertChild(numParams uint8, path, fullPath string, handlers Hand
 // made if a handle exists with an extra (without the) trailing slash for the
 // given path.
 func (n *node) getValue(path string, psBuf Params) (handlers HandlerChain, ps Params, tsr bool) {
+	leaf, ps, tsr := n.getNode(path, psBuf)
+	if leaf != nil {
+		handlers = leaf.handlers
+	}
+	return
+}
+
+// getNode is like getValue but it returns the node holding the handle.
+func (n *node) getNode(path string, psBuf Params) (leaf *node, ps Params, tsr bool) {
 	ps = psBuf[:0]
-walk: // outer loop for walking the tree
-	for {
-		if len(path) > len(n.path) {
-			if path[:len(n.path)] == n.path {
-				path = path[len(n.path):]
-				// If this node does not have a wildcard (param or catchAll)
-				// child,  we can just look up the next child node and continue
-				// to walk down the tree
-				if !n.wildChild {
-					c := path[0]
-					for i := 0; i < len(n.indices); i++ {
-						if c == n.indices[i] {
-							n = n.children[i]
-							continue walk
-						}
-					}
-
-					// Nothing found.
-					// We can recommend to redirect to the same URL without a
-					// trailing slash if a leaf exists for that path.
-					tsr = (path == "/" && n.handlers != nil)
-					return
-				}
-
-				// handle wildcard child
-				n = n.children[0]
-				switch n.nType {
-				case param:
-					// find param end (either '/' or path end)
-					end := 0
-					for end < len(path) && path[end] != '/' {
-						end++
-					}
-
-					// save param value
-					if cap(ps) < int(n.maxParams) {
-						ps = make(Params, 0, n.maxParams)
-					}
-					i := len(ps)
-					ps = ps[:i+1] // expand slice within preallocated capacity
-					ps[i].Key = n.path[1:]
-					ps[i].Value = path[:end]
-
-					// we need to go deeper!
-					if end < len(path) {
-						if len(n.children) > 0 {
-							path = path[end:]
-							n = n.children[0]
-							continue walk
-						}
-
-						// ... but we can't
-						tsr = (len(path) == end+1)
-						return
-					}
-
-					if handlers = n.handlers; handlers != nil {
-						return
-					} else if len(n.children) == 1 {
-						// No handle found. Check if a handle for this path + a
-						// trailing slash exists for TSR recommendation
-						n = n.children[0]
-						tsr = (n.path == "/" && n.handlers != nil)
-					}
-
-					return
-
-				case catchAll:
-					// save param value
-					if cap(ps) < int(n.maxParams) {
-						ps = make(Params, 0, n.maxParams)
-					}
-					i := len(ps)
-					ps = ps[:i+1] // expand slice within preallocated capacity
-					ps[i].Key = n.path[2:]
-					ps[i].Value = path
-
-					handlers = n.handlers
-					return
-
-				default:
-					panic("invalid node type")
-				}
-			}
-		} else if path == n.path {
-			// We should have reached the node containing the handle.
-			// Check if this node has a handle registered.
-			if handlers = n.handlers; handlers != nil {
-				return
-			}
+	if leaf = n.match(path, &ps); leaf != nil {
+		return
+	}
+
+	// Nothing found. We can recommend to redirect to the same URL with an
+	// extra (without the) trailing slash if a leaf exists for that path.
+	if len(path) > 1 && path[len(path)-1] == '/' {
+		tsr = n.match(path[:len(path)-1], &ps) != nil
+	} else if path != "" {
+		tsr = n.match(path+"/", &ps) != nil
+	}
+	ps = psBuf[:0]
+	return
+}
+
+// match walks the subtree n for path, it returns the node holding the handle,
+// or nil if no handle can be found. The values of wildcards are appended to ps.
+func (n *node) match(path string, ps *Params) *node {
+	numParams := len(*ps)
 
-			if path == "/" && n.wildChild && n.nType != root {
-				tsr = true
-				return
+	switch n.nType {
+	case static, root:
+		if len(path) < len(n.path) || path[:len(n.path)] != n.path {
+			return nil
+		}
+		path = path[len(n.path):]
+
+	case param:
+		// find param end (either '/' or path end)
+		end := 0
+		for end < len(path) && path[end] != '/' {
+			end++
+		}
+		if end == 0 {
+			return nil
+		}
+
+		// check the constraint of param
+		key := n.path[1:]
+		if n.constraint != nil {
+			if !n.constraint.match(path[:end]) {
+				return nil
 			}
+			key = n.constraint.key
+		}
+
+		// save param value
+		*ps = appendParam(*ps, key, path[:end], n.maxParams)
+		path = path[end:]
+
+	case catchAll:
+		if len(path) == 0 || path[0] != '/' || n.handlers == nil {
+			return nil
+		}
+
+		// save param value
+		*ps = appendParam(*ps, n.path[2:], path, n.maxParams)
+		return n
+
+	default:
+		panic("invalid node type")
+	}
 
-			// No handle found. Check if a handle for this path + a
-			// trailing slash exists for trailing slash recommendation
-			for i := 0; i < len(n.indices); i++ {
-				if n.indices[i] == '/' {
-					n = n.children[i]
-					tsr = (len(n.path) == 1 && n.handlers != nil) ||
-						(n.nType == catchAll && n.children[0].handlers != nil)
-					return
-				}
+	// We should have reached the node containing the handle.
+	if len(path) == 0 {
+		if n.handlers != nil {
+			return n
+		}
+		*ps = (*ps)[:numParams]
+		return nil
+	}
+
+	// static children first
+	c := path[0]
+	for i := 0; i < len(n.indices); i++ {
+		if c == n.indices[i] {
+			if leaf := n.children[i].match(path, ps); leaf != nil {
+				return leaf
 			}
+			break
+		}
+	}
 
-			return
+	// then the wildcard children
+	for _, child := range n.wildChildren() {
+		if leaf := child.match(path, ps); leaf != nil {
+			return leaf
 		}
+	}
 
-		// Nothing found. We can recommend to redirect to the same URL with an
-		// extra trailing slash if a leaf exists for that path
-		tsr = (path == "/") ||
-			(len(n.path) == len(path)+1 && n.path[len(path)] == '/' &&
-				path == n.path[:len(n.path)-1] && n.handlers != nil)
-		return
+	*ps = (*ps)[:numParams]
+	return nil
+}
+
+// appendParam appends the param to ps, it preallocates the capacity for maxParams
+// more params if the capacity of ps is not enough.
+func appendParam(ps Params, key, value string, maxParams uint8) Params {
+	if len(ps) == cap(ps) {
+		newPs := make(Params, len(ps), len(ps)+int(maxParams))
+		copy(newPs, ps)
+		ps = newPs
 	}
+	return append(ps, Param{Key: key, Value: value})
 }
 
 // Makes a case-insensitive lookup of the given path and tries to find a handler.
@@ -446,202 +639,97 @@ walk: // outer loop for walking the tree
 // It returns the case-corrected path and a bool indicating whether the lookup
 // was successful.
 func (n *node) findCaseInsensitivePath(path string, fixTrailingSlash bool) (ciPath []byte, found bool) {
-	return n.findCaseInsensitivePathRec(
-		path,
-		strings.ToLower(path),
-		make([]byte, 0, len(path)+1), // preallocate enough memory for new path
-		[4]byte{},                    // empty rune buffer
-		fixTrailingSlash,
-	)
-}
+	buf := make([]byte, 0, len(path)+1) // preallocate enough memory for new path
+	if ciPath, found = n.findCaseInsensitivePathRec(path, 0, buf); found {
+		return
+	}
 
-// shift bytes in array by n bytes left
-func shiftNRuneBytes(rb [4]byte, n int) [4]byte {
-	switch n {
-	case 0:
-		return rb
-	case 1:
-		return [4]byte{rb[1], rb[2], rb[3], 0}
-	case 2:
-		return [4]byte{rb[2], rb[3]}
-	case 3:
-		return [4]byte{rb[3]}
-	default:
-		return [4]byte{}
+	// Nothing found.
+	// Try to fix the path by adding / removing a trailing slash
+	if fixTrailingSlash {
+		if len(path) > 1 && path[len(path)-1] == '/' {
+			return n.findCaseInsensitivePathRec(path[:len(path)-1], 0, buf)
+		}
+		if path != "" {
+			return n.findCaseInsensitivePathRec(path+"/", 0, buf)
+		}
 	}
+	return ciPath, false
 }
 
-// recursive case-insensitive lookup function used by n.findCaseInsensitivePath
-func (n *node) findCaseInsensitivePathRec(path, loPath string, ciPath []byte, rb [4]byte, fixTrailingSlash bool) ([]byte, bool) {
-	loNPath := strings.ToLower(n.path)
-
-walk: // outer loop for walking the tree
-	for len(loPath) >= len(loNPath) && (len(loNPath) == 0 || loPath[1:len(loNPath)] == loNPath[1:]) {
-		// add common path to result
-		ciPath = append(ciPath, n.path...)
-
-		if path = path[len(n.path):]; len(path) > 0 {
-			loOld := loPath
-			loPath = loPath[len(loNPath):]
-
-			// If this node does not have a wildcard (param or catchAll) child,
-			// we can just look up the next child node and continue to walk down
-			// the tree
-			if !n.wildChild {
-				// skip rune bytes already processed
-				rb = shiftNRuneBytes(rb, len(loNPath))
-
-				if rb[0] != 0 {
-					// old rune not finished
-					for i := 0; i < len(n.indices); i++ {
-						if n.indices[i] == rb[0] {
-							// continue with child node
-							n = n.children[i]
-							loNPath = strings.ToLower(n.path)
-							continue walk
-						}
-					}
-				} else {
-					// process a new rune
-					var rv rune
-
-					// find rune start
-					// runes are up to 4 byte long,
-					// -4 would definitely be another rune
-					var off int
-					for max := min(len(loNPath), 3); off < max; off++ {
-						if i := len(loNPath) - off; utf8.RuneStart(loOld[i]) {
-							// read rune from cached lowercase path
-							rv, _ = utf8.DecodeRuneInString(loOld[i:])
-							break
-						}
-					}
-
-					// calculate lowercase bytes of current rune
-					utf8.EncodeRune(rb[:], rv)
-					// skipp already processed bytes
-					rb = shiftNRuneBytes(rb, off)
-
-					for i := 0; i < len(n.indices); i++ {
-						// lowercase matches
-						if n.indices[i] == rb[0] {
-							// must use a recursive approach since both the
-							// uppercase byte and the lowercase byte might exist
-							// as an index
-							if out, found := n.children[i].findCaseInsensitivePathRec(
-								path, loPath, ciPath, rb, fixTrailingSlash,
-							); found {
-								return out, true
-							}
-							break
-						}
-					}
-
-					// same for uppercase rune, if it differs
-					if up := unicode.ToUpper(rv); up != rv {
-						utf8.EncodeRune(rb[:], up)
-						rb = shiftNRuneBytes(rb, off)
-
-						for i := 0; i < len(n.indices); i++ {
-							// uppercase matches
-							if n.indices[i] == rb[0] {
-								// continue with child node
-								n = n.children[i]
-								loNPath = strings.ToLower(n.path)
-								continue walk
-							}
-						}
-					}
-				}
-
-				// Nothing found. We can recommend to redirect to the same URL
-				// without a trailing slash if a leaf exists for that path
-				return ciPath, (fixTrailingSlash && path == "/" && n.handlers != nil)
-			}
+// recursive case-insensitive lookup function used by n.findCaseInsensitivePath,
+// n.path[off:] is the part of the node which has not been matched.
+func (n *node) findCaseInsensitivePathRec(path string, off int, ciPath []byte) ([]byte, bool) {
+	if len(path) == 0 {
+		return ciPath, off == len(n.path) && n.handlers != nil
+	}
 
-			n = n.children[0]
-			switch n.nType {
-			case param:
-				// find param end (either '/' or path end)
-				k := 0
-				for k < len(path) && path[k] != '/' {
-					k++
-				}
-
-				// add param value to case insensitive path
-				ciPath = append(ciPath, path[:k]...)
-
-				// we need to go deeper!
-				if k < len(path) {
-					if len(n.children) > 0 {
-						// continue with child node
-						n = n.children[0]
-						loNPath = strings.ToLower(n.path)
-						loPath = loPath[k:]
-						path = path[k:]
-						continue
-					}
-
-					// ... but we can't
-					if fixTrailingSlash && len(path) == k+1 {
-						return ciPath, true
-					}
-					return ciPath, false
-				}
-
-				if n.handlers != nil {
-					return ciPath, true
-				} else if fixTrailingSlash && len(n.children) == 1 {
-					// No handle found. Check if a handle for this path + a
-					// trailing slash exists
-					n = n.children[0]
-					if n.path == "/" && n.handlers != nil {
-						return append(ciPath, '/'), true
-					}
-				}
-				return ciPath, false
-
-			case catchAll:
-				return append(ciPath, path...), true
+	// match the next rune with the static nodes, try all the case variants of it
+	rv, size := utf8.DecodeRuneInString(path)
+	var rb [utf8.UTFMax]byte
+	for v := rv; ; {
+		b := rb[:utf8.EncodeRune(rb[:], v)]
+		if child, childOff, ok := n.matchBytes(off, b); ok {
+			if out, found := child.findCaseInsensitivePathRec(path[size:], childOff, append(ciPath, b...)); found {
+				return out, true
+			}
+		}
+		if v = unicode.SimpleFold(v); v == rv {
+			break
+		}
+	}
 
-			default:
-				panic("invalid node type")
+	// then the wildcard children
+	if off < len(n.path) {
+		return ciPath, false
+	}
+	for _, child := range n.wildChildren() {
+		switch child.nType {
+		case param:
+			// find param end (either '/' or path end)
+			end := 0
+			for end < len(path) && path[end] != '/' {
+				end++
 			}
-		} else {
-			// We should have reached the node containing the handle.
-			// Check if this node has a handle registered.
-			if n.handlers != nil {
-				return ciPath, true
+			if end == 0 || (child.constraint != nil && !child.constraint.match(path[:end])) {
+				continue
 			}
 
-			// No handle found.
-			// Try to fix the path by adding a trailing slash
-			if fixTrailingSlash {
-				for i := 0; i < len(n.indices); i++ {
-					if n.indices[i] == '/' {
-						n = n.children[i]
-						if (len(n.path) == 1 && n.handlers != nil) ||
-							(n.nType == catchAll && n.children[0].handlers != nil) {
-							return append(ciPath, '/'), true
-						}
-						return ciPath, false
-					}
-				}
+			// add param value to case insensitive path
+			if out, found := child.findCaseInsensitivePathRec(path[end:], len(child.path), append(ciPath, path[:end]...)); found {
+				return out, true
 			}
-			return ciPath, false
+
+		case catchAll:
+			if path[0] == '/' && child.handlers != nil {
+				return append(ciPath, path...), true
+			}
+
+		default:
+			panic("invalid node type")
 		}
 	}
+	return ciPath, false
+}
 
-	// Nothing found.
-	// Try to fix the path by adding / removing a trailing slash
-	if fixTrailingSlash {
-		if path == "/" {
-			return ciPath, true
+// matchBytes matches b with the static nodes from n.path[off:],
+// it returns the node and the offset in the node path where b ends.
+func (n *node) matchBytes(off int, b []byte) (*node, int, bool) {
+	for len(b) > 0 {
+		if off == len(n.path) {
+			// continue with the static child
+			i := strings.IndexByte(n.indices, b[0])
+			if i < 0 {
+				return nil, 0, false
+			}
+			n, off = n.children[i], 0
 		}
-		if len(loPath)+1 == len(loNPath) && loNPath[len(loPath)] == '/' &&
-			loPath[1:] == loNPath[1:len(loPath)] && n.handlers != nil {
-			return append(ciPath, n.path...), true
+		k := min(len(b), len(n.path)-off)
+		if n.path[off:off+k] != string(b[:k]) {
+			return nil, 0, false
 		}
+		off += k
+		b = b[k:]
 	}
-	return ciPath, false
+	return n, off, true
 }
@@ -89,7 +89,7 @@ func checkMaxParams(t *testing.T, n *node) uint8 {
 			maxParams = params
 		}
 	}
-	if n.nType > root && !n.wildChild {
+	if n.nType == param || n.nType == catchAll {
 		maxParams++
 	}
 
@@ -110,6 +110,9 @@ func TestCountParams(t *testing.T) {
 	if countParams(strings.Repeat("/:param", 256)) != 255 {
 		t.Fail()
 	}
+	if countParams("/path/:param1<\\d+:\\d+>/static/*catch-all") != 2 {
+		t.Fail()
+	}
 }
 
 func TestTreeAddAndGet(t *testing.T) {
@@ -180,13 +183,13 @@ func TestTreeWildcard(t *testing.T) {
 	checkRequests(t, tree, testRequests{
 		{"/", false, "/", nil},
 		{"/cmd/test/", false, "/cmd/:tool/", Params{Param{"tool", "test"}}},
-		{"/cmd/test", true, "", Params{Param{"tool", "test"}}},
+		{"/cmd/test", true, "", nil},
 		{"/cmd/test/3", false, "/cmd/:tool/:sub", Params{Param{"tool", "test"}, Param{"sub", "3"}}},
 		{"/src/", false, "/src/*filepath", Params{Param{"filepath", "/"}}},
 		{"/src/some/file.png", false, "/src/*filepath", Params{Param{"filepath", "/some/file.png"}}},
 		{"/search/", false, "/search/", nil},
 		{"/search/someth!ng+in+ünìcodé", false, "/search/:query", Params{Param{"query", "someth!ng+in+ünìcodé"}}},
-		{"/search/someth!ng+in+ünìcodé/", true, "", Params{Param{"query", "someth!ng+in+ünìcodé"}}},
+		{"/search/someth!ng+in+ünìcodé/", true, "", nil},
 		{"/user_gopher", false, "/user_:name", Params{Param{"name", "gopher"}}},
 		{"/user_gopher/about", false, "/user_:name/about", Params{Param{"name", "gopher"}}},
 		{"/files/js/inc/framework.js", false, "/files/:dir/*filepath", Params{Param{"dir", "js"}, Param{"filepath", "/inc/framework.js"}}},
@@ -198,6 +201,85 @@ func TestTreeWildcard(t *testing.T) {
 	checkMaxParams(t, tree)
 }
 
+func TestTreeParamConstraint(t *testing.T) {
+	tree := &node{}
+
+	routes := [...]string{
+		"/users/:id<int>",
+		"/users/:id<int>/posts/:post<uint>",
+		"/objects/:uuid<uuid>",
+		"/posts/:slug<[a-z0-9-]+>",
+		"/times/:time<\\d{2}:\\d{2}>/*rest",
+		"/names/:name<alpha>/",
+	}
+	for _, route := range routes {
+		tree.addRoute(route, fakeHandler(route))
+	}
+
+	checkRequests(t, tree, testRequests{
+		{"/users/12", false, "/users/:id<int>", Params{Param{"id", "12"}}},
+		{"/users/-12", false, "/users/:id<int>", Params{Param{"id", "-12"}}},
+		{"/users/abc", true, "", nil},
+		{"/users/12/posts/3", false, "/users/:id<int>/posts/:post<uint>", Params{Param{"id", "12"}, Param{"post", "3"}}},
+		{"/users/12/posts/-3", true, "", nil},
+		{"/objects/123e4567-e89b-12d3-a456-426614174000", false, "/objects/:uuid<uuid>", Params{Param{"uuid", "123e4567-e89b-12d3-a456-426614174000"}}},
+		{"/objects/123e4567", true, "", nil},
+		{"/posts/hello-world-2", false, "/posts/:slug<[a-z0-9-]+>", Params{Param{"slug", "hello-world-2"}}},
+		{"/posts/Hello", true, "", nil},
+		{"/times/12:30/a/b", false, "/times/:time<\\d{2}:\\d{2}>/*rest", Params{Param{"time", "12:30"}, Param{"rest", "/a/b"}}},
+		{"/times/1230/a/b", true, "", nil},
+		{"/names/john/", false, "/names/:name<alpha>/", Params{Param{"name", "john"}}},
+		{"/names/john1/", true, "", nil},
+	})
+
+	checkPriorities(t, tree)
+	checkMaxParams(t, tree)
+
+	// a failed constraint must not recommend a trailing slash redirect
+	if _, _, tsr := tree.getValue("/names/john1", nil); tsr {
+		t.Errorf("unexpected TSR for route '/names/john1'")
+	}
+	if out, found := tree.findCaseInsensitivePath("/USERS/12", true); !found || string(out) != "/users/12" {
+		t.Errorf("Wrong result for '/USERS/12': got %s, %t", string(out), found)
+	}
+	if _, found := tree.findCaseInsensitivePath("/USERS/abc", true); found {
+		t.Errorf("Found '/USERS/abc' which does not satisfy the constraint")
+	}
+}
+
+func TestTreeParamConstraintInvalid(t *testing.T) {
+	routes := [...]string{
+		"/users/:id<>",
+		"/users/:<int>",
+		"/users/:id<int",
+		"/users/:id<int>x",
+		"/users/:id<[a-z>",
+		"/users/:id<unknown(>",
+		"/src/*filepath<int>",
+	}
+	for _, route := range routes {
+		tree := &node{}
+		recv := catchPanic(func() {
+			tree.addRoute(route, nil)
+		})
+		if recv == nil {
+			t.Errorf("no panic while inserting route with invalid constraint '%s'", route)
+		}
+	}
+
+	// params with the same constraint must have the same name
+	tree := &node{}
+	tree.addRoute("/users/:id<int>", fakeHandler("/users/:id<int>"))
+	for _, route := range [...]string{"/users/:name<int>", "/users/:id<int", "/users/:num<int>/edit"} {
+		recv := catchPanic(func() {
+			tree.addRoute(route, fakeHandler(route))
+		})
+		if recv == nil {
+			t.Errorf("no panic while inserting conflicting route '%s'", route)
+		}
+	}
+}
+
 func catchPanic(testFunc func()) (recv interface{}) {
 	defer func() {
 		recv = recover()
@@ -235,20 +317,24 @@ func testRoutes(t *testing.T, routes []testRoute) {
 func TestTreeWildcardConflict(t *testing.T) {
 	routes := []testRoute{
 		{"/cmd/:tool/:sub", false},
-		{"/cmd/vet", true},
+		{"/cmd/vet", false},
+		{"/cmd/:tool/:other", true},
+		{"/cmd/:command", true},
 		{"/src/*filepath", false},
 		{"/src/*filepathx", true},
-		{"/src/", true},
+		{"/src/", false},
 		{"/src1/", false},
-		{"/src1/*filepath", true},
+		{"/src1/*filepath", false},
 		{"/src2*filepath", true},
 		{"/search/:query", false},
-		{"/search/invalid", true},
+		{"/search/invalid", false},
+		{"/search/:name", true},
 		{"/user_:name", false},
-		{"/user_x", true},
+		{"/user_x", false},
 		{"/user_:name", false},
 		{"/id:id", false},
-		{"/id/:id", true},
+		{"/id/:id", false},
+		{"/id:name", true},
 	}
 	testRoutes(t, routes)
 }
@@ -256,19 +342,175 @@ func TestTreeWildcardConflict(t *testing.T) {
 func TestTreeChildConflict(t *testing.T) {
 	routes := []testRoute{
 		{"/cmd/vet", false},
-		{"/cmd/:tool/:sub", true},
+		{"/cmd/:tool/:sub", false},
 		{"/src/AUTHORS", false},
-		{"/src/*filepath", true},
+		{"/src/*filepath", false},
 		{"/user_x", false},
-		{"/user_:name", true},
+		{"/user_:name", false},
 		{"/id/:id", false},
-		{"/id:id", true},
-		{"/:id", true},
-		{"/*filepath", true},
+		{"/id:id", false},
+		{"/:id", false},
+		{"/*filepath", false},
+		{"/*any", true},
+		{"/:name", true},
 	}
 	testRoutes(t, routes)
 }
 
+func TestTreeStaticAndWildcard(t *testing.T) {
+	tree := &node{}
+
+	routes := [...]string{
+		"/users/new",
+		"/users/:id",
+		"/users/:id<int>/edit",
+		"/users/:id/profile",
+		"/users/new/profile",
+		"/files/index",
+		"/files/*filepath",
+		"/src/:name",
+		"/src/:name/readme",
+		"/src/*filepath",
+		"/*any",
+		"/items/:id<int>",
+		"/items/:id<uuid>",
+		"/items/:name",
+		"/items/latest",
+	}
+	for _, route := range routes {
+		recv := catchPanic(func() {
+			tree.addRoute(route, fakeHandler(route))
+		})
+		if recv != nil {
+			t.Fatalf("panic inserting route '%s': %v", route, recv)
+		}
+	}
+
+	//printChildren(tree, "")
+
+	checkRequests(t, tree, testRequests{
+		// static wins over param
+		{"/users/new", false, "/users/new", nil},
+		{"/users/newer", false, "/users/:id", Params{Param{"id", "newer"}}},
+		{"/users/ne", false, "/users/:id", Params{Param{"id", "ne"}}},
+		{"/users/12", false, "/users/:id", Params{Param{"id", "12"}}},
+		// backtracking from the static child to the param child, and to the catch-all at last
+		{"/users/new/edit", false, "/*any", Params{Param{"any", "/users/new/edit"}}},
+		{"/users/new/profile", false, "/users/new/profile", nil},
+		{"/users/newer/profile", false, "/users/:id/profile", Params{Param{"id", "newer"}}},
+		// backtracking from the constrained param to the other param
+		{"/users/12/edit", false, "/users/:id<int>/edit", Params{Param{"id", "12"}}},
+		{"/users/12/profile", false, "/users/:id/profile", Params{Param{"id", "12"}}},
+		{"/users/abc/edit", false, "/*any", Params{Param{"any", "/users/abc/edit"}}},
+		// static wins over catch-all
+		{"/files/index", false, "/files/index", nil},
+		{"/files/index.html", false, "/files/*filepath", Params{Param{"filepath", "/index.html"}}},
+		{"/files/", false, "/files/*filepath", Params{Param{"filepath", "/"}}},
+		// param wins over catch-all
+		{"/src/gin", false, "/src/:name", Params{Param{"name", "gin"}}},
+		{"/src/gin/readme", false, "/src/:name/readme", Params{Param{"name", "gin"}}},
+		{"/src/gin/LICENSE", false, "/src/*filepath", Params{Param{"filepath", "/gin/LICENSE"}}},
+		{"/src/", false, "/src/*filepath", Params{Param{"filepath", "/"}}},
+		// catch-all at root
+		{"/", false, "/*any", Params{Param{"any", "/"}}},
+		{"/users", false, "/*any", Params{Param{"any", "/users"}}},
+		{"/users/12/posts", false, "/*any", Params{Param{"any", "/users/12/posts"}}},
+		// constrained params are tried in the registration order, before the param without constraint
+		{"/items/latest", false, "/items/latest", nil},
+		{"/items/12", false, "/items/:id<int>", Params{Param{"id", "12"}}},
+		{"/items/123e4567-e89b-12d3-a456-426614174000", false, "/items/:id<uuid>", Params{Param{"id", "123e4567-e89b-12d3-a456-426614174000"}}},
+		{"/items/gopher", false, "/items/:name", Params{Param{"name", "gopher"}}},
+	})
+
+	checkPriorities(t, tree)
+	checkMaxParams(t, tree)
+
+	if out, found := tree.findCaseInsensitivePath("/USERS/NEW/PROFILE", false); !found || string(out) != "/users/new/profile" {
+		t.Errorf("Wrong result for '/USERS/NEW/PROFILE': got %s, %t", string(out), found)
+	}
+	if out, found := tree.findCaseInsensitivePath("/USERS/Newer/PROFILE", false); !found || string(out) != "/users/Newer/profile" {
+		t.Errorf("Wrong result for '/USERS/Newer/PROFILE': got %s, %t", string(out), found)
+	}
+}
+
+func TestTreeDeepBacktracking(t *testing.T) {
+	tree := &node{}
+
+	routes := [...]string{
+		"/api/v1/users/list",
+		"/api/v1/users/:id/detail",
+		"/api/v1/:res/:id/meta",
+		"/api/:ver<v[0-9]+>/:res/:id/raw",
+		"/api/:ver<v[0-9]+>/:res/*path",
+		"/api/*rest",
+	}
+	for _, route := range routes {
+		recv := catchPanic(func() {
+			tree.addRoute(route, fakeHandler(route))
+		})
+		if recv != nil {
+			t.Fatalf("panic inserting route '%s': %v", route, recv)
+		}
+	}
+
+	checkRequests(t, tree, testRequests{
+		{"/api/v1/users/list", false, "/api/v1/users/list", nil},
+		// static, static, static, then param
+		{"/api/v1/users/7/detail", false, "/api/v1/users/:id/detail", Params{Param{"id", "7"}}},
+		// backtracking from the static child at the 3rd level to the param
+		{"/api/v1/users/7/meta", false, "/api/v1/:res/:id/meta", Params{Param{"res", "users"}, Param{"id", "7"}}},
+		// backtracking from the 4th level to the constrained param at the 2nd level
+		{"/api/v1/users/list/raw", false, "/api/:ver<v[0-9]+>/:res/:id/raw", Params{Param{"ver", "v1"}, Param{"res", "users"}, Param{"id", "list"}}},
+		{"/api/v2/users/7/raw", false, "/api/:ver<v[0-9]+>/:res/:id/raw", Params{Param{"ver", "v2"}, Param{"res", "users"}, Param{"id", "7"}}},
+		// backtracking from the 5th level to the catch-all at the 3rd level
+		{"/api/v1/users/7/detail/more", false, "/api/:ver<v[0-9]+>/:res/*path", Params{Param{"ver", "v1"}, Param{"res", "users"}, Param{"path", "/7/detail/more"}}},
+		// backtracking to the catch-all at the 2nd level, the params of the failed branches are dropped
+		{"/api/v1/users", false, "/api/*rest", Params{Param{"rest", "/v1/users"}}},
+		{"/api/x1/users/7/raw", false, "/api/*rest", Params{Param{"rest", "/x1/users/7/raw"}}},
+		{"/api/v1", false, "/api/*rest", Params{Param{"rest", "/v1"}}},
+		{"/api", true, "", nil},
+	})
+
+	checkPriorities(t, tree)
+	checkMaxParams(t, tree)
+}
+
+func TestTreeMaxParamsBuffer(t *testing.T) {
+	tree := &node{}
+
+	routes := [...]string{
+		"/a/:x",
+		"/a/:b<int>/:c<int>/:d<int>/:e<alpha>/*rest",
+		"/a/:b<int>/:c<int>/static",
+		"/a/:b<int>/*tail",
+		"/z/*rest",
+	}
+	for _, route := range routes {
+		tree.addRoute(route, fakeHandler(route))
+	}
+	checkMaxParams(t, tree)
+	if tree.maxParams != 5 {
+		t.Fatalf("maxParams of root is %d, want 5", tree.maxParams)
+	}
+
+	for _, path := range [...]string{
+		"/a/1/2/3/abc/x/y",
+		"/a/1/2/3/4/x", // fails at the 4th param and backtracks, the params of the failed branch must not grow the buffer
+		"/a/1/2/static",
+		"/a/abc",
+	} {
+		buf := make(Params, 0, tree.maxParams)
+		handler, ps, _ := tree.getValue(path, buf)
+		if handler == nil {
+			t.Errorf("no handle for route '%s'", path)
+			continue
+		}
+		if len(ps) == 0 || &ps[0] != &buf[:1][0] {
+			t.Errorf("the params of route '%s' are not stored in the buffer: %v", path, ps)
+		}
+	}
+}
+
 func TestTreeDupliatePath(t *testing.T) {
 	tree := &node{}
 
@@ -338,7 +580,8 @@ func TestTreeCatchAllConflict(t *testing.T) {
 func TestTreeCatchAllConflictRoot(t *testing.T) {
 	routes := []testRoute{
 		{"/", false},
-		{"/*filepath", true},
+		{"/*filepath", false},
+		{"/*any", true},
 	}
 	testRoutes(t, routes)
 }
@@ -657,3 +900,154 @@ func TestTreeInvalidNodeType(t *testing.T) {
 		t.Fatalf("Expected panic '"+panicMsg+"', got '%v'", recv)
 	}
 }
+
+func TestTreeRemoveRoute(t *testing.T) {
+	tree := &node{}
+
+	routes := [...]string{
+		"/",
+		"/cmd/:tool/:sub",
+		"/cmd/:tool/",
+		"/cmd/vet",
+		"/src/*filepath",
+		"/src/:name<int>",
+		"/search/",
+		"/search/:query",
+		"/user_:name",
+		"/user_:name/about",
+		"/files/:dir/*filepath",
+		"/doc/",
+		"/doc/go_faq.html",
+		"/doc/go1.html",
+		"/info/:user/public",
+		"/info/:user/project/:project",
+	}
+	for _, route := range routes {
+		tree.addRoute(route, fakeHandler(route))
+	}
+
+	for _, route := range [...]string{"/cmd/:tool", "/cmd/:tool/:other", "/src/:name", "/doc", "/doc/go", "/nothing", "/user_:nam"} {
+		if tree.removeRoute(route) {
+			t.Errorf("removed route '%s' which was not registered", route)
+		}
+	}
+
+	removed := [...]string{
+		"/cmd/:tool/:sub",
+		"/src/:name<int>",
+		"/doc/go_faq.html",
+		"/user_:name",
+		"/files/:dir/*filepath",
+		"/info/:user/project/:project",
+	}
+	for _, route := range removed {
+		if !tree.removeRoute(route) {
+			t.Errorf("failed to remove route '%s'", route)
+		}
+		if tree.removeRoute(route) {
+			t.Errorf("removed route '%s' twice", route)
+		}
+	}
+
+	//printChildren(tree, "")
+
+	checkRequests(t, tree, testRequests{
+		{"/", false, "/", nil},
+		{"/cmd/test/3", true, "", nil},
+		{"/cmd/test/", false, "/cmd/:tool/", Params{Param{"tool", "test"}}},
+		{"/cmd/vet", false, "/cmd/vet", nil},
+		{"/src/12", false, "/src/*filepath", Params{Param{"filepath", "/12"}}},
+		{"/search/gopher", false, "/search/:query", Params{Param{"query", "gopher"}}},
+		{"/user_gopher", true, "", nil},
+		{"/user_gopher/about", false, "/user_:name/about", Params{Param{"name", "gopher"}}},
+		{"/files/js/inc/framework.js", true, "", nil},
+		{"/doc/go_faq.html", true, "", nil},
+		{"/doc/go1.html", false, "/doc/go1.html", nil},
+		{"/info/gordon/public", false, "/info/:user/public", Params{Param{"user", "gordon"}}},
+		{"/info/gordon/project/go", true, "", nil},
+	})
+
+	checkPriorities(t, tree)
+	checkMaxParams(t, tree)
+
+	// the prefixes are merged as if the removed routes were never registered
+	tree = &node{}
+	tree.addRoute("/doc/", fakeHandler("/doc/"))
+	tree.addRoute("/doc/go_faq.html", fakeHandler("/doc/go_faq.html"))
+	tree.addRoute("/doc/go1.html", fakeHandler("/doc/go1.html"))
+	tree.removeRoute("/doc/")
+	tree.removeRoute("/doc/go_faq.html")
+	if tree.path != "/doc/go1.html" || len(tree.children) != 0 || tree.nType != root || tree.priority != 1 {
+		t.Errorf("prefixes are not merged after removing routes")
+	}
+	tree.removeRoute("/doc/go1.html")
+	if !reflect.DeepEqual(tree, &node{}) {
+		t.Errorf("tree is not empty after removing all routes")
+	}
+}
+
+func TestTreeConflictError(t *testing.T) {
+	tree := &node{}
+	tree.addRoute("/users/:id/posts", fakeHandler("/users/:id/posts"))
+	tree.addRoute("/users/new", fakeHandler("/users/new"))
+
+	tests := [...]struct {
+		path, existingPath string
+	}{
+		{"/users/:name", "/users/:id/posts"},
+		{"/users/new", "/users/new"},
+		{"/users/:id/posts", "/users/:id/posts"},
+	}
+	for _, tt := range tests {
+		recv := catchPanic(func() {
+			tree.addRouteMethod("GET", tt.path, fakeHandler(tt.path))
+		})
+		err, ok := recv.(*RouteConflictError)
+		if !ok {
+			t.Errorf("expected *RouteConflictError for route '%s', got %v", tt.path, recv)
+			continue
+		}
+		if err.Method != "GET" || err.Path != tt.path || err.ExistingPath != tt.existingPath {
+			t.Errorf("wrong error for route '%s': %+v", tt.path, err)
+		}
+	}
+}
+
+// dumpTree returns the structure of the tree, the handlers are compared by nil-ness.
+func dumpTree(n *node, prefix string) string {
+	s := fmt.Sprintf("%s%s[%d:%d:%d:%q:%t:%t]\n", prefix, n.path, n.nType, n.priority, n.maxParams, n.indices, n.wildChild, n.handlers != nil)
+	for _, child := range n.children {
+		s += dumpTree(child, prefix+"  ")
+	}
+	return s
+}
+
+func TestTreeRepair(t *testing.T) {
+	build := func() *node {
+		tree := &node{}
+		for _, route := range [...]string{"/users/:id/posts", "/users/new", "/src/*filepath"} {
+			tree.addRoute(route, fakeHandler(route))
+		}
+		return tree
+	}
+	tree := build()
+	want := dumpTree(tree, "")
+	for _, route := range [...]string{
+		"/users/:name/posts/new",
+		"/users/news/:id/:x:y",
+		"/src/*path",
+		"/us:",
+		"/users/new",
+		"/src/abc/*filepath/x",
+	} {
+		recv := catchPanic(func() {
+			tree.addRouteMethod("GET", route, fakeHandler(route))
+		})
+		if recv == nil {
+			t.Errorf("no panic for invalid route '%s'", route)
+		}
+		if got := dumpTree(tree, ""); got != want {
+			t.Errorf("tree is not repaired after inserting invalid route '%s':\n%s\nwant:\n%s", route, got, want)
+		}
+	}
+}
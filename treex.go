@@ -55,10 +55,33 @@ func (p *trees) addTree(method string, root *node) {
 	*p = append(*p, tree{method: method, root: root})
 }
 
+// clone returns a deep copy of the trees, the handlers and the routes are shared.
+func (v trees) clone() trees {
+	c := make(trees, len(v))
+	for i, t := range v {
+		c[i] = tree{method: t.method, root: t.root.clone()}
+	}
+	return c
+}
+
 type Route struct {
 	Method  string
-	Path    string
-	Handler string // handler name
+	Host    string    // host pattern, empty if the route is not registered by Engine.Host()
+	Path    string    // path pattern, including the constraints of parameters, e.g. /users/:id<int>
+	Handler string    // handler name
+	Name    string    // route name, empty if the route has not been named
+	Meta    RouteMeta // route metadata
+}
+
+// routeInfo holds the registration details of a route,
+// it is shared by the tree node and the RegisteredRoute of the route.
+type routeInfo struct {
+	method  string
+	host    string // host pattern
+	path    string
+	handler string // handler name
+	name    string
+	meta    map[string]interface{}
 }
 
 // routes returns a slice of registered routes, including some useful information, such as:
@@ -74,11 +97,17 @@ func (v trees) routes() []Route {
 func iterateTree(routesPtr *[]Route, root *node, pathPrefix, treeMethod string) {
 	path := pathPrefix + root.path
 	if len(root.handlers) > 0 {
-		*routesPtr = append(*routesPtr, Route{
+		route := Route{
 			Method:  treeMethod,
 			Path:    path,
 			Handler: nameOfFunction(root.handlers.last()),
-		})
+		}
+		if root.route != nil {
+			route.Host = root.route.host
+			route.Name = root.route.name
+			route.Meta = RouteMeta{m: root.route.meta}
+		}
+		*routesPtr = append(*routesPtr, route)
 	}
 	for _, childRoot := range root.children {
 		iterateTree(routesPtr, childRoot, path, treeMethod)
@@ -0,0 +1,310 @@
+// Copyright 2014 Manu Martinez-Almeida.  All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package gin
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/chanxuehong/gin/internal/netutil"
+)
+
+// The default headers (canonical) to get the client IP from, in order of precedence, see Engine.RemoteIPHeaders().
+// X-Real-IP is the last since it is a single value, which can not be walked along the trusted proxies.
+var __defaultRemoteIPHeaders = []string{
+	http.CanonicalHeaderKey(HeaderXForwardedFor),
+	HeaderForwarded,
+	http.CanonicalHeaderKey(HeaderXRealIP),
+}
+
+// TrustedProxies sets the proxies whose forwarding headers are trusted, each of cidrs is
+// a CIDR (e.g. "10.0.0.0/8", "fd00::/8") or a single IP (e.g. "192.168.1.1").
+// It also enables fetching the client IP from headers, see Engine.FetchClientIPFromHeader().
+//
+// The headers are used only if the request comes from a trusted proxy. The lists of
+// X-Forwarded-For and Forwarded are walked from right to left, the trusted proxies are skipped,
+// and the first untrusted address is the client IP; so a client can not spoof its IP by
+// sending these headers, the entries added by the client are never reached.
+//
+// If FetchClientIPFromHeader is enabled without the trusted proxies, only the loopback addresses
+// (e.g. a proxy on the same host) are trusted, TrustedProxies must be called to trust the other proxies.
+func (engine *Engine) TrustedProxies(cidrs ...string) {
+	engine.startedChecker.check() // check if engine has been started.
+	nets, err := netutil.ParseIPNets(cidrs)
+	if err != nil {
+		panic("invalid trusted proxy: " + err.Error())
+	}
+	engine.trustedProxies = nets
+	engine.fetchClientIPFromHeader = len(nets) > 0
+}
+
+// RemoteIPHeaders sets the headers to get the client IP from, in order of precedence,
+// e.g. "CF-Connecting-IP", "X-Forwarded-For". The "Forwarded" header is parsed as RFC 7239,
+// the other headers are comma-separated lists of IP (a single IP is a list of one).
+// The headers are used only if the request comes from a trusted proxy, see Engine.TrustedProxies().
+// A single IP header like "X-Real-IP" should be used only if the nearest proxy always sets it.
+//
+// Default is "X-Forwarded-For", "Forwarded", "X-Real-IP".
+func (engine *Engine) RemoteIPHeaders(headers ...string) {
+	engine.startedChecker.check() // check if engine has been started.
+	canonical := make([]string, 0, len(headers))
+	for _, header := range headers {
+		if header == "" {
+			panic("remote IP header can not be empty")
+		}
+		canonical = append(canonical, http.CanonicalHeaderKey(header))
+	}
+	engine.remoteIPHeaders = canonical
+}
+
+// trustedRequest reports whether the forwarding headers of r can be trusted,
+// i.e. FetchClientIPFromHeader is enabled and r comes from a trusted proxy.
+func (engine *Engine) trustedRequest(r *http.Request) bool {
+	if engine == nil || !engine.fetchClientIPFromHeader {
+		return false
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return false
+	}
+	return engine.isTrustedProxy(net.ParseIP(host))
+}
+
+func (engine *Engine) isTrustedProxy(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	if engine.trustedProxies == nil {
+		return ip.IsLoopback()
+	}
+	for _, ipNet := range engine.trustedProxies {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientIPFromHeaders returns the client IP from the headers of r which comes from a trusted proxy.
+func (engine *Engine) clientIPFromHeaders(r *http.Request) (string, bool) {
+	headers := engine.remoteIPHeaders
+	if headers == nil {
+		headers = __defaultRemoteIPHeaders
+	}
+	for _, header := range headers {
+		values := r.Header[header]
+		if len(values) == 0 {
+			continue
+		}
+		if header == HeaderForwarded {
+			if elem, ok := engine.forwardedClient(values); ok {
+				return elem.ip, true
+			}
+			continue
+		}
+		if ip, ok := engine.walkForwardedFor(values); ok {
+			return ip, true
+		}
+	}
+	return "", false
+}
+
+// walkForwardedFor walks the comma-separated list of IP in values from right to left,
+// and returns the first IP which is not a trusted proxy, or the leftmost IP if all are trusted.
+// It returns false if any entry walked is not a valid IP.
+func (engine *Engine) walkForwardedFor(values []string) (string, bool) {
+	var client string
+	for i := len(values) - 1; i >= 0; i-- {
+		list := values[i]
+		for list != "" {
+			var entry string
+			if comma := strings.LastIndexByte(list, ','); comma >= 0 {
+				entry, list = list[comma+1:], list[:comma]
+			} else {
+				entry, list = list, ""
+			}
+			ip := parseNodeIP(entry)
+			if ip == nil {
+				return "", false
+			}
+			client = ip.String()
+			if !engine.isTrustedProxy(ip) {
+				return client, true
+			}
+		}
+	}
+	return client, client != ""
+}
+
+// forwardedElement is a forwarded-element of the Forwarded header (RFC 7239).
+type forwardedElement struct {
+	ip    string // the IP of the "for" parameter
+	proto string
+	host  string
+}
+
+// forwardedClient walks the forwarded-elements in values from right to left, and returns the first
+// element whose "for" is not a trusted proxy, or the leftmost element if all are trusted.
+// It returns false if any element walked has no "for" parameter or its "for" is not an IP
+// (e.g. "unknown" or an obfuscated identifier).
+func (engine *Engine) forwardedClient(values []string) (forwardedElement, bool) {
+	var client forwardedElement
+	for i := len(values) - 1; i >= 0; i-- {
+		elems := splitQuoted(values[i], ',')
+		for j := len(elems) - 1; j >= 0; j-- {
+			elem := parseForwardedElement(elems[j])
+			ip := parseNodeIP(elem.ip)
+			if ip == nil {
+				return forwardedElement{}, false
+			}
+			elem.ip = ip.String()
+			client = elem
+			if !engine.isTrustedProxy(ip) {
+				return client, true
+			}
+		}
+	}
+	return client, client.ip != ""
+}
+
+// parseForwardedElement parses a forwarded-element, e.g. `for="[2001:db8::1]:4711";proto=https`.
+func parseForwardedElement(s string) (elem forwardedElement) {
+	for _, pair := range splitQuoted(s, ';') {
+		eq := strings.IndexByte(pair, '=')
+		if eq < 0 {
+			continue
+		}
+		key, value := strings.TrimSpace(pair[:eq]), unquote(strings.TrimSpace(pair[eq+1:]))
+		switch strings.ToLower(key) {
+		case "for":
+			elem.ip = value
+		case "proto":
+			elem.proto = strings.ToLower(value)
+		case "host":
+			elem.host = value
+		}
+	}
+	return
+}
+
+// splitQuoted splits s by sep which is not in a quoted-string.
+func splitQuoted(s string, sep byte) []string {
+	var parts []string
+	quoted, start := false, 0
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '\\' && quoted:
+			i++
+		case c == '"':
+			quoted = !quoted
+		case c == sep && !quoted:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// unquote returns the value of the quoted-string s, or s itself if it is not quoted.
+func unquote(s string) string {
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return s
+	}
+	s = s[1 : len(s)-1]
+	if !strings.ContainsRune(s, '\\') {
+		return s
+	}
+	buf := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+		}
+		buf = append(buf, s[i])
+	}
+	return string(buf)
+}
+
+// parseNodeIP parses the IP of a node, which may have a port, e.g. "192.0.2.43:47011", "[2001:db8::1]:4711".
+func parseNodeIP(s string) net.IP {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil
+	}
+	if ip := net.ParseIP(s); ip != nil {
+		return ip
+	}
+	host, _, err := net.SplitHostPort(s)
+	if err != nil {
+		host = strings.TrimSuffix(strings.TrimPrefix(s, "["), "]")
+	}
+	return net.ParseIP(host)
+}
+
+// forwardedProtoHost returns the scheme and host of r set by the trusted proxies,
+// the empty string is returned if it is not set.
+func (engine *Engine) forwardedProtoHost(r *http.Request) (proto, host string) {
+	if engine.usesForwardedHeader() {
+		if values := r.Header[HeaderForwarded]; len(values) > 0 {
+			if elem, ok := engine.forwardedClient(values); ok && (elem.proto != "" || elem.host != "") {
+				return elem.proto, elem.host
+			}
+		}
+	}
+	proto = strings.ToLower(engine.forwardedValue(r, HeaderXForwardedProto))
+	host = engine.forwardedValue(r, HeaderXForwardedHost)
+	return proto, host
+}
+
+// forwardedValue returns the value of the X-Forwarded-Proto or X-Forwarded-Host header set by the
+// outermost trusted proxy. The values are walked from right to left along with the X-Forwarded-For
+// hops, stopping at the first untrusted hop. If the proxies do not append to both headers (the number
+// of values differs), only the rightmost value, which is set by the nearest proxy, is used.
+func (engine *Engine) forwardedValue(r *http.Request, header string) string {
+	values := reversedListValues(r.Header[header])
+	if len(values) == 0 {
+		return ""
+	}
+	hops := reversedListValues(r.Header[http.CanonicalHeaderKey(HeaderXForwardedFor)])
+	if len(hops) != len(values) {
+		return values[0]
+	}
+	i := 0
+	for i < len(values)-1 {
+		ip := parseNodeIP(hops[i])
+		if ip == nil || !engine.isTrustedProxy(ip) {
+			break
+		}
+		i++
+	}
+	return values[i]
+}
+
+func (engine *Engine) usesForwardedHeader() bool {
+	if engine.remoteIPHeaders == nil {
+		return true
+	}
+	for _, header := range engine.remoteIPHeaders {
+		if header == HeaderForwarded {
+			return true
+		}
+	}
+	return false
+}
+
+// reversedListValues returns the items of the comma-separated lists in values from right to left,
+// i.e. from the last item of the last line.
+func reversedListValues(values []string) []string {
+	var items []string
+	for i := len(values) - 1; i >= 0; i-- {
+		list := strings.Split(values[i], ",")
+		for j := len(list) - 1; j >= 0; j-- {
+			if item := strings.TrimSpace(list[j]); item != "" {
+				items = append(items, item)
+			}
+		}
+	}
+	return items
+}
@@ -0,0 +1,178 @@
+// Copyright 2014 Manu Martinez-Almeida.  All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package gin
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func serveTrusted(engine *Engine, remoteAddr string, header http.Header, tls *tls.ConnectionState) string {
+	var result string
+	engine.Get("/", func(ctx *Context) {
+		result = ctx.ClientIP() + " " + ctx.Scheme() + " " + ctx.Host()
+	})
+	r := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+	r.RemoteAddr = remoteAddr
+	r.TLS = tls
+	for k, v := range header {
+		r.Header[k] = v
+	}
+	engine.ServeHTTP(httptest.NewRecorder(), r)
+	return result
+}
+
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		name       string
+		proxies    []string // nil: FetchClientIPFromHeader disabled
+		headers    []string // nil: default headers
+		remoteAddr string
+		header     http.Header
+		want       string
+	}{
+		{"disabled", nil, nil, "10.0.0.1:1234",
+			http.Header{"X-Forwarded-For": {"1.1.1.1"}}, "10.0.0.1"},
+		{"untrusted remote", []string{"10.0.0.0/8"}, nil, "2.2.2.2:1234",
+			http.Header{"X-Forwarded-For": {"1.1.1.1"}}, "2.2.2.2"},
+		{"spoofed xff", []string{"10.0.0.0/8"}, nil, "10.0.0.1:1234",
+			http.Header{"X-Forwarded-For": {"6.6.6.6, 1.1.1.1, 10.0.0.2"}}, "1.1.1.1"},
+		{"multiple xff headers", []string{"10.0.0.0/8"}, nil, "10.0.0.1:1234",
+			http.Header{"X-Forwarded-For": {"6.6.6.6", "1.1.1.1, 10.0.0.2"}}, "1.1.1.1"},
+		{"all trusted", []string{"10.0.0.0/8"}, nil, "10.0.0.1:1234",
+			http.Header{"X-Forwarded-For": {"10.0.0.3, 10.0.0.2"}}, "10.0.0.3"},
+		{"invalid xff", []string{"10.0.0.0/8"}, nil, "10.0.0.1:1234",
+			http.Header{"X-Forwarded-For": {"1.1.1.1, bogus"}}, "10.0.0.1"},
+		{"single ip proxy", []string{"10.0.0.1"}, nil, "10.0.0.1:1234",
+			http.Header{"X-Forwarded-For": {"1.1.1.1, 10.0.0.2"}}, "10.0.0.2"},
+		{"ipv6 proxy", []string{"fd00::/8"}, nil, "[fd00::1]:1234",
+			http.Header{"X-Forwarded-For": {"2001:db8::1"}}, "2001:db8::1"},
+		{"xff before real ip", []string{"10.0.0.0/8"}, nil, "10.0.0.1:1234",
+			http.Header{"X-Real-Ip": {"3.3.3.3"}, "X-Forwarded-For": {"1.1.1.1"}}, "1.1.1.1"},
+		{"real ip", []string{"10.0.0.0/8"}, nil, "10.0.0.1:1234",
+			http.Header{"X-Real-Ip": {"3.3.3.3"}}, "3.3.3.3"},
+		{"untrusted real ip", []string{"10.0.0.0/8"}, nil, "2.2.2.2:1234",
+			http.Header{"X-Real-Ip": {"3.3.3.3"}}, "2.2.2.2"},
+		{"spoofed leftmost xff", []string{"10.0.0.0/8"}, nil, "10.0.0.1:1234",
+			http.Header{"X-Forwarded-For": {"10.0.0.9, 6.6.6.6", "1.1.1.1"}}, "1.1.1.1"},
+		{"real ip configured", []string{"10.0.0.0/8"}, []string{"X-Real-IP", "X-Forwarded-For"}, "10.0.0.1:1234",
+			http.Header{"X-Real-Ip": {"3.3.3.3"}, "X-Forwarded-For": {"1.1.1.1"}}, "3.3.3.3"},
+		{"xff before forwarded", []string{"10.0.0.0/8"}, nil, "10.0.0.1:1234",
+			http.Header{"X-Forwarded-For": {"1.1.1.1"}, "Forwarded": {"for=5.5.5.5"}}, "1.1.1.1"},
+		{"forwarded configured first", []string{"10.0.0.0/8"}, []string{"Forwarded", "X-Forwarded-For"}, "10.0.0.1:1234",
+			http.Header{"X-Forwarded-For": {"1.1.1.1"}, "Forwarded": {"for=5.5.5.5"}}, "5.5.5.5"},
+		{"precedence falls through", []string{"10.0.0.0/8"}, []string{"CF-Connecting-IP", "X-Forwarded-For"}, "10.0.0.1:1234",
+			http.Header{"X-Forwarded-For": {"1.1.1.1"}}, "1.1.1.1"},
+		{"forwarded", []string{"10.0.0.0/8"}, nil, "10.0.0.1:1234",
+			http.Header{"Forwarded": {`for=6.6.6.6, for="[2001:db8:cafe::17]:4711";proto=https, for=10.0.0.2`}}, "2001:db8:cafe::17"},
+		{"forwarded unknown", []string{"10.0.0.0/8"}, nil, "10.0.0.1:1234",
+			http.Header{"Forwarded": {`for=unknown`}}, "10.0.0.1"},
+		{"header precedence", []string{"10.0.0.0/8"}, []string{"cf-connecting-ip", "X-Forwarded-For"}, "10.0.0.1:1234",
+			http.Header{"Cf-Connecting-Ip": {"4.4.4.4"}, "X-Forwarded-For": {"1.1.1.1"}}, "4.4.4.4"},
+		{"header not configured", []string{"10.0.0.0/8"}, []string{"X-Forwarded-For"}, "10.0.0.1:1234",
+			http.Header{"X-Real-Ip": {"3.3.3.3"}}, "10.0.0.1"},
+	}
+	for _, tt := range tests {
+		engine := New()
+		if tt.proxies != nil {
+			engine.TrustedProxies(tt.proxies...)
+		}
+		if tt.headers != nil {
+			engine.RemoteIPHeaders(tt.headers...)
+		}
+		var ip string
+		engine.Get("/", func(ctx *Context) { ip = ctx.ClientIP() })
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = tt.remoteAddr
+		r.Header = tt.header
+		engine.ServeHTTP(httptest.NewRecorder(), r)
+		if ip != tt.want {
+			t.Errorf("%s: ClientIP() = %q, want %q", tt.name, ip, tt.want)
+		}
+	}
+}
+
+func TestClientIPDefaultTrustedProxies(t *testing.T) {
+	header := http.Header{
+		"X-Forwarded-For":   {"6.6.6.6, 1.1.1.1"},
+		"X-Forwarded-Proto": {"https"},
+		"X-Forwarded-Host":  {"evil.com"},
+		"X-Real-Ip":         {"6.6.6.6"},
+	}
+	tests := []struct {
+		remoteAddr string
+		want       string
+	}{
+		// the spoofed headers from the untrusted peer are ignored
+		{"2.2.2.2:1234", "2.2.2.2 http example.com"},
+		{"10.0.0.1:1234", "10.0.0.1 http example.com"},
+		// only the loopback addresses are trusted by default
+		{"127.0.0.1:1234", "1.1.1.1 https evil.com"},
+		{"[::1]:1234", "1.1.1.1 https evil.com"},
+	}
+	for _, tt := range tests {
+		engine := New()
+		engine.FetchClientIPFromHeader(true)
+		if got := serveTrusted(engine, tt.remoteAddr, header, nil); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.remoteAddr, got, tt.want)
+		}
+	}
+}
+
+func TestSchemeAndHost(t *testing.T) {
+	tests := []struct {
+		name       string
+		remoteAddr string
+		header     http.Header
+		tls        *tls.ConnectionState
+		want       string
+	}{
+		{"direct", "2.2.2.2:1234", nil, nil, "2.2.2.2 http example.com"},
+		{"direct tls", "2.2.2.2:1234", nil, &tls.ConnectionState{}, "2.2.2.2 https example.com"},
+		{"untrusted headers", "2.2.2.2:1234",
+			http.Header{"X-Forwarded-Proto": {"https"}, "X-Forwarded-Host": {"evil.com"}}, nil, "2.2.2.2 http example.com"},
+		{"x-forwarded", "10.0.0.1:1234",
+			http.Header{"X-Forwarded-For": {"1.1.1.1"}, "X-Forwarded-Proto": {"http, HTTPS"}, "X-Forwarded-Host": {"evil.com, api.example.com"}}, nil,
+			"1.1.1.1 https api.example.com"},
+		{"spoofed x-forwarded-proto line", "10.0.0.1:1234",
+			http.Header{"X-Forwarded-For": {"1.1.1.1"}, "X-Forwarded-Proto": {"https", "http"}, "X-Forwarded-Host": {"evil.com", "api.example.com"}}, nil,
+			"1.1.1.1 http api.example.com"},
+		{"spoofed x-forwarded-proto hop", "10.0.0.1:1234",
+			http.Header{"X-Forwarded-For": {"6.6.6.6, 1.1.1.1"}, "X-Forwarded-Proto": {"https, http"}}, nil,
+			"1.1.1.1 http example.com"},
+		{"trusted x-forwarded-proto chain", "10.0.0.1:1234",
+			http.Header{"X-Forwarded-For": {"1.1.1.1", "10.0.0.2"}, "X-Forwarded-Proto": {"https", "http"}}, nil,
+			"1.1.1.1 https example.com"},
+		{"forwarded", "10.0.0.1:1234",
+			http.Header{
+				"Forwarded":         {`for=6.6.6.6;proto=http;host=evil.com, for=1.1.1.1;proto=https;host="api.example.com:8443"`},
+				"X-Forwarded-Proto": {"http"},
+			}, nil, "1.1.1.1 https api.example.com:8443"},
+	}
+	for _, tt := range tests {
+		engine := New()
+		engine.TrustedProxies("10.0.0.0/8")
+		if got := serveTrusted(engine, tt.remoteAddr, tt.header, tt.tls); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestTrustedProxiesInvalid(t *testing.T) {
+	for _, cidr := range [...]string{"", "10.0.0.0/33", "bogus", "10.0.0/8"} {
+		if recv := catchPanic(func() { New().TrustedProxies(cidr) }); recv == nil {
+			t.Errorf("no panic for invalid trusted proxy '%s'", cidr)
+		}
+	}
+}
+
+func TestParseForwardedElement(t *testing.T) {
+	elem := parseForwardedElement(`For="[2001:db8::1]:4711"; Proto=HTTPS ;host="a\"b"`)
+	if elem.ip != "[2001:db8::1]:4711" || elem.proto != "https" || elem.host != `a"b` {
+		t.Errorf("parseForwardedElement() = %+v", elem)
+	}
+}